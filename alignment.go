@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/csv"
+	"math"
+	"os"
+	"strconv"
+)
+
+// NematicOrder: Calculates the 2D nematic order parameter of a set of fibres.
+// Fibres are undirected, so each fibre angle theta is doubled: S = |<exp(2i*theta)>|.
+// S = 1 when every fibre is parallel and S is close to 0 when the fibres point every which way.
+// Input: fibres ([]*Fibre) the fibres.
+// Output: S (float64) the order parameter, director (float64) the mean fibre angle in radians in [0, pi).
+func NematicOrder(fibres []*Fibre) (float64, float64) {
+	if len(fibres) == 0 {
+		return math.NaN(), math.NaN()
+	}
+	var sumCos, sumSin float64
+	for _, fibre := range fibres {
+		theta := math.Atan2(fibre.direction.y, fibre.direction.x)
+		sumCos += math.Cos(2 * theta)
+		sumSin += math.Sin(2 * theta)
+	}
+	n := float64(len(fibres))
+	S := math.Sqrt(sumCos*sumCos+sumSin*sumSin) / n
+	director := math.Atan2(sumSin, sumCos) / 2
+	if director < 0 {
+		director += math.Pi
+	}
+	return S, director
+}
+
+// LocalAlignmentMap: Splits the board into a gridSize x gridSize grid and calculates the nematic order
+// parameter of the fibres whose centers fall in each square.
+// Input: fibres ([]*Fibre) the fibres, gridSize (int) the number of squares along each side.
+// Output: ([][]float64) S for every square indexed [column][row]. Empty squares are NaN.
+func LocalAlignmentMap(fibres []*Fibre, gridSize int) [][]float64 {
+	buckets := make([][][]*Fibre, gridSize)
+	for i := range buckets {
+		buckets[i] = make([][]*Fibre, gridSize)
+	}
+	for _, fibre := range fibres {
+		i := GridIndex(fibre.position.x, gridSize)
+		j := GridIndex(fibre.position.y, gridSize)
+		buckets[i][j] = append(buckets[i][j], fibre)
+	}
+	alignmentMap := make([][]float64, gridSize)
+	for i := range alignmentMap {
+		alignmentMap[i] = make([]float64, gridSize)
+		for j := range alignmentMap[i] {
+			alignmentMap[i][j], _ = NematicOrder(buckets[i][j])
+		}
+	}
+	return alignmentMap
+}
+
+// GridIndex: Returns which of gridSize equal squares along one side of the board a coordinate falls in.
+func GridIndex(coordinate float64, gridSize int) int {
+	index := int(coordinate / ECMwidth * float64(gridSize))
+	if index < 0 {
+		return 0
+	}
+	if index >= gridSize {
+		return gridSize - 1
+	}
+	return index
+}
+
+// OrientationHistogram: Counts the fibres whose angle falls in each of numBins equal bins over [0, 180) degrees.
+// Input: fibres ([]*Fibre) the fibres, numBins (int) the number of bins.
+// Output: ([]int) the number of fibres in each bin. Fibres with an undefined direction aren't counted.
+func OrientationHistogram(fibres []*Fibre, numBins int) []int {
+	counts := make([]int, numBins)
+	for _, fibre := range fibres {
+		theta := math.Atan2(fibre.direction.y, fibre.direction.x)
+		if math.IsNaN(theta) {
+			continue
+		}
+		theta = math.Mod(theta+math.Pi, math.Pi) // fibres are undirected
+		bin := int(theta / math.Pi * float64(numBins))
+		if bin >= numBins {
+			bin = numBins - 1
+		}
+		counts[bin]++
+	}
+	return counts
+}
+
+// RingAlignment: Measures fibre alignment in a ring around a cell, using the fibres whose centers are
+// between innerRadius and outerRadius from the center of the cell.
+// Input: cell (*Cell) the cell, fibres ([]*Fibre) all fibres, innerRadius, outerRadius (float64) the ring.
+// Output: S (float64) the nematic order parameter of the ring, radial (float64) the mean of cos^2 of the angle between
+// each fibre and the line to the cell. radial is 1 if every fibre points at the cell, 0 if every fibre is tangential
+// and 0.5 for random fibres.
+func (cell *Cell) RingAlignment(fibres []*Fibre, innerRadius, outerRadius float64) (float64, float64) {
+	var ring []*Fibre
+	radial := 0.0
+	for _, fibre := range fibres {
+		distance := ComputeDistance(cell.position, fibre.position)
+		if distance < innerRadius || distance > outerRadius || distance == 0 {
+			continue
+		}
+		ring = append(ring, fibre)
+		toCell := OrderedPair{cell.position.x - fibre.position.x, cell.position.y - fibre.position.y}
+		cosine := DotProduct2D(toCell, fibre.direction) / (distance * fibre.direction.Magnitude())
+		radial += cosine * cosine
+	}
+	if len(ring) == 0 {
+		return math.NaN(), math.NaN()
+	}
+	S, _ := NematicOrder(ring)
+	return S, radial / float64(len(ring))
+}
+
+// WriteAlignmentToFile: Computes the alignment metrics for every time point and writes them to csv files
+// named with the given prefix, then plots the order parameter against time.
+// prefix + "Order.csv": time, S, director (degrees)
+// prefix + "Histogram.csv": time, fibre count in each AlignmentHistogramBins bin
+// prefix + "Map.csv": time, grid column, grid row, S
+// prefix + "Rings.csv": time, cell label, S, radial alignment
+// prefix + "Order.svg": S against time
+// Input: timeFrames ([]*ECM) the ECMs from the simulation, timeStep (float64) the time between frames.
+func WriteAlignmentToFile(timeFrames []*ECM, timeStep float64, prefix string) {
+	var orderRows, histogramRows, mapRows, ringRows [][]string
+	times := make([]float64, len(timeFrames))
+	orders := make([]float64, len(timeFrames))
+	for gen, frame := range timeFrames {
+		t := float64(gen) * timeStep
+		timeString := strconv.FormatFloat(t, 'f', 1, 64)
+
+		S, director := NematicOrder(frame.fibres)
+		times[gen], orders[gen] = t, S
+		orderRows = append(orderRows, []string{timeString, FormatValue(S), FormatValue(director * 180 / math.Pi)})
+
+		histogramRow := []string{timeString}
+		for _, count := range OrientationHistogram(frame.fibres, AlignmentHistogramBins) {
+			histogramRow = append(histogramRow, strconv.Itoa(count))
+		}
+		histogramRows = append(histogramRows, histogramRow)
+
+		for i, column := range LocalAlignmentMap(frame.fibres, AlignmentGridSize) {
+			for j, value := range column {
+				mapRows = append(mapRows, []string{timeString, strconv.Itoa(i), strconv.Itoa(j), FormatValue(value)})
+			}
+		}
+
+		for _, cell := range frame.cells {
+			ringS, radial := cell.RingAlignment(frame.fibres, cell.radius, cell.radius+AlignmentRingWidth)
+			ringRows = append(ringRows, []string{timeString, strconv.Itoa(cell.label), FormatValue(ringS), FormatValue(radial)})
+		}
+	}
+
+	WriteCSV(prefix+"Order.csv", orderRows)
+	WriteCSV(prefix+"Histogram.csv", histogramRows)
+	WriteCSV(prefix+"Map.csv", mapRows)
+	WriteCSV(prefix+"Rings.csv", ringRows)
+	WriteLinePlotSVG(prefix+"Order.svg", "Fibre alignment", "Time (hours)", "Nematic order parameter S",
+		[]Series{{Name: "S", X: times, Y: orders}})
+}
+
+// FormatValue: Formats a float64 for a csv file, writing NaN as an empty field.
+func FormatValue(value float64) string {
+	if math.IsNaN(value) {
+		return ""
+	}
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+// WriteCSV: Writes rows of strings to a csv file.
+func WriteCSV(filename string, rows [][]string) {
+	outFile, err := os.Create(filename)
+	if err != nil {
+		panic("Error creating output csv file " + filename + ".")
+	}
+	defer outFile.Close()
+
+	err = csv.NewWriter(outFile).WriteAll(rows)
+	if err != nil {
+		panic("Error writing output csv file " + filename + ".")
+	}
+}
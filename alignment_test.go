@@ -0,0 +1,58 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestNematicOrder(t *testing.T) {
+	type test struct {
+		angles []float64 // fibre directions in degrees
+		S      float64
+	}
+
+	tests := make([]test, 4)
+	// parallel fibres are perfectly aligned, even if they point opposite ways
+	tests[0].angles = []float64{30, 30, 210}
+	tests[0].S = 1.0
+
+	// perpendicular fibres cancel out
+	tests[1].angles = []float64{0, 90}
+	tests[1].S = 0.0
+
+	// evenly spread fibres cancel out
+	tests[2].angles = []float64{0, 60, 120}
+	tests[2].S = 0.0
+
+	// two fibres 90 degrees apart plus one along the first
+	tests[3].angles = []float64{0, 0, 90}
+	tests[3].S = 1.0 / 3.0
+
+	for i, test := range tests {
+		fibres := make([]*Fibre, len(test.angles))
+		for j, angle := range test.angles {
+			var fibre Fibre
+			fibre.direction = OrderedPair{math.Cos(angle * math.Pi / 180), math.Sin(angle * math.Pi / 180)}
+			fibres[j] = &fibre
+		}
+		outcome, _ := NematicOrder(fibres)
+		if math.Abs(outcome-test.S) > 1e-9 {
+			t.Errorf("Error! For input test dataset %d, your code gives %f, and the correct order parameter is %f", i, outcome, test.S)
+		}
+	}
+}
+
+func TestOrientationHistogram(t *testing.T) {
+	angles := []float64{10, 190, 100, -80, 170}
+	fibres := make([]*Fibre, len(angles))
+	for j, angle := range angles {
+		var fibre Fibre
+		fibre.direction = OrderedPair{math.Cos(angle * math.Pi / 180), math.Sin(angle * math.Pi / 180)}
+		fibres[j] = &fibre
+	}
+	answer := []int{2, 3}
+	outcome := OrientationHistogram(fibres, 2)
+	if outcome[0] != answer[0] || outcome[1] != answer[1] {
+		t.Errorf("Error! Your code gives %v, and the correct histogram is %v", outcome, answer)
+	}
+}
@@ -2,7 +2,6 @@ package main
 
 import (
 	"math"
-	"math/rand"
 )
 
 /*
@@ -12,34 +11,18 @@ type Cell struct {
 }
 */
 
-// UpdateCell finds the new direction of a given cell based on the fibre that causes the least change in direction
+// UpdateCell finds the new direction of a given cell using the run's steering model and moves the cell.
 // Input: cell object and a list of updated fibres
 // Output: cell with updated projection and position
-func (cell *Cell) UpdateCell(oldCell *Cell, fibres []*Fibre, threshold float64, time float64) {
-	// This was for the attempt to model the cell as a soft body object. It didn't work unfortunately.
-	// cell.UpdateShape(oldCell)
-
+func (cell *Cell) UpdateCell(fibres []*Fibre, threshold float64, time float64) {
 	// range over all fibres and compute projection vectors caused by all fibres on the cell
 	// to make this easier, we can only pick fibres that are within a certain critical distance to the cell
-	nearbyFibres := cell.FindNearbyFibres(threshold, fibres)    // returns a slice of nearest fibres within a certain threshold distance
-	cell.projection = cell.CalculateNewProjection(nearbyFibres) // Normalized net force acting on the cell from all nearby fibres
-
-	var changeMagnitude float64
-	var indexMin int
-	// Then we will loop through the fibres again to find the smallest change in projectiono
-	for index, fibre := range nearbyFibres {
-		delta_magnitude := FindAngleChange(cell.projection, fibre.direction)
-		if index == 0 { // set default magnitude of change to first one
-			changeMagnitude = math.Abs(delta_magnitude)
-		} else if math.Abs(delta_magnitude) > changeMagnitude { // find the minimum magnitude of change
-			changeMagnitude = delta_magnitude
-			indexMin = index
-		}
+	nearbyFibres := cell.FindNearbyFibres(threshold, fibres) // returns a slice of nearest fibres within a certain threshold distance
+	cell.projection = CellSteering.Steer(cell, nearbyFibres, time)
+	cell.UpdatePosition(fibres, threshold, time)
+	if SoftBodyCells {
+		cell.UpdateShape(time)
 	}
-	if len(nearbyFibres) > 0 {
-		cell.UpdateProjection(nearbyFibres[indexMin].direction) // Update the projection vector
-	}
-	cell.UpdatePosition(time)
 }
 
 // FindNearbyFibres: Finds all fibres who's centers are within some threshold distance
@@ -65,13 +48,14 @@ func (currCell *Cell) FindNearbyFibres(threshold float64, fibres []*Fibre) []*Fi
 // c (*Cell) a pointer to the Cell object being acted upon
 // fibres ([]*Slice) a slice of pointers to nearby fibres
 // Output:
-// (OrderedPair) The new normalized projection vector of the cell as an OrderedPair.
+// (OrderedPair) The new normalized projection vector of the cell as an OrderedPair. If there are no fibres,
+// or their noisy pulls cancel out, the cell keeps its current projection.
 func (c *Cell) CalculateNewProjection(fibres []*Fibre) OrderedPair {
-	var netForce OrderedPair
-	if len(fibres) > 0 {
-		netForce = c.CalculateNetForce(fibres)
-		netForce.Normalize()
+	netForce := c.CalculateNetForce(fibres)
+	if netForce.Magnitude() == 0 {
+		return c.projection
 	}
+	netForce.Normalize()
 	return netForce
 }
 
@@ -88,7 +72,7 @@ func (c *Cell) CalculateNetForce(fibres []*Fibre) OrderedPair {
 		if DotProduct2D(c.projection, val.direction) < 0 {
 			sign *= -1
 		}
-		noise := sign * (1 + rand.NormFloat64())
+		noise := sign * (1 + rng.NormFloat64())
 		projectionVector := ProjectVector(c.projection, MultiplyVectorByConstant2D(val.direction, noise))
 		netForce.x += projectionVector.x
 		netForce.y += projectionVector.y
@@ -108,53 +92,153 @@ func (c *Cell) UpdateProjection(newProjection OrderedPair) {
 	c.projection.y = newProjection.y
 }
 
-// UpdatePosition uses the updated projection vector to change the position of the cell.
-// Input: The time step (in hours).
-func (currCell *Cell) UpdatePosition(time float64) {
-	// The postion of the cell using the velocity and timeStep
-
-	var drag OrderedPair
-	// Calculate the drag force using the projection vectors from the fibres
-	drag = currCell.ComputeDragForce()
-	drag.Normalize()
+// UpdatePosition moves the cell with an overdamped Langevin integrator. The cell's velocity is the net force
+// acting on it (its motile force plus the traction of nearby fibres) divided by its drag coefficient, plus
+// isotropic Gaussian noise:
+// dx = F/(c*n) dt + NoiseAmplitude * sqrt(dt) * N(0, 1) independently in x and y.
+// If a single step could move the cell more than MaxStepFraction of its radius, the time step is split into
+// smaller substeps, and the nearby fibres and the force they exert are found again at the start of each one.
+// Input: fibres ([]*Fibre) all fibres on the ECM, threshold (float64) how close a fibre's center must be to pull
+// on the cell, time (float64) the time step (in hours).
+func (currCell *Cell) UpdatePosition(fibres []*Fibre, threshold, time float64) {
+	drag := currCell.DragCoefficient()
+	motileForce := currCell.ComputeMotileForce()
+	// the traction is an average of pulls no stronger than the motile force, so this bounds the speed
+	maxSpeed := motileForce.Magnitude() * (1 + FibreTraction) / drag
+	numSubsteps := NumSubsteps(maxSpeed, NoiseAmplitude, time, MaxStepFraction*currCell.radius)
+	h := time / float64(numSubsteps)
 
-	// Calculte the new position
-	currCell.position.x += (drag.x) * CellSpeed * time
-	currCell.position.y += (drag.y) * CellSpeed * time
+	// Only the fibres within threshold + slack of where they were last looked up can come within threshold of
+	// the cell before it has moved slack away from there, so the search over every fibre is only repeated then.
+	// The traction is the average of the pulls of the nearby fibres, and the pull of each one stays the same
+	// for the whole time step, so they are worked out along with the candidates.
+	slack := 0.5*threshold + currCell.radius
+	var anchor OrderedPair
+	var candidates []*Fibre
+	var pulls []OrderedPair
+	for step := 0; step < numSubsteps; step++ {
+		if step == 0 || ComputeDistance(currCell.position, anchor) > slack {
+			anchor = currCell.position
+			candidates = currCell.FindNearbyFibres(threshold+slack, fibres)
+			pulls = pulls[:0]
+			for i := range candidates {
+				pulls = append(pulls, currCell.ComputeTractionForce(candidates[i:i+1]))
+			}
+		}
+		var traction OrderedPair
+		numNearby := 0
+		for i, fibre := range candidates {
+			if ComputeDistance(currCell.position, fibre.position) < threshold {
+				traction.x += pulls[i].x
+				traction.y += pulls[i].y
+				numNearby++
+			}
+		}
+		if numNearby > 0 {
+			traction = MultiplyVectorByConstant2D(traction, 1/float64(numNearby))
+		}
+		velocity := OrderedPair{(motileForce.x + traction.x) / drag, (motileForce.y + traction.y) / drag}
+		var displacement OrderedPair
+		displacement.x = velocity.x*h + NoiseAmplitude*math.Sqrt(h)*rng.NormFloat64()
+		displacement.y = velocity.y*h + NoiseAmplitude*math.Sqrt(h)*rng.NormFloat64()
+		// The perimeter moves along with the center.
+		currCell.Translate(displacement)
+	}
+}
 
-	// Putting the cells on a torus
-	if currCell.position.x < 0 {
-		currCell.position.x += ECMwidth
-	} else if currCell.position.x > ECMwidth {
-		currCell.position.x -= ECMwidth
+// NumSubsteps: Finds how many substeps a time step has to be split into so that the expected displacement
+// per substep (drift plus one standard deviation of noise) is at most maxStep.
+// Input: speed (float64) the drift speed, noise (float64) the noise amplitude, time (float64) the time step,
+// maxStep (float64) the largest allowed displacement per substep.
+// Output: (int) the number of substeps, at least 1 and at most MaxSubsteps.
+func NumSubsteps(speed, noise, time, maxStep float64) int {
+	if maxStep <= 0 || time <= 0 {
+		return 1
 	}
-	if currCell.position.y < 0 {
-		currCell.position.y += ECMwidth
-	} else if currCell.position.y > ECMwidth {
-		currCell.position.y -= ECMwidth
+	numSubsteps := 1
+	// the noise term shrinks like sqrt(h), so this needs to be checked iteratively rather than solved in one go
+	for numSubsteps < MaxSubsteps {
+		h := time / float64(numSubsteps)
+		if speed*h+noise*math.Sqrt(h) <= maxStep {
+			break
+		}
+		numSubsteps *= 2
 	}
+	return numSubsteps
 }
 
-// ComputeDragForce: Computes the drag force acting on a cell by all nearby fibres.
-// Input: currCell (*Cell) a pointer to the Cell object.
-// Output: (OrderedPair) The drag force.
-func (currCell *Cell) ComputeDragForce() OrderedPair {
-	// F = speed x shape factor (c) x fluid viscosity (n) x projection vector + noise
-	var Drag, FinalForce OrderedPair
-	var Noise float64
+// DragCoefficient: Computes the drag coefficient of a cell, shape factor (c) x fluid viscosity (n).
+// Output: (float64) the drag coefficient.
+func (currCell *Cell) DragCoefficient() float64 {
+	return currCell.shapeFactor * currCell.viscocity
+}
+
+// MaxSubsteps is the most substeps NumSubsteps splits a time step into.
+const MaxSubsteps = 1 << 20
+
+// MotileForce: Returns the size of the force a cell crawls with. CellSpeed is the speed of a cell of the default
+// size in a medium of the default viscosity, so the force is CellSpeed x ReferenceDragCoefficient. Bigger cells
+// and more viscous media have more drag and so move more slowly.
+func MotileForce() float64 {
+	return CellSpeed * ReferenceDragCoefficient()
+}
 
-	// Calculate the noise
-	Noise = rand.Float64()*2.0 - 1.0
+// ComputeMotileForce: Computes the force a cell exerts to crawl along its projection vector,
+// F = MotileForce x unit projection vector.
+// Input: currCell (*Cell) a pointer to the Cell object.
+// Output: (OrderedPair) The motile force.
+func (currCell *Cell) ComputeMotileForce() OrderedPair {
+	return MultiplyVectorByConstant2D(UnitVector(currCell.projection), MotileForce())
+}
 
-	// Compute the drag force
-	Drag.x = CellSpeed * currCell.shapeFactor * currCell.viscocity * currCell.projection.x
-	Drag.y = CellSpeed * currCell.shapeFactor * currCell.viscocity * currCell.projection.y
+// ComputeTractionForce: Computes the pull of the nearby fibres on a cell. Each fibre pulls along its own direction
+// by the projection of the cell's unit projection vector onto it, so fibres aligned with the cell's heading pull
+// hardest. The pulls are averaged and scaled by FibreTraction x MotileForce.
+// Input: fibres ([]*Fibre) the fibres near the cell.
+// Output: (OrderedPair) The traction force, zero if there are no fibres.
+func (currCell *Cell) ComputeTractionForce(fibres []*Fibre) OrderedPair {
+	var traction OrderedPair
+	if len(fibres) == 0 || FibreTraction == 0 {
+		return traction
+	}
+	projection := UnitVector(currCell.projection)
+	for _, fibre := range fibres {
+		pull := ProjectVector(projection, fibre.direction)
+		traction.x += pull.x
+		traction.y += pull.y
+	}
+	return MultiplyVectorByConstant2D(traction, FibreTraction*MotileForce()/float64(len(fibres)))
+}
 
-	// Add the noise to the drag force
-	FinalForce.x = Drag.x + Noise
-	FinalForce.y = Drag.y + Noise
+// Translate: Moves a cell (and its perimeter) by some displacement, keeping it on the torus.
+// Input: delta (OrderedPair) the displacement.
+func (c *Cell) Translate(delta OrderedPair) {
+	c.position.x += delta.x
+	c.position.y += delta.y
+	for i := range c.perimeterVertices {
+		c.perimeterVertices[i].x += delta.x
+		c.perimeterVertices[i].y += delta.y
+	}
+	c.WrapPosition()
+}
 
-	return FinalForce
+// WrapPosition: Puts the cell back on the board if it has moved past an edge. The board is a torus.
+// The perimeter vertices are shifted with the center so the cell keeps its shape.
+func (currCell *Cell) WrapPosition() {
+	// a cell can cross the board more than once in a step, so shift by as many widths as it takes
+	var shift OrderedPair
+	if currCell.position.x < 0 || currCell.position.x > ECMwidth {
+		shift.x = -ECMwidth * math.Floor(currCell.position.x/ECMwidth)
+	}
+	if currCell.position.y < 0 || currCell.position.y > ECMwidth {
+		shift.y = -ECMwidth * math.Floor(currCell.position.y/ECMwidth)
+	}
+	currCell.position.x += shift.x
+	currCell.position.y += shift.y
+	for i := range currCell.perimeterVertices {
+		currCell.perimeterVertices[i].x += shift.x
+		currCell.perimeterVertices[i].y += shift.y
+	}
 }
 
 // CopyCell: Returns a pointer to a copy of a Cell object.
@@ -163,6 +247,7 @@ func (currCell *Cell) ComputeDragForce() OrderedPair {
 func (c *Cell) CopyCell() *Cell {
 	var newCell Cell
 	newCell.label = c.label
+	newCell.cellType = c.cellType
 	newCell.radius = c.radius
 	newCell.height = c.height
 	// newCell.speed = c.speed
@@ -197,45 +282,82 @@ func (c *Cell) CopyCell() *Cell {
 	return &newCell
 }
 
-// UpdatesShape updates the perimeter vertices of a Cell object.
+// UpdateShape updates the perimeter vertices of a Cell object.
+// The center of the cell is moved by UpdatePosition, so only the perimeter vertices move here.
+// Every spring pulls its ends back towards its resting length (F = kx), the perimeter vertex closest to
+// the projection vector is pushed outward by ProtrusionForce, and a pressure term keeps the area close
+// to its resting value. The vertices are overdamped, so they move with velocity ShapeRelaxationRate * F.
+// The time step is split into substeps small enough that the explicit integration stays stable.
 // Input:
 // c (*Cell): The cell whose shape is updated by this function.
-// oldCell (*Cell): The cell that represents "c" from the previous generation.
-func (c *Cell) UpdateShape(oldCell *Cell) {
-	for i := range c.springs {
-		movementMagnitude := oldCell.springs[i].CalculateSpringMoveMagnitude()
-		// Calculate the direction the ends of the spring should move
-		var delta OrderedPair
-		delta.x = oldCell.springs[i].end2.x - oldCell.springs[i].end1.x
-		delta.y = oldCell.springs[i].end2.y - oldCell.springs[i].end1.y
-		deltaMag := delta.Magnitude()
-		delta.x *= movementMagnitude / deltaMag
-		delta.y *= movementMagnitude / deltaMag
-		c.springs[i].end1.x += delta.x
-		c.springs[i].end1.y += delta.y
-		c.springs[i].end2.x -= delta.x
-		c.springs[i].end2.y -= delta.y
+// time (float64): The time step in hours.
+func (c *Cell) UpdateShape(time float64) {
+	numVertices := len(c.perimeterVertices)
+	if numVertices < 3 {
+		return
+	}
+	restArea := math.Pi * c.radius * c.radius
+	numSubsteps := int(math.Ceil(ShapeRelaxationRate * time / 0.1))
+	h := time / float64(numSubsteps)
+	forces := make([]OrderedPair, numVertices)
+	for step := 0; step < numSubsteps; step++ {
+		for i := range forces {
+			forces[i] = OrderedPair{}
+		}
+		// spring forces. The first numVertices springs join neighbouring vertices, the rest join vertices to the center.
+		for i := range c.springs {
+			force := c.springs[i].Force()
+			forces[i%numVertices].x += force.x
+			forces[i%numVertices].y += force.y
+			if i < numVertices {
+				forces[(i+1)%numVertices].x -= force.x
+				forces[(i+1)%numVertices].y -= force.y
+			}
+		}
+		// pressure pushes every vertex outward (or inward) along the outward normal of the perimeter
+		pressure := (restArea - c.Area()) / restArea
+		for i := range c.perimeterVertices {
+			previous := c.perimeterVertices[(i+numVertices-1)%numVertices]
+			next := c.perimeterVertices[(i+1)%numVertices]
+			normal := OrderedPair{next.y - previous.y, previous.x - next.x} // outward for counter-clockwise vertices
+			forces[i].x += 0.5 * pressure * normal.x
+			forces[i].y += 0.5 * pressure * normal.y
+		}
+		// protrusion at the leading edge
+		if leading := c.FindClosestPerimVertex(c.projection); leading != nil {
+			i := c.PerimVertexIndex(leading)
+			forces[i].x += ProtrusionForce * c.projection.x
+			forces[i].y += ProtrusionForce * c.projection.y
+		}
+		for i := range c.perimeterVertices {
+			c.perimeterVertices[i].x += ShapeRelaxationRate * h * forces[i].x
+			c.perimeterVertices[i].y += ShapeRelaxationRate * h * forces[i].y
+		}
 	}
 }
 
-// CalculateSpringMoveMagnitude: Calculates how much the "weights" at each end of a
-// spring should move towards each other. Neither end is thought to be fixed. Note: This is a pseudospring,
-// so it doesn't update using the force equation (F = kx).
-// Input: scalingFactor (float64) a factor used to determine how much the ends of the spring should move.
-func (s PseudoSpring) CalculateSpringMoveMagnitude() float64 {
-	dx := s.end1.x - s.end2.x
-	dy := s.end1.y - s.end2.y
+// Force: Calculates the force acting on end1 of a spring using Hooke's law with a spring constant of 1.
+// A stretched spring pulls end1 towards end2 and a compressed spring pushes it away.
+// Output: (OrderedPair) the force on end1. The force on end2 is the negative of this.
+func (s PseudoSpring) Force() OrderedPair {
+	dx := s.end2.x - s.end1.x
+	dy := s.end2.y - s.end1.y
 	distance := math.Sqrt(dx*dx + dy*dy)
-	numerator := distance - s.x0
-	magnitude := math.Abs(numerator) * numerator / (4 * s.x0)
-	// if numerator > s.x0 {
-	// 	magnitude = numerator / 2
-	// }
-	// if magnitude < 0 {
-	// 	fmt.Println(magnitude, distance, s.x0)
-	// }
-	// fmt.Println(magnitude)
-	return magnitude
+	if distance == 0 {
+		return OrderedPair{}
+	}
+	magnitude := (distance - s.x0) / distance
+	return OrderedPair{magnitude * dx, magnitude * dy}
+}
+
+// PerimVertexIndex: Returns the index of a perimeter vertex given a pointer to it, or -1 if it isn't one of the cell's vertices.
+func (currCell *Cell) PerimVertexIndex(vertex *OrderedPair) int {
+	for i := range currCell.perimeterVertices {
+		if &(currCell.perimeterVertices[i]) == vertex {
+			return i
+		}
+	}
+	return -1
 }
 
 // FindClosestPerimVertex Finds the closest vertex in the direction of the drag force.
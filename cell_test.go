@@ -0,0 +1,115 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestNumSubsteps(t *testing.T) {
+	type test struct {
+		speed, noise, time, maxStep float64
+	}
+
+	tests := make([]test, 6)
+	tests[0] = test{10, 0, 0.75, 7.5}
+	tests[1] = test{100, 0, 0.75, 7.5}
+	tests[2] = test{0, 50, 0.75, 7.5}
+	tests[3] = test{1000, 200, 1, 7.5}
+	tests[4] = test{1e6, 0, 0.75, 7.5}
+	tests[5] = test{10, 5, 0.1, 0.5}
+
+	for i, test := range tests {
+		outcome := NumSubsteps(test.speed, test.noise, test.time, test.maxStep)
+		h := test.time / float64(outcome)
+		if step := test.speed*h + test.noise*math.Sqrt(h); step > test.maxStep {
+			t.Errorf("Error! For input test dataset %d, your code gives %d substeps of %v uM, and the correct substeps are at most %v uM.", i, outcome, step, test.maxStep)
+		}
+		// half as many substeps would have been too few
+		if h := 2 * h; outcome > 1 && test.speed*h+test.noise*math.Sqrt(h) <= test.maxStep {
+			t.Errorf("Error! For input test dataset %d, your code gives %d substeps, and the correct number is smaller.", i, outcome)
+		}
+	}
+}
+
+// TestUpdatePositionNoise checks that a cell with no projection only diffuses, with variance NoiseAmplitude^2 * dt
+// in each direction, whatever the number of substeps.
+func TestUpdatePositionNoise(t *testing.T) {
+	noise, width := NoiseAmplitude, ECMwidth
+	defer func() { NoiseAmplitude, ECMwidth = noise, width }()
+	NoiseAmplitude, ECMwidth = 20, 1e9
+	SeedRandom(1)
+
+	const numCells = 20000
+	for _, time := range []float64{0.01, 0.75} {
+		var sumX, sumY, sumXX, sumYY float64
+		for i := 0; i < numCells; i++ {
+			cell := Cell{radius: 15, shapeFactor: 1, viscocity: 1, position: OrderedPair{5e8, 5e8}}
+			cell.UpdatePosition(nil, InteractionRadius, time)
+			dx, dy := cell.position.x-5e8, cell.position.y-5e8
+			sumX, sumY, sumXX, sumYY = sumX+dx, sumY+dy, sumXX+dx*dx, sumYY+dy*dy
+		}
+		answer := NoiseAmplitude * NoiseAmplitude * time
+		for axis, variance := range []float64{sumXX/numCells - math.Pow(sumX/numCells, 2), sumYY/numCells - math.Pow(sumY/numCells, 2)} {
+			if math.Abs(variance-answer) > 0.05*answer {
+				t.Errorf("Error! For a time step of %v, your code gives a variance of %v along axis %d, and the correct variance is %v.", time, variance, axis, answer)
+			}
+		}
+	}
+}
+
+// TestDragSlowsCells checks that the speed of a cell is its motile force divided by its drag coefficient, so that
+// ten times the viscosity gives a tenth of the speed.
+func TestDragSlowsCells(t *testing.T) {
+	noise, width := NoiseAmplitude, ECMwidth
+	defer func() { NoiseAmplitude, ECMwidth = noise, width }()
+	NoiseAmplitude, ECMwidth = 0, 1e9
+
+	reference := ReferenceDragCoefficient()
+	for _, viscosity := range []float64{1, 100, 1000} {
+		cell := Cell{radius: 15, shapeFactor: reference / 100, viscocity: viscosity, position: OrderedPair{5e8, 5e8}, projection: OrderedPair{0, 1}}
+		cell.UpdatePosition(nil, InteractionRadius, 0.5)
+		outcome := (cell.position.y - 5e8) / 0.5
+		answer := CellSpeed * 100 / viscosity
+		if math.Abs(outcome-answer) > 1e-9*answer || cell.position.x != 5e8 {
+			t.Errorf("Error! For a viscosity of %v, your code gives a speed of %v, and the correct speed is %v.", viscosity, outcome, answer)
+		}
+	}
+}
+
+// TestUpdatePositionSubsteps sends a fast cell towards a fibre that starts out of reach. With substeps the cell
+// feels the fibre once it comes within the interaction radius and is pulled along it part of the way, where a
+// single Euler step with the starting force would have carried it straight past.
+func TestUpdatePositionSubsteps(t *testing.T) {
+	noise, width, traction := NoiseAmplitude, ECMwidth, FibreTraction
+	defer func() { NoiseAmplitude, ECMwidth, FibreTraction = noise, width, traction }()
+	NoiseAmplitude, ECMwidth, FibreTraction = 0, 1e9, 0.5
+
+	// a fibre at 45 degrees whose center is 80 uM ahead of the cell
+	fibre := NewTestFibre(5e8+80, 5e8, 20, 45)
+	reference := ReferenceDragCoefficient()
+	cell := Cell{radius: 15, shapeFactor: reference, viscocity: 1, position: OrderedPair{5e8, 5e8}, projection: OrderedPair{1, 0}}
+	// 10 hours at CellSpeed * ReferenceDragCoefficient / drag = 10 uM per hour
+	cell.UpdatePosition([]*Fibre{fibre}, 40, 10)
+
+	euler := OrderedPair{5e8 + 100, 5e8}
+	if cell.position.y-euler.y < 1 || cell.position.x <= euler.x {
+		t.Errorf("Error! Your code moves the cell to %v, and the correct cell is pulled up and ahead of %v by the fibre.", cell.position, euler)
+	}
+
+	// the pull is (0.5, 0.5) * FibreTraction of the motile force while the fibre is within 40 uM, which is while the
+	// cell is between 40 and 120 uM along, i.e. for every substep starting at or after 40 uM
+	substeps := NumSubsteps(CellSpeed*1.5, 0, 10, 7.5)
+	h := 10 / float64(substeps)
+	var answer OrderedPair
+	for step := 0; step < substeps; step++ {
+		velocity := OrderedPair{CellSpeed, 0}
+		if 80-answer.x < 40 {
+			velocity = OrderedPair{CellSpeed * 1.25, CellSpeed * 0.25}
+		}
+		answer.x += velocity.x * h
+		answer.y += velocity.y * h
+	}
+	if outcome := (OrderedPair{cell.position.x - 5e8, cell.position.y - 5e8}); ComputeDistance(outcome, answer) > 1e-6 {
+		t.Errorf("Error! Your code moves the cell by %v, and the correct displacement is %v.", outcome, answer)
+	}
+}
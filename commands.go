@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Usage is printed when the program is given a command it doesn't know.
+const Usage = `Usage:
+  CellularDysfunction                 start the web app
+  CellularDysfunction serve [flags]   start the web app on a chosen address and output directory
+  CellularDysfunction run [flags]     run one simulation from the command line
+  CellularDysfunction sweep [flags]   run a grid of simulations in parallel and aggregate the results
+  CellularDysfunction fit [flags]     find the parameters whose simulations best match observed cell tracks
+  CellularDysfunction compare runA runB  compare the outputs of two runs side by side
+Run a command with -h to see its flags.`
+
+// RunCommand: Runs one of the command line tools.
+// Input: name (string) the command, args ([]string) the remaining command line arguments.
+func RunCommand(name string, args []string) {
+	switch name {
+	case "serve":
+		RunWebApp(args)
+	case "run":
+		RunFromCommandLine(args)
+	case "sweep":
+		RunSweep(args)
+	case "fit":
+		RunFit(args)
+	case "compare":
+		RunCompare(args)
+	default:
+		fmt.Println(Usage)
+		os.Exit(2)
+	}
+}
+
+// RunParameters are the arguments of RunSimulation.
+type RunParameters struct {
+	numGens, numCells, numFibres          int
+	timeStep, width, cellSpeed, stiffness float64
+}
+
+// DefaultRunParameters are the same defaults the web form shows.
+var DefaultRunParameters = RunParameters{numGens: 200, numCells: 5, numFibres: 7500, timeStep: 0.75, width: 500, cellSpeed: 10, stiffness: 0.95}
+
+// RunFromCommandLine: Parses the flags of the "run" command and runs a single simulation.
+// All output files are written to the directory given by -out.
+func RunFromCommandLine(args []string) {
+	flags := flag.NewFlagSet("run", flag.ExitOnError)
+	p := DefaultRunParameters
+	flags.IntVar(&p.numGens, "numGens", p.numGens, "number of generations")
+	flags.IntVar(&p.numCells, "numCells", p.numCells, "number of cells")
+	flags.IntVar(&p.numFibres, "numFibres", p.numFibres, "number of fibres")
+	flags.Float64Var(&p.timeStep, "timeStep", p.timeStep, "time step in hours")
+	flags.Float64Var(&p.width, "width", p.width, "width of the ECM in micrometers")
+	flags.Float64Var(&p.cellSpeed, "cellSpeed", p.cellSpeed, "speed of a cell away from fibres in micrometers per hour")
+	flags.Float64Var(&p.stiffness, "stiffness", p.stiffness, "matrix stiffness between 0 and 1")
+	flags.Float64Var(&NoiseAmplitude, "noiseAmplitude", NoiseAmplitude, "noise amplitude in micrometers per sqrt(hour)")
+	flags.Float64Var(&RecoilTime, "recoilTime", RecoilTime, "recoil time constant of fibres at stiffness 1 in hours (0 disables recoil)")
+	flags.Float64Var(&CellDoublingTime, "doublingTime", CellDoublingTime, "cell doubling time in hours (0 disables division)")
+	flags.Float64Var(&CellApoptosisRate, "apoptosisRate", CellApoptosisRate, "apoptosis rate per hour (0 disables apoptosis)")
+	flags.StringVar(&DaughterPlacement, "daughterPlacement", DaughterPlacement, "axis daughters are placed along: random or projection")
+	flags.StringVar(&LineageFormat, "lineageFormat", LineageFormat, "lineage file format: newick or json")
+	cellTypes := flags.String("cellTypes", "default:1:0:0", "cell types as name:fraction:degradationRate:depositionRate, separated by commas")
+	flags.Float64Var(&DegradationRadius, "degradationRadius", DegradationRadius, "distance in micrometers within which cells degrade fibres")
+	flags.Float64Var(&CrosslinkDensity, "crosslinkDensity", CrosslinkDensity, "probability that two crossing fibres are crosslinked (0 disables the network)")
+	flags.Float64Var(&CrosslinkStiffness, "crosslinkStiffness", CrosslinkStiffness, "spring constant of a crosslink")
+	flags.BoolVar(&SoftBodyCells, "softBody", SoftBodyCells, "let cells deform using their perimeter springs")
+	steering := flags.String("steeringModel", "fibre-snap", "steering model: "+strings.Join(SteeringModelNames(), ", "))
+	flags.Float64Var(&PersistenceTime, "persistenceTime", PersistenceTime, "persistence time in hours of the random walk and contact guidance models")
+	flags.Float64Var(&TumbleRate, "tumbleRate", TumbleRate, "tumble rate per hour of the run-and-tumble model")
+	flags.IntVar(&Dimensions, "dimensions", Dimensions, "2 for the flat board, 3 for the cube")
+	flags.IntVar(&RenderWorkers, "renderWorkers", RenderWorkers, "frames drawn at once (0 for one per CPU)")
+	flags.StringVar(&CellColouring, "cellColour", CellColouring, "cell colours: uniform, label or type")
+	flags.StringVar(&FibreColouring, "fibreColour", FibreColouring, "fibre colours: uniform, orientation or rotation")
+	flags.IntVar(&TrailLength, "trail", TrailLength, "generations of past positions drawn behind each cell")
+	flags.BoolVar(&DrawProjections, "arrows", DrawProjections, "draw each cell's projection vector as an arrow")
+	flags.BoolVar(&DrawTimeStamps, "timeStamp", DrawTimeStamps, "write the time on each frame")
+	flags.Float64Var(&FrameScaleBar, "frameScaleBar", FrameScaleBar, "length of the scale bar on each frame in micrometers (0 for none)")
+	flags.StringVar(&SnapshotGenerations, "snapshots", SnapshotGenerations, "comma separated generations to save as SVG snapshots (-1 is the last)")
+	flags.BoolVar(&SnapshotTrajectories, "trajectories", SnapshotTrajectories, "draw cell tracks on the SVG snapshots")
+	flags.Float64Var(&SnapshotScaleBar, "scaleBar", SnapshotScaleBar, "length of the SVG scale bar in micrometers (0 for none)")
+	seed := flags.Int64("seed", 0, "random seed (0 seeds from the clock)")
+	draw := flags.Bool("draw", true, "draw the animation")
+	flags.StringVar(&OutputFormat, "format", OutputFormat, "animation format: gif, png, mp4 or webm")
+	flags.StringVar(&VideoEncoder, "encoder", VideoEncoder, "video encoder binary for mp4 and webm")
+	flags.IntVar(&VideoFrameRate, "frameRate", VideoFrameRate, "frames per second of mp4 and webm output")
+	out := flags.String("out", ".", "directory to write the output files to")
+	flags.DurationVar(&TimeLimit, "timeout", TimeLimit, "stop the run after this long, e.g. 10m (0 for no limit)")
+	flags.BoolVar(&CheckInvariants, "check", CheckInvariants, "check the invariants of the model after every generation and stop at the first one broken")
+	paramsFile := AddModelParameterFlags(flags)
+	flags.Parse(args)
+	if err := ApplyParameterFile(flags, *paramsFile); err != nil {
+		panic("Error: " + err.Error())
+	}
+
+	if *seed != 0 {
+		SeedRandom(*seed)
+	}
+	DrawGIF = *draw
+	var err error
+	if CellTypes, err = ParseCellTypes(*cellTypes); err != nil {
+		panic("Error: " + err.Error())
+	}
+	model, ok := GetSteeringModel(*steering)
+	if !ok {
+		panic("Error: unknown steering model " + *steering + ".")
+	}
+	CellSteering = model
+	if Dimensions != 2 && Dimensions != 3 {
+		panic("Error: the number of dimensions must be 2 or 3.")
+	}
+	if !IsOutputFormat(OutputFormat) {
+		panic("Error: unknown output format " + OutputFormat + ".")
+	}
+	CheckDrawingOptions()
+	CheckNetworkOptions()
+	CheckSteeringOptions()
+	CheckLineageOptions()
+	CheckInvariantOptions()
+	CheckSnapshotGenerations(p.numGens)
+	Check3DOptions()
+	if err := os.MkdirAll(*out, 0755); err != nil {
+		panic("Error creating output directory " + *out + ".")
+	}
+	if err := os.Chdir(*out); err != nil {
+		panic("Error changing to output directory " + *out + ".")
+	}
+	if DrawGIF {
+		if err := os.MkdirAll(Plots, 0755); err != nil {
+			panic("Error creating gif directory.")
+		}
+	}
+
+	// Ctrl-C or the time limit stop the run cleanly, keeping the results so far
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if TimeLimit > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, TimeLimit)
+		defer cancel()
+	}
+	// record the parameters next to the outputs, as the web app does, so runs can be compared later
+	dir, _ := os.Getwd()
+	record := &RunRecord{ID: filepath.Base(dir), Created: time.Now(), Parameters: FlagParameters(flags), State: "running", dir: "."}
+	delete(record.Parameters, "out") // where the record is, not how the run went
+	if err := record.Save(); err != nil {
+		panic("Error writing run.json: " + err.Error())
+	}
+	animation, status := RunSimulation(ctx, p.numGens, p.numCells, p.numFibres, p.timeStep, p.width, p.cellSpeed, p.stiffness)
+	if err := record.Finish(animation, status, ""); err != nil {
+		panic("Error writing run.json: " + err.Error())
+	}
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	htemplate "html/template"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+)
+
+// RunOutputs holds what a comparison needs from the output directory of one run.
+type RunOutputs struct {
+	Name          string
+	Dir           string
+	Parameters    map[string]string  // from run.json, empty if the run didn't write one
+	Stats         map[string]float64 // the statistics named in SweepStatistics
+	Animation     string             // path of the animation inside Dir, "" if there isn't one
+	AnimationHTML htemplate.HTML     // set by whoever shows the comparison, since the URL depends on where it is shown
+	MSD           Series             // MSD against lag time
+	Speed         Series             // mean cell speed against time
+	Alignment     Series             // nematic order parameter against time
+}
+
+// ComparisonRow is one line of the table of differences between two runs.
+type ComparisonRow struct {
+	Name       string
+	Values     [2]string
+	Difference string // B - A and the relative change when both values are numbers, "" otherwise
+	Changed    bool
+}
+
+// Comparison is what the compare page and the compare command show about two runs.
+type Comparison struct {
+	Runs          [2]*RunOutputs
+	Parameters    []ComparisonRow
+	Statistics    []ComparisonRow
+	MSDPlot       htemplate.HTML // inline SVG of the two MSD curves
+	SpeedPlot     htemplate.HTML
+	AlignmentPlot htemplate.HTML
+	Web           bool // shown by the web app, so links to the other pages work
+}
+
+// AnimationNames are the files an animation may have been exported to, in the order they are looked for.
+var AnimationNames = []string{"CellMigration.out.gif", "CellMigration.mp4", "CellMigration.webm"}
+
+// ReadRunOutputs: Reads the parameters, statistics and curves of a finished 2D run.
+// Input: dir (string) the directory the run wrote its output to, name (string) what to call the run.
+// Output: the outputs, or an error if the motility and alignment results can't be read.
+func ReadRunOutputs(dir, name string) (*RunOutputs, error) {
+	run := &RunOutputs{Name: name, Dir: dir, Parameters: make(map[string]string)}
+	if record, err := ReadRunRecord(dir); err == nil {
+		run.Parameters = record.Parameters
+		run.Animation = record.Animation
+	}
+	if run.Animation == "" {
+		for _, animation := range AnimationNames {
+			if _, err := os.Stat(filepath.Join(dir, Plots, animation)); err == nil {
+				run.Animation = filepath.Join(Plots, animation)
+				break
+			}
+		}
+	}
+
+	stats, err := ReadRunSummary(dir)
+	if err != nil {
+		return nil, fmt.Errorf("can't read the results of %s (only finished 2D runs can be compared): %v", dir, err)
+	}
+	run.Stats = stats
+
+	correlation, err := ReadCSV(filepath.Join(dir, "MotilityCorrelation.csv"))
+	if err != nil {
+		return nil, err
+	}
+	run.MSD = ColumnSeries(name, correlation, 0, 2)
+	order, err := ReadCSV(filepath.Join(dir, "AlignmentOrder.csv"))
+	if err != nil {
+		return nil, err
+	}
+	run.Alignment = ColumnSeries(name, order, 0, 1)
+
+	positionArray, err := ReadPositionFile(filepath.Join(dir, "CellPosition.csv"))
+	if err != nil {
+		return nil, err
+	}
+	width := DefaultRunParameters.width
+	if value, err := strconv.ParseFloat(run.Parameters["width"], 64); err == nil {
+		width = value
+	}
+	run.Speed = MeanSpeedOverTime(ExtractTrajectories(positionArray, width))
+	run.Speed.Name = name
+	return run, nil
+}
+
+// ColumnSeries: Makes a series from two columns of a csv file, skipping rows that aren't numbers (such as a header).
+func ColumnSeries(name string, rows [][]string, xColumn, yColumn int) Series {
+	series := Series{Name: name}
+	for _, row := range rows {
+		if len(row) <= xColumn || len(row) <= yColumn {
+			continue
+		}
+		x := ParseValue(row[xColumn])
+		if math.IsNaN(x) {
+			continue
+		}
+		series.X = append(series.X, x)
+		series.Y = append(series.Y, ParseValue(row[yColumn]))
+	}
+	return series
+}
+
+// MeanSpeedOverTime: Returns the mean speed of the cells against time. The speed at a time is the mean over
+// the cells of the length of their last step divided by its duration.
+func MeanSpeedOverTime(trajectories []Trajectory) Series {
+	speeds := make(map[float64][]float64)
+	for _, trajectory := range trajectories {
+		for i, step := range trajectory.Steps() {
+			dt := trajectory.times[i+1] - trajectory.times[i]
+			if dt > 0 {
+				speeds[trajectory.times[i+1]] = append(speeds[trajectory.times[i+1]], step.Magnitude()/dt)
+			}
+		}
+	}
+	var series Series
+	for t := range speeds {
+		series.X = append(series.X, t)
+	}
+	sort.Float64s(series.X)
+	for _, t := range series.X {
+		series.Y = append(series.Y, Mean(speeds[t]))
+	}
+	return series
+}
+
+// NewComparisonRow: Compares one parameter or statistic of two runs. When both values are numbers and differ,
+// the difference is given as B - A followed by the change relative to A, e.g. "+0.45 (+90%)".
+func NewComparisonRow(name, a, b string) ComparisonRow {
+	row := ComparisonRow{Name: name, Values: [2]string{a, b}, Changed: a != b}
+	x, errA := strconv.ParseFloat(a, 64)
+	y, errB := strconv.ParseFloat(b, 64)
+	if errA != nil || errB != nil {
+		return row
+	}
+	row.Changed = x != y
+	if !row.Changed {
+		return row
+	}
+	row.Difference = fmt.Sprintf("%+.4g", y-x)
+	if x != 0 {
+		row.Difference += fmt.Sprintf(" (%+.0f%%)", 100*(y-x)/math.Abs(x))
+	}
+	return row
+}
+
+// CompareRuns: Builds the tables and plots comparing run a (A) with run b (B).
+// Every parameter set in either run is listed, with the ones that differ marked as changed.
+func CompareRuns(a, b *RunOutputs) *Comparison {
+	comparison := &Comparison{Runs: [2]*RunOutputs{a, b}}
+
+	var names []string
+	for name := range a.Parameters {
+		names = append(names, name)
+	}
+	for name := range b.Parameters {
+		if _, ok := a.Parameters[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		comparison.Parameters = append(comparison.Parameters, NewComparisonRow(name, a.Parameters[name], b.Parameters[name]))
+	}
+	for _, name := range SweepStatistics {
+		comparison.Statistics = append(comparison.Statistics,
+			NewComparisonRow(name, FormatStatistic(a.Stats[name]), FormatStatistic(b.Stats[name])))
+	}
+
+	comparison.MSDPlot = htemplate.HTML(LinePlotSVG("Mean squared displacement", "Lag time (hours)", "MSD (uM^2)",
+		[]Series{a.MSD, b.MSD}))
+	comparison.SpeedPlot = htemplate.HTML(LinePlotSVG("Cell speed", "Time (hours)", "Mean speed (uM/hour)",
+		[]Series{a.Speed, b.Speed}))
+	comparison.AlignmentPlot = htemplate.HTML(LinePlotSVG("Fibre alignment", "Time (hours)", "Nematic order parameter S",
+		[]Series{a.Alignment, b.Alignment}))
+	return comparison
+}
+
+// FormatStatistic: Formats a statistic to 4 significant figures for the comparison table, writing NaN as "".
+func FormatStatistic(value float64) string {
+	if math.IsNaN(value) {
+		return ""
+	}
+	return strconv.FormatFloat(value, 'g', 4, 64)
+}
+
+// WriteComparison: Writes a comparison to a directory:
+// "Comparison.csv": kind (parameter or statistic), name, value in A, value in B, difference, changed
+// "CompareMSD.svg", "CompareSpeed.svg", "CompareAlignment.svg": the overlaid curves
+// "Compare.html": the animations side by side, the curves and the table, linking to the runs' own files.
+func WriteComparison(comparison *Comparison, out string) {
+	rows := [][]string{{"kind", "name", "A", "B", "difference", "changed"}}
+	for _, row := range comparison.Parameters {
+		rows = append(rows, []string{"parameter", row.Name, row.Values[0], row.Values[1], row.Difference, strconv.FormatBool(row.Changed)})
+	}
+	for _, row := range comparison.Statistics {
+		rows = append(rows, []string{"statistic", row.Name, row.Values[0], row.Values[1], row.Difference, strconv.FormatBool(row.Changed)})
+	}
+	WriteCSV(filepath.Join(out, "Comparison.csv"), rows)
+
+	plots := map[string]htemplate.HTML{"CompareMSD.svg": comparison.MSDPlot, "CompareSpeed.svg": comparison.SpeedPlot,
+		"CompareAlignment.svg": comparison.AlignmentPlot}
+	for name, plot := range plots {
+		if err := os.WriteFile(filepath.Join(out, name), []byte(plot), 0644); err != nil {
+			panic("Error writing plot " + name + ".")
+		}
+	}
+
+	// the page links to the animations where they are, relative to the page
+	for _, run := range comparison.Runs {
+		if run.Animation == "" {
+			continue
+		}
+		absolute, _ := filepath.Abs(filepath.Join(run.Dir, run.Animation))
+		page, _ := filepath.Abs(out)
+		relative, err := filepath.Rel(page, absolute)
+		if err != nil {
+			relative = absolute
+		}
+		run.AnimationHTML = htemplate.HTML(AnimationHTML(filepath.ToSlash(relative)))
+	}
+	page, err := os.Create(filepath.Join(out, "Compare.html"))
+	if err != nil {
+		panic("Error creating Compare.html.")
+	}
+	defer page.Close()
+	if err := ExecuteHTMLTemplate(page, "compare.html", comparison); err != nil {
+		panic("Error writing Compare.html: " + err.Error())
+	}
+}
+
+// RunCompare: Parses the flags of the "compare" command and compares the output directories of two runs,
+// e.g. "compare -out comparison results/soft results/stiff".
+func RunCompare(args []string) {
+	flags := flag.NewFlagSet("compare", flag.ExitOnError)
+	out := flags.String("out", "comparison", "directory to write the comparison to")
+	flags.Parse(args)
+	if flags.NArg() != 2 {
+		fmt.Println("Usage: CellularDysfunction compare [-out dir] runA runB")
+		os.Exit(2)
+	}
+
+	var runs [2]*RunOutputs
+	for i, dir := range flags.Args() {
+		name := string(rune('A'+i)) + ": " + filepath.Base(filepath.Clean(dir))
+		run, err := ReadRunOutputs(dir, name)
+		if err != nil {
+			panic("Error: " + err.Error())
+		}
+		runs[i] = run
+	}
+	comparison := CompareRuns(runs[0], runs[1])
+	if err := os.MkdirAll(*out, 0755); err != nil {
+		panic("Error creating output directory " + *out + ".")
+	}
+	WriteComparison(comparison, *out)
+
+	for _, row := range append(comparison.Parameters, comparison.Statistics...) {
+		if row.Changed {
+			fmt.Printf("%s: %s -> %s %s\n", row.Name, row.Values[0], row.Values[1], row.Difference)
+		}
+	}
+	fmt.Println("Comparison written to " + filepath.Join(*out, "Compare.html") + ".")
+}
+
+// compareHandler: Shows two runs from the history side by side, e.g. /compare?run=<id A>&run=<id B>.
+func compareHandler(w http.ResponseWriter, r *http.Request) {
+	ids := r.URL.Query()["run"]
+	if len(ids) != 2 {
+		http.Error(w, "Pick exactly two runs to compare.", http.StatusBadRequest)
+		return
+	}
+	var runs [2]*RunOutputs
+	for i, id := range ids {
+		record, err := LoadRunRecord(id)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		run, err := ReadRunOutputs(record.Dir(), string(rune('A'+i))+": "+id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if run.Animation != "" {
+			run.AnimationHTML = htemplate.HTML(AnimationHTML(record.URL(run.Animation)))
+		}
+		runs[i] = run
+	}
+	comparison := CompareRuns(runs[0], runs[1])
+	comparison.Web = true
+	ServePage(w, "compare.html", comparison)
+}
@@ -0,0 +1,26 @@
+package main
+
+import "testing"
+
+func TestNewComparisonRow(t *testing.T) {
+	type test struct {
+		a, b       string
+		difference string
+		changed    bool
+	}
+
+	tests := make([]test, 5)
+	tests[0] = test{"0.5", "0.95", "+0.45 (+90%)", true}
+	tests[1] = test{"10", "10.0", "", false}
+	tests[2] = test{"0", "2", "+2", true}
+	tests[3] = test{"random", "projection", "", true}
+	tests[4] = test{"", "0.35", "", true}
+
+	for i, test := range tests {
+		row := NewComparisonRow("x", test.a, test.b)
+		if row.Difference != test.difference || row.Changed != test.changed {
+			t.Errorf("Error! For input test dataset %d, your code gives %q (changed %v), and the correct difference is %q (changed %v)",
+				i, row.Difference, row.Changed, test.difference, test.changed)
+		}
+	}
+}
@@ -1,14 +1,80 @@
 package main
 
+import "time"
+
 var ECMwidth float64 = 500.0 // uM
 var ECMstiffness float64 = 0.95
-var CellSpeed float64 = 10.0 // uM per second
+var CellSpeed float64 = 10.0            // uM per hour, the speed of a cell away from fibres
+var CellIntegrin float64 = 50.0         // % of integrins expressed by the cells
+var InteractionRadius float64 = 40.0    // uM. Cells and fibres closer than this act on each other.
+var FibreLengthMean float64 = 75.0      // uM. Fibre lengths are normally distributed.
+var FibreLengthSD float64 = 5.0         // uM
+var FibreWidth float64 = 0.2            // uM, i.e. 200nm
+var CellRadius float64 = 15.0           // uM
+var CellHeight float64 = 2.6            // uM
+var CellViscosity float64 = 100.0       // Poise
+var FibreTraction float64 = 0.5         // pull of the nearby fibres as a fraction of the motile force
+var ShapeFactorCoefficient = 16.7       // k in the shape factor c = k * sqrt(0.5 * r * h) of Eqn S3
+var AlignFactorCoefficient = 0.1        // a in ComputePhi's alignFactor = 1 - a * integrin * (1 - stiffness)
+var PlacementMargin float64 = 0.125     // fraction of the width at each edge where cells aren't placed at the start
+var CellDoublingTime float64 = 0.0      // hours. 0 disables cell division.
+var CellApoptosisRate float64 = 0.0     // per hour. 0 disables apoptosis.
+var DaughterPlacement string = "random" // "random" or "projection": the axis along which daughters are placed.
+var DegradationRadius float64 = 20.0    // uM. Cells degrade fibres closer than this.
+var MinimumFibreLength float64 = 5.0    // uM. Fibres degraded below this length are removed.
+var CellTypes = []CellType{{name: "default", fraction: 1.0}}
+var CrosslinkDensity float64 = 0.0                   // probability that two crossing fibres are crosslinked. 0 disables the network model.
+var CrosslinkStiffness float64 = 1.0                 // spring constant of a crosslink
+var RelaxationIterations int = 20                    // spring relaxation steps per generation
+var SoftBodyCells bool = false                       // if true cells deform using their perimeter springs
+var ShapeRelaxationRate float64 = 5.0                // per hour. How quickly the perimeter vertices respond to forces.
+var ProtrusionForce float64 = 5.0                    // uM. Outward push on the leading perimeter vertex.
+var CellSteering SteeringModel = FibreSnapSteering{} // see SteeringModels for the available models
+var PersistenceTime float64 = 2.0                    // hours. Used by the random walk and contact guidance steering models.
+var TumbleRate float64 = 0.5                         // per hour. Used by the run-and-tumble steering model.
+var GuidanceLength float64 = 10.0                    // uM. Decay length of fibre influence in the contact guidance steering model.
+var NoiseAmplitude float64 = 0.0                     // uM per sqrt(hour). Standard deviation of the random motion in each direction.
+var MaxStepFraction float64 = 0.5                    // largest displacement per integration substep, as a fraction of the cell radius
+var RecoilTime float64 = 0.0                         // hours. Recoil time constant at stiffness 1. 0 disables recoil.
+var Dimensions int = 2                               // 2 for the flat board, 3 for the cube
+var RenderMode3D string = "projection"               // "projection" or "slice"
+var SliceDepth float64 = 0.5                         // height of the slice as a fraction of ECMwidth
+var SliceThickness float64 = 20.0                    // uM
+var AlignmentGridSize int = 10                       // squares along each side of the local alignment map
+var AlignmentHistogramBins int = 18                  // bins over [0, 180) degrees
+var AlignmentRingWidth float64 = 30.0                // uM. Width of the ring around each cell used for ring alignment.
+var DrawGIF bool = true                              // false skips drawing, e.g. for runs in a parameter sweep
+var SnapshotGenerations string = ""                  // comma separated generations to save as SVG, e.g. "0,-1" for the first and last
+var SnapshotTrajectories bool = false                // draw the cell tracks on SVG snapshots
+var SnapshotScaleBar float64 = 100.0                 // uM. Length of the scale bar on SVG snapshots, 0 for none.
+var CellColouring string = "uniform"                 // "uniform", "label" or "type"
+var FibreColouring string = "uniform"                // "uniform", "orientation" or "rotation"
+var TrailLength int = 0                              // generations of past positions drawn behind each cell
+var DrawProjections bool = false                     // draw each cell's projection vector as an arrow
+var DrawTimeStamps bool = false                      // write the time on each frame
+var FrameScaleBar float64 = 0.0                      // uM. Length of the scale bar on each frame, 0 for none.
+var TimeLimit time.Duration = 0                      // longest a run may take before it is stopped, 0 for no limit
+var ViewerGracePeriod = 15 * time.Second             // how long a web run keeps going after its page is closed
+var LiveFibreLimit int = 1500                        // most fibres sent per generation to the live viewer
+var RenderWorkers int = 0                            // frames drawn at once, 0 for one per CPU
+var OutputDir string = "."                           // directory RunSimulation writes its outputs to
+var ThumbnailSize int = 0                            // pixels. Width of Thumbnail.png of the final frame, 0 for none.
+var ServerAddress string = ":5000"                   // address the web app listens on
+var TemplateDir string = ""                          // directory to read page templates from, "" for the built in ones
+var ShutdownGrace = 30 * time.Second                 // how long a web run may go on once the server is told to stop
+var OutputFormat string = "gif"                      // "gif", "png", "mp4" or "webm"
+var VideoEncoder string = "ffmpeg"                   // binary used to encode mp4 and webm
+var VideoFrameRate int = 10                          // frames per second of mp4 and webm output
+var LineageFormat string = "newick"                  // "newick" or "json"
+var CheckInvariants bool = false                     // check every generation of a 2D run with FindInvariantViolation
 
 type ECM struct {
 	// width     float64
 	// stiffness float64
-	fibres []*Fibre
-	cells  []*Cell
+	fibres     []*Fibre
+	cells      []*Cell
+	lineage    *Lineage // shared by every time frame of a simulation
+	crosslinks []Crosslink
 }
 
 type Cell struct {
@@ -16,20 +82,23 @@ type Cell struct {
 	position, projection                             OrderedPair
 	perimeterVertices                                []OrderedPair
 	springs                                          []PseudoSpring
-	label                                            int
+	label, cellType                                  int // cellType indexes CellTypes
 }
 
 type Fibre struct {
 	length, width   float64
 	position, pivot OrderedPair
 	direction       OrderedPair
+	flipped         bool        // true if FindPivot has reversed direction an odd number of times
+	restDirection   OrderedPair // MaterialDirection when the fibre was created
+	restPosition    OrderedPair
 }
 
 type OrderedPair struct {
 	x, y float64
 }
 
-// This data object was for the attempt at implementing soft-body physics.
+// PseudoSpring joins two points of a soft-body cell (two perimeter vertices, or a perimeter vertex and the center).
 type PseudoSpring struct {
 	end1, end2 *OrderedPair // Ordered Pair objects representing the ends of the spring.
 	x0         float64      // the resting length of the "spring"
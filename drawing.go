@@ -2,44 +2,136 @@ package main
 
 import (
 	"canvas"
+	"context"
 	"image"
+	"runtime"
+	"sync"
 )
 
-/*
-Not necessary: Was for if I could get soft body physics working.
-ADD THIS TO canvas.go. NECESSARY FOR DRAWING POLYGONS.
-func (c *Canvas) BeginPath() {
-	c.gc.BeginPath()
-}
-
-func (c *Canvas) Close() {
-	c.gc.Close()
-}
-*/
-
 // AnimateSystem takes a slice of Universe objects along with a canvas width
 // parameter and a frequency parameter.
-// Every frequency steps, it generates a slice of images corresponding to drawing each Universe
-// on a canvasWidth x canvasWidth canvas.
+// Every frequency steps, it draws the Universe on a canvasWidth x canvasWidth canvas and hands the image to emit.
 // A scaling factor is a final input that is used to scale the stars big enough to see them.
-func DrawECM(timePoints []*ECM, canvasWidth, frequency int, scalingFactor float64) []image.Image {
-	images := make([]image.Image, 0)
-
+// timeStep is the time between frames in hours, used for the time stamp.
+// Frames are drawn concurrently by RenderInOrder, which passes them to emit in order and only keeps a few of
+// them at once. If ctx ends or emit fails, drawing stops and the error is returned.
+// Output: (int) the number of frames passed to emit.
+func DrawECM(ctx context.Context, timePoints []*ECM, canvasWidth, frequency int, scalingFactor, timeStep float64, emit func(image.Image) error) (int, error) {
 	if len(timePoints) == 0 {
 		panic("Error: no Universe objects present in AnimateSystem.")
 	}
+	if frequency < 1 {
+		panic("Error: the frame frequency must be at least 1.")
+	}
+
+	// every frequency-th universe is drawn, starting with the first. Drawing only reads the frames and
+	// the global settings, so frames can be drawn at the same time.
+	numFrames := (len(timePoints)-1)/frequency + 1
+	numEmitted := 0
+	err := RenderInOrder(ctx, numFrames, NumRenderWorkers(), func(i int) image.Image {
+		generation := i * frequency
+		overlay := FrameOverlay{time: float64(generation) * timeStep, trails: FindTrails(timePoints, generation, TrailLength)}
+		return timePoints[generation].DrawToCanvas(canvasWidth, scalingFactor, overlay)
+	}, func(img image.Image) error {
+		numEmitted++
+		return emit(img)
+	})
+	return numEmitted, err
+}
 
-	// for every universe, draw to canvas and grab the image
-	for i := range timePoints {
-		images = append(images, timePoints[i].DrawToCanvas(canvasWidth, scalingFactor))
+// NumRenderWorkers: Returns how many frames to draw at once: RenderWorkers, or the number of CPUs if that is 0.
+func NumRenderWorkers() int {
+	if RenderWorkers > 0 {
+		return RenderWorkers
+	}
+	return runtime.NumCPU()
+}
+
+// RenderInOrder: Calls render(i) for i = 0 ... n-1 on a pool of workers and passes each image to emit in
+// order of i as soon as it and every image before it are done. At most 2*workers frames are being drawn or
+// waiting for an earlier one at any time, so memory doesn't grow with n.
+// ctx is checked before each frame is drawn and emitted; if it ends, or emit returns an error, no more frames
+// are emitted and that error is returned.
+func RenderInOrder(ctx context.Context, n, workers int, render func(i int) image.Image, emit func(image.Image) error) error {
+	if workers < 1 {
+		workers = 1
+	}
+	type frame struct {
+		index int
+		img   image.Image
+	}
+	slots := make(chan struct{}, 2*workers) // taken when a frame is handed out, freed when it is emitted
+	stop := make(chan struct{})             // closed when emitting stops early
+	indices := make(chan int)
+	rendered := make(chan frame)
+
+	go func() {
+		defer close(indices)
+		for i := 0; i < n; i++ {
+			select {
+			case slots <- struct{}{}:
+			case <-stop:
+				return
+			}
+			select {
+			case indices <- i:
+			case <-stop:
+				return
+			}
+		}
+	}()
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				var img image.Image
+				if ctx.Err() == nil {
+					img = render(i)
+				}
+				select {
+				case rendered <- frame{i, img}:
+				case <-stop:
+					return
+				}
+			}
+		}()
 	}
+	go func() {
+		wg.Wait()
+		close(rendered)
+	}()
 
-	return images
+	// frames that finish early wait here for the ones before them
+	waiting := make(map[int]image.Image)
+	next := 0
+	var err error
+	for f := range rendered {
+		if err != nil {
+			continue // drain the workers
+		}
+		waiting[f.index] = f.img
+		for img, ok := waiting[next]; ok; img, ok = waiting[next] {
+			delete(waiting, next)
+			if err = ctx.Err(); err == nil {
+				err = emit(img)
+			}
+			if err != nil {
+				close(stop)
+				break
+			}
+			next++
+			<-slots
+		}
+	}
+	return err
 }
 
 // DrawToCanvas generates the image corresponding to a canvas after drawing a ECM
 // object's bodies on a square canvas that is canvasWidth pixels x canvasWidth pixels.
-func (e *ECM) DrawToCanvas(canvasWidth int, scalingFactor float64) image.Image {
+// The overlay gives the trails and time stamp drawn on top when those options are on.
+func (e *ECM) DrawToCanvas(canvasWidth int, scalingFactor float64, overlay FrameOverlay) image.Image {
 	if e == nil {
 		panic("Can't Draw a nil ECM.")
 	}
@@ -62,26 +154,21 @@ func (e *ECM) DrawToCanvas(canvasWidth int, scalingFactor float64) image.Image {
 		direction.y *= 0.5 * f.length / magnitude * float64(canvasWidth) / ECMwidth
 
 		c.SetLineWidth(f.width / ECMwidth * float64(canvasWidth))
-		c.SetStrokeColor(canvas.MakeColor(100, 100, 200))
+		c.SetStrokeColor(MakeCanvasColour(FibreColour(f)))
 		c.MoveTo(center_x-direction.x, center_y-direction.y)
 		c.LineTo(center_x+direction.x, center_y+direction.y)
 		c.Stroke()
 		c.FillStroke()
 	}
 
+	e.DrawTrails(&c, overlay.trails, canvasWidth)
+
 	// range over all the bodies and draw them.
 	for _, c1 := range e.cells {
-		c.SetFillColor(canvas.MakeColor(200, 150, 200))
-		cx := (c1.position.x / ECMwidth) * float64(canvasWidth)
-		cy := (c1.position.y / ECMwidth) * float64(canvasWidth)
-		r := scalingFactor * (c1.radius / ECMwidth) * float64(canvasWidth)
-		c.Circle(cx, cy, r)
-		c.Fill()
-
-		/*
-			//This is for if soft body works
-			c.SetFillColor(canvas.MakeColor(200, 150, 200))
-			c.BeginPath()
+		c.SetFillColor(MakeCanvasColour(CellColour(c1)))
+		if SoftBodyCells && len(c1.perimeterVertices) > 2 {
+			// Draw the cell as a polygon through its perimeter vertices. Fill clears the path afterwards,
+			// so no explicit BeginPath/Close is needed.
 			for i := range c1.perimeterVertices {
 				x := c1.perimeterVertices[i].x / ECMwidth * float64(canvasWidth)
 				y := c1.perimeterVertices[i].y / ECMwidth * float64(canvasWidth)
@@ -93,9 +180,24 @@ func (e *ECM) DrawToCanvas(canvasWidth int, scalingFactor float64) image.Image {
 			}
 			c.LineTo(c1.perimeterVertices[0].x/ECMwidth*float64(canvasWidth),
 				c1.perimeterVertices[0].y/ECMwidth*float64(canvasWidth))
-			c.Close()
-			c.FillStroke()
-		*/
+			c.Fill()
+			continue
+		}
+		cx := (c1.position.x / ECMwidth) * float64(canvasWidth)
+		cy := (c1.position.y / ECMwidth) * float64(canvasWidth)
+		r := scalingFactor * (c1.radius / ECMwidth) * float64(canvasWidth)
+		c.Circle(cx, cy, r)
+		c.Fill()
+	}
+
+	if DrawProjections {
+		e.DrawProjectionArrows(&c, canvasWidth)
+	}
+	if DrawTimeStamps {
+		DrawTimeStamp(&c, overlay.time, canvasWidth)
+	}
+	if FrameScaleBar > 0 {
+		DrawScaleBar(&c, FrameScaleBar, canvasWidth)
 	}
 
 	// we want to return an image!
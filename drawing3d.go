@@ -0,0 +1,72 @@
+package main
+
+import (
+	"canvas"
+	"context"
+	"image"
+	"math"
+)
+
+// DrawECM3D renders every frame of a 3D simulation and hands each image to emit in order. With mode "slice"
+// only the fibres and cells that cross a slab of thickness SliceThickness centred at height SliceDepth*ECMwidth
+// are drawn. With mode "projection" everything is projected onto the xy plane and fibres are shaded by their height.
+// Like DrawECM it stops and returns the error if ctx ends or emit fails.
+// Output: (int) the number of frames passed to emit.
+func DrawECM3D(ctx context.Context, timePoints []*ECM3D, canvasWidth int, mode string, emit func(image.Image) error) (int, error) {
+	if len(timePoints) == 0 {
+		panic("Error: no ECM3D objects present in DrawECM3D.")
+	}
+	numEmitted := 0
+	err := RenderInOrder(ctx, len(timePoints), NumRenderWorkers(), func(i int) image.Image {
+		return timePoints[i].DrawToCanvas3D(canvasWidth, mode)
+	}, func(img image.Image) error {
+		numEmitted++
+		return emit(img)
+	})
+	return numEmitted, err
+}
+
+// DrawToCanvas3D draws one 3D ECM as a slice or projection on a canvasWidth x canvasWidth canvas.
+func (e *ECM3D) DrawToCanvas3D(canvasWidth int, mode string) image.Image {
+	c := canvas.CreateNewCanvas(canvasWidth, canvasWidth)
+	c.SetFillColor(canvas.MakeColor(0, 0, 0))
+	c.ClearRect(0, 0, canvasWidth, canvasWidth)
+	c.Fill()
+
+	scale := float64(canvasWidth) / ECMwidth
+	sliceZ := SliceDepth * ECMwidth
+
+	for _, f := range e.fibres {
+		endpoint1, endpoint2 := f.GetEndpoints()
+		brightness := 1.0
+		if mode == "slice" {
+			// skip fibres that don't cross the slab
+			if math.Max(endpoint1.z, endpoint2.z) < sliceZ-SliceThickness/2 || math.Min(endpoint1.z, endpoint2.z) > sliceZ+SliceThickness/2 {
+				continue
+			}
+		} else {
+			brightness = 0.3 + 0.7*f.position.z/ECMwidth // deeper fibres are dimmer
+		}
+		c.SetLineWidth(f.width * scale)
+		c.SetStrokeColor(canvas.MakeColor(uint8(100*brightness), uint8(100*brightness), uint8(200*brightness)))
+		c.MoveTo(endpoint1.x*scale, endpoint1.y*scale)
+		c.LineTo(endpoint2.x*scale, endpoint2.y*scale)
+		c.Stroke()
+	}
+
+	for _, cell := range e.cells {
+		r := cell.radius
+		if mode == "slice" {
+			dz := math.Abs(cell.position.z - sliceZ)
+			if dz >= cell.radius {
+				continue
+			}
+			r = math.Sqrt(cell.radius*cell.radius - dz*dz) // cross-section of the sphere
+		}
+		c.SetFillColor(canvas.MakeColor(200, 150, 200))
+		c.Circle(cell.position.x*scale, cell.position.y*scale, r*scale)
+		c.Fill()
+	}
+
+	return c.GetImage()
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"image"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestRenderInOrder draws frames that take random times and checks that they are emitted in order and that no
+// more than 2*workers frames are held at once.
+func TestRenderInOrder(t *testing.T) {
+	type test struct {
+		n, workers int
+	}
+
+	tests := make([]test, 4)
+	tests[0] = test{40, 4}
+	tests[1] = test{40, 1}
+	tests[2] = test{3, 8}
+	tests[3] = test{0, 2}
+
+	for i, test := range tests {
+		random := rand.New(rand.NewSource(int64(i)))
+		delays := make([]time.Duration, test.n)
+		for j := range delays {
+			delays[j] = time.Duration(random.Intn(2000)) * time.Microsecond
+		}
+
+		var lock sync.Mutex
+		held, maxHeld := 0, 0
+		var emitted []int
+		err := RenderInOrder(context.Background(), test.n, test.workers, func(j int) image.Image {
+			lock.Lock()
+			held++
+			if held > maxHeld {
+				maxHeld = held
+			}
+			lock.Unlock()
+			time.Sleep(delays[j])
+			// the frame's index is stored in its width
+			return image.NewGray(image.Rect(0, 0, j+1, 1))
+		}, func(img image.Image) error {
+			lock.Lock()
+			held--
+			lock.Unlock()
+			emitted = append(emitted, img.Bounds().Dx()-1)
+			return nil
+		})
+
+		if err != nil {
+			t.Errorf("Error! For input test dataset %d, your code returns the error %v.", i, err)
+		}
+		if len(emitted) != test.n {
+			t.Errorf("Error! For input test dataset %d, your code emits %d frames, and the correct number is %d.", i, len(emitted), test.n)
+		}
+		for j, index := range emitted {
+			if index != j {
+				t.Errorf("Error! For input test dataset %d, your code emits the frames in the order %v, and the correct order is 0, 1, 2, ...", i, emitted)
+				break
+			}
+		}
+		if maxHeld > 2*test.workers {
+			t.Errorf("Error! For input test dataset %d, your code holds %d frames at once, and the most it should hold is %d.", i, maxHeld, 2*test.workers)
+		}
+	}
+}
+
+// TestRenderInOrderStopped checks that no frames are emitted after emit fails or ctx ends, and that drawing
+// stops soon after.
+func TestRenderInOrderStopped(t *testing.T) {
+	const n, workers = 1000, 4
+	failure := errors.New("disk full")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	for i, stop := range []func(numEmitted int) error{
+		func(numEmitted int) error {
+			if numEmitted == 10 {
+				return failure
+			}
+			return nil
+		},
+		func(numEmitted int) error {
+			if numEmitted == 10 {
+				cancel()
+			}
+			return nil
+		},
+	} {
+		var lock sync.Mutex
+		numRendered, numEmitted := 0, 0
+		err := RenderInOrder(ctx, n, workers, func(j int) image.Image {
+			lock.Lock()
+			numRendered++
+			lock.Unlock()
+			return image.NewGray(image.Rect(0, 0, 1, 1))
+		}, func(img image.Image) error {
+			numEmitted++
+			return stop(numEmitted)
+		})
+
+		if err != failure && err != context.Canceled {
+			t.Errorf("Error! For input test dataset %d, your code returns %v, and the correct error is the one that stopped it.", i, err)
+		}
+		if numEmitted != 10 {
+			t.Errorf("Error! For input test dataset %d, your code emits %d frames, and the correct number is 10.", i, numEmitted)
+		}
+		if numRendered > 10+2*workers {
+			t.Errorf("Error! For input test dataset %d, your code draws %d frames, and it should stop drawing after about 10.", i, numRendered)
+		}
+	}
+}
+
+// TestDrawECMFrequency checks that every frequency-th frame is drawn, starting with the first.
+func TestDrawECMFrequency(t *testing.T) {
+	type test struct {
+		numTimePoints, frequency, answer int
+	}
+
+	tests := make([]test, 4)
+	tests[0] = test{7, 1, 7}
+	tests[1] = test{7, 3, 3}
+	tests[2] = test{7, 6, 2}
+	tests[3] = test{7, 10, 1}
+
+	SeedRandom(1)
+	ecm := InitializeECM(5, 2, 100, 10, 0.5)
+	for i, test := range tests {
+		timePoints := make([]*ECM, test.numTimePoints)
+		for j := range timePoints {
+			timePoints[j] = ecm
+		}
+		numImages := 0
+		outcome, err := DrawECM(context.Background(), timePoints, 20, test.frequency, 1, 0.5, func(img image.Image) error {
+			numImages++
+			return nil
+		})
+		if err != nil || outcome != test.answer || numImages != test.answer {
+			t.Errorf("Error! For input test dataset %d, your code draws %d frames (and says %d, with the error %v), and the correct number is %d.", i, numImages, outcome, err, test.answer)
+		}
+	}
+}
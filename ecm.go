@@ -15,22 +15,36 @@ type ECM struct {
 func (e *ECM) UpdateECM(time, timePoint float64, positionArray [][]float64) (float64, *ECM, [][]float64) {
 	// range over all cells on ECM
 	newECM := e.CopyECM() // makes a deep copy of the ECM
-	var thresh float64    // NEED TO GIVE THIS AN ACTUAL VALUE. Threshold should probably be length of fibre/2.
-	thresh = 40.0
+	thresh := InteractionRadius
 
 	for _, fibre := range newECM.fibres {
-		nearestCell := fibre.FindNearestCell(newECM.cells) // returns a nearest cell
-		if ComputeDistance(nearestCell.position, fibre.position) <= thresh {
-			fibre.UpdateFibre(nearestCell, ECMstiffness)
+		nearbyCells := fibre.FindCellsWithin(thresh, newECM.cells) // every cell close enough to pull on the fibre
+		// fibres with no cells pulling on them recoil towards rest
+		if len(nearbyCells) == 0 {
+			fibre.Recoil(time, ECMstiffness)
+			continue
 		}
+		fibre.UpdateFibreFromCells(nearbyCells, ECMstiffness)
 	}
 
+	// crosslinks spread the rotations caused by cells through the fibre network
+	newECM.RelaxNetwork()
+
 	timePoint += time // update time point by time step
 
-	for i, cell := range newECM.cells {
+	for _, cell := range newECM.cells {
+		cell.UpdateCell(newECM.fibres, thresh, time)
+	}
+
+	// cells degrade and deposit fibres along the way
+	newECM.RemodelMatrix(time)
 
-		cell.UpdateCell(e.cells[i], newECM.fibres, thresh, time)
+	// cells divide or die after they have moved
+	newECM.cells = ProliferateCells(newECM.cells, newECM.lineage, time, timePoint)
 
+	// the positions are recorded after proliferation, so a daughter's track starts at the time point it was born
+	// and the tracks of cells that died or divided end at the time point before
+	for _, cell := range newECM.cells {
 		// add position and time values to array as string
 		newValues := make([]float64, 4)
 		newValues[0] = float64(timePoint)
@@ -38,8 +52,8 @@ func (e *ECM) UpdateECM(time, timePoint float64, positionArray [][]float64) (flo
 		newValues[2] = cell.position.x
 		newValues[3] = cell.position.y
 		positionArray = append(positionArray, newValues)
-
 	}
+
 	return timePoint, newECM, positionArray
 }
 
@@ -55,6 +69,9 @@ func (e *ECM) CopyECM() *ECM {
 
 	newECM.fibres = make([]*Fibre, totalFibres)
 	newECM.cells = make([]*Cell, totalCells)
+	newECM.lineage = e.lineage
+	newECM.crosslinks = make([]Crosslink, len(e.crosslinks))
+	copy(newECM.crosslinks, e.crosslinks)
 
 	// For fibres
 	for i := 0; i < totalFibres; i++ {
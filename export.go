@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"gifhelper"
+	"html"
+	"image"
+	"image/png"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sync"
+)
+
+// OutputFormats lists the formats the animation can be exported as.
+var OutputFormats = []string{"gif", "png", "mp4", "webm"}
+
+// IsOutputFormat: Reports whether format is one of OutputFormats.
+func IsOutputFormat(format string) bool {
+	for _, name := range OutputFormats {
+		if name == format {
+			return true
+		}
+	}
+	return false
+}
+
+// AnimationExporter writes an animation one frame at a time as the frames are drawn.
+// "gif" writes <name>.out.gif with gifhelper. "png" writes numbered frames to <name>_frames/.
+// "mp4" and "webm" write the numbered frames and then encode them with VideoEncoder. If the encoder
+// can't be found or fails, a GIF is written instead so the run still has an animation, unless the encoder
+// failed because ctx ended: a stopped run gets no animation.
+// PNG and video frames are written to disk as they arrive, so only a few of them are in memory at once.
+// gifhelper encodes a GIF from the whole list of frames, so the frames of a GIF are kept until Finish.
+type AnimationExporter struct {
+	format    string
+	name      string // path of the output without extension, e.g. "gifs/CellMigration"
+	frameDir  string
+	numFrames int
+	images    []image.Image  // the frames of a GIF
+	slots     chan struct{}  // one for each frame being written
+	writing   sync.WaitGroup // the frames being written
+	lock      sync.Mutex
+	err       error // the first error writing a frame
+}
+
+// NewAnimationExporter: Creates an exporter writing in the given format, one of OutputFormats, to name.
+// Frames left in <name>_frames/ by an earlier, longer run are removed.
+func NewAnimationExporter(format, name string) *AnimationExporter {
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		panic("Error creating animation directory: " + err.Error())
+	}
+	exporter := &AnimationExporter{format: format, name: name, frameDir: name + "_frames"}
+	switch format {
+	case "gif":
+		return exporter
+	case "png", "mp4", "webm":
+	default:
+		panic("Error: unknown output format " + format + ".")
+	}
+	if err := os.RemoveAll(exporter.frameDir); err != nil {
+		panic("Error writing frames: " + err.Error())
+	}
+	if err := os.MkdirAll(exporter.frameDir, 0755); err != nil {
+		panic("Error writing frames: " + err.Error())
+	}
+	exporter.slots = make(chan struct{}, NumRenderWorkers())
+	return exporter
+}
+
+// AddFrame: Adds the next frame of the animation. PNG encoding is slow, so up to NumRenderWorkers frames are
+// written at once; AddFrame waits while that many are being written.
+// Output: (error) the first error hit writing a frame so far, if any.
+func (exporter *AnimationExporter) AddFrame(img image.Image) error {
+	index := exporter.numFrames
+	exporter.numFrames++
+	if exporter.format == "gif" {
+		exporter.images = append(exporter.images, img)
+		return nil
+	}
+
+	exporter.slots <- struct{}{}
+	exporter.writing.Add(1)
+	go func() {
+		defer exporter.writing.Done()
+		if err := WritePNG(img, filepath.Join(exporter.frameDir, FrameName(index))); err != nil {
+			exporter.lock.Lock()
+			if exporter.err == nil {
+				exporter.err = err
+			}
+			exporter.lock.Unlock()
+		}
+		<-exporter.slots
+	}()
+
+	exporter.lock.Lock()
+	defer exporter.lock.Unlock()
+	return exporter.err
+}
+
+// Wait: Waits for the frames being written to be done.
+// Output: (error) the first error hit writing a frame, if any.
+func (exporter *AnimationExporter) Wait() error {
+	exporter.writing.Wait()
+	return exporter.err
+}
+
+// Finish: Writes out the animation once every frame has been added.
+// Input: ctx (context.Context) stops the video encoder if it ends.
+// Output: (string) path of the file to show for the animation. For "png" this is the last frame. "" if ctx ended
+// while a video was being encoded.
+func (exporter *AnimationExporter) Finish(ctx context.Context) string {
+	if err := exporter.Wait(); err != nil {
+		panic("Error writing frames: " + err.Error())
+	}
+	switch exporter.format {
+	case "gif":
+		gifhelper.ImagesToGIF(exporter.images, exporter.name)
+		return exporter.name + ".out.gif"
+	case "png":
+		return filepath.Join(exporter.frameDir, FrameName(exporter.numFrames-1))
+	}
+
+	videoFile := exporter.name + "." + exporter.format
+	if err := EncodeVideo(ctx, exporter.frameDir, videoFile, exporter.format, VideoFrameRate); err != nil {
+		if ctx.Err() != nil {
+			fmt.Println("Stopped encoding " + exporter.format + ". The frames are in " + exporter.frameDir + ".")
+			return ""
+		}
+		fmt.Println("Could not encode " + exporter.format + " (" + err.Error() + "). Writing a GIF instead.")
+		images, err := ReadFrames(exporter.frameDir, exporter.numFrames)
+		if err != nil {
+			panic("Error reading frames: " + err.Error())
+		}
+		gifhelper.ImagesToGIF(images, exporter.name)
+		return exporter.name + ".out.gif"
+	}
+	return videoFile
+}
+
+// FrameName: Returns the file name of the frame with the given index. Names are zero padded so they sort in order.
+func FrameName(index int) string {
+	return fmt.Sprintf("frame_%05d.png", index)
+}
+
+// ReadFrames: Reads back the first numFrames numbered PNG frames in dir.
+func ReadFrames(dir string, numFrames int) ([]image.Image, error) {
+	images := make([]image.Image, numFrames)
+	for i := range images {
+		file, err := os.Open(filepath.Join(dir, FrameName(i)))
+		if err != nil {
+			return nil, err
+		}
+		images[i], err = png.Decode(file)
+		file.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return images, nil
+}
+
+// WritePNG: Encodes one image as a PNG file.
+func WritePNG(img image.Image, filename string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(file, img); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// EncodeVideo: Encodes the numbered frames in frameDir into an mp4 (H.264) or webm (VP9) video
+// using the VideoEncoder binary (ffmpeg by default). Returns an error if the encoder isn't installed.
+// The encoder is killed if ctx ends.
+func EncodeVideo(ctx context.Context, frameDir, videoFile, format string, frameRate int) error {
+	encoder, err := exec.LookPath(VideoEncoder)
+	if err != nil {
+		return err
+	}
+	args := []string{"-y", "-loglevel", "error",
+		"-framerate", fmt.Sprint(frameRate),
+		"-i", filepath.Join(frameDir, "frame_%05d.png"),
+		// both codecs need even frame sizes
+		"-vf", "scale=trunc(iw/2)*2:trunc(ih/2)*2"}
+	switch format {
+	case "mp4":
+		args = append(args, "-c:v", "libx264", "-pix_fmt", "yuv420p")
+	case "webm":
+		args = append(args, "-c:v", "libvpx-vp9", "-b:v", "0", "-crf", "32")
+	default:
+		return fmt.Errorf("unknown video format %q", format)
+	}
+	args = append(args, videoFile)
+
+	cmd := exec.CommandContext(ctx, encoder, args...)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%v: %s", err, output)
+	}
+	return nil
+}
+
+// AnimationHTML: Returns the HTML element that shows an exported animation on the results page.
+// Input: src (string) the URL of the animation, e.g. "/runs/<id>/gifs/CellMigration.out.gif".
+func AnimationHTML(src string) string {
+	src = html.EscapeString(src)
+	switch path.Ext(src) {
+	case ".mp4", ".webm":
+		return fmt.Sprintf("<video src='%s' class='rounded' controls autoplay loop style='width:600px;height:auto;'></video>", src)
+	}
+	return fmt.Sprintf("<img src='%s' class='rounded' alt='skew' style='width:600px;height:auto;'>", src)
+}
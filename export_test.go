@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+// TestExportAnimationCancelled checks that a video that can't be encoded because the run was stopped doesn't
+// fall back to a GIF.
+func TestExportAnimationCancelled(t *testing.T) {
+	encoder := VideoEncoder
+	defer func() { VideoEncoder = encoder }()
+	VideoEncoder = "no-such-encoder"
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	name := filepath.Join(t.TempDir(), "gifs", "CellMigration")
+	exporter := NewAnimationExporter("mp4", name)
+	for i := 0; i < 2; i++ {
+		if err := exporter.AddFrame(image.NewRGBA(image.Rect(0, 0, 4, 4))); err != nil {
+			t.Fatalf("Error! Your code returns the error %v.", err)
+		}
+	}
+	if outcome := exporter.Finish(ctx); outcome != "" {
+		t.Errorf("Error! Your code gives the animation %q, and the correct answer is none.", outcome)
+	}
+	if _, err := os.Stat(name + ".out.gif"); err == nil {
+		t.Errorf("Error! Your code writes a GIF for a stopped run, and the correct answer is no file.")
+	}
+}
+
+func TestIsOutputFormat(t *testing.T) {
+	type test struct {
+		format string
+		answer bool
+	}
+
+	tests := make([]test, 7)
+	tests[0] = test{"gif", true}
+	tests[1] = test{"png", true}
+	tests[2] = test{"mp4", true}
+	tests[3] = test{"webm", true}
+	tests[4] = test{"GIF", false}
+	tests[5] = test{"avi", false}
+	tests[6] = test{"", false}
+
+	for i, test := range tests {
+		if outcome := IsOutputFormat(test.format); outcome != test.answer {
+			t.Errorf("Error! For input test dataset %d, your code gives %v, and the correct answer is %v.", i, outcome, test.answer)
+		}
+	}
+}
+
+func TestFrameName(t *testing.T) {
+	type test struct {
+		index  int
+		answer string
+	}
+
+	tests := make([]test, 4)
+	tests[0] = test{0, "frame_00000.png"}
+	tests[1] = test{7, "frame_00007.png"}
+	tests[2] = test{1234, "frame_01234.png"}
+	tests[3] = test{99999, "frame_99999.png"}
+
+	for i, test := range tests {
+		if outcome := FrameName(test.index); outcome != test.answer {
+			t.Errorf("Error! For input test dataset %d, your code gives %q, and the correct name is %q.", i, outcome, test.answer)
+		}
+	}
+	// the names sort in the same order as the frames, which is how the video encoder reads them
+	names := []string{FrameName(10), FrameName(9), FrameName(100), FrameName(0)}
+	sort.Strings(names)
+	if names[0] != FrameName(0) || names[1] != FrameName(9) || names[2] != FrameName(10) || names[3] != FrameName(100) {
+		t.Errorf("Error! Your frame names sort as %v, and the correct order is by frame.", names)
+	}
+}
+
+// TestAnimationExporterFrames checks that each frame is written to its own numbered file, in order, and that
+// frames left over from an earlier, longer run are removed.
+func TestAnimationExporterFrames(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "CellMigration")
+	dir := name + "_frames"
+	exporter := NewAnimationExporter("png", name)
+	for i := 0; i < 15; i++ {
+		if err := exporter.AddFrame(image.NewGray(image.Rect(0, 0, 2, 2))); err != nil {
+			t.Fatalf("Error! Your code returns the error %v.", err)
+		}
+	}
+	exporter.Finish(context.Background())
+
+	const numFrames = 12
+	exporter = NewAnimationExporter("png", name)
+	for i := 0; i < numFrames; i++ {
+		img := image.NewGray(image.Rect(0, 0, 3, 2))
+		img.SetGray(1, 1, color.Gray{Y: uint8(10 * i)})
+		if err := exporter.AddFrame(img); err != nil {
+			t.Fatalf("Error! Your code returns the error %v.", err)
+		}
+	}
+	if outcome := exporter.Finish(context.Background()); outcome != filepath.Join(dir, FrameName(numFrames-1)) {
+		t.Errorf("Error! Your code gives the animation %q, and the correct answer is the last frame.", outcome)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.png"))
+	if len(files) != numFrames {
+		t.Fatalf("Error! Your code writes %d frames, and the correct number is %d.", len(files), numFrames)
+	}
+	sort.Strings(files)
+	for i, file := range files {
+		reader, err := os.Open(file)
+		if err != nil {
+			t.Fatalf("Error! Your code doesn't write %s.", file)
+		}
+		img, err := png.Decode(reader)
+		reader.Close()
+		if err != nil {
+			t.Fatalf("Error! Your code writes %s, which isn't a PNG: %v.", file, err)
+		}
+		if gray := color.GrayModel.Convert(img.At(1, 1)).(color.Gray); filepath.Base(file) != FrameName(i) || gray.Y != uint8(10*i) || img.Bounds().Dx() != 3 {
+			t.Errorf("Error! Your code writes %s with the pixel %d, and the correct file is %s with the pixel %d.", file, gray.Y, FrameName(i), 10*i)
+		}
+	}
+}
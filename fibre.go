@@ -21,6 +21,53 @@ func (fibre *Fibre) UpdateFibre(cell *Cell, stiffness float64) {
 	fibre.UpdatePosition()
 }
 
+// UpdateFibreFromCells: Rotates a fibre under the combined influence of every cell in a slice.
+// Each cell acts on its own copy of the fibre as in UpdateFibre. The new fibre is the average of those
+// copies: the directions are averaged as undirected lines and the centers are averaged as points.
+// With a single cell this is the same as UpdateFibre.
+// Input: cells ([]*Cell) the cells close enough to act on the fibre, stiffness (float64) matrix stiffness.
+func (fibre *Fibre) UpdateFibreFromCells(cells []*Cell, stiffness float64) {
+	if len(cells) == 0 {
+		return
+	}
+	if len(cells) == 1 {
+		fibre.UpdateFibre(cells[0], stiffness)
+		return
+	}
+	reference := fibre.direction
+	var direction, position OrderedPair
+	for _, cell := range cells {
+		candidate := fibre.CopyFibre()
+		candidate.UpdateFibre(cell, stiffness)
+		aligned := AlignWith(reference, candidate.direction)
+		direction.x += aligned.x
+		direction.y += aligned.y
+		position.x += candidate.position.x / float64(len(cells))
+		position.y += candidate.position.y / float64(len(cells))
+	}
+	if direction.Magnitude() == 0 { // the cells pull in exactly opposite ways
+		return
+	}
+	direction.Normalize()
+	fibre.direction = direction
+	fibre.position = position
+	fibre.ResetPivot()
+}
+
+// FindCellsWithin: Finds all cells whose centers are within some distance of the fibre,
+// measuring to the closest point on the fibre.
+// Input: radius (float64) the interaction radius, cells ([]*Cell) all cells on the ECM.
+// Output: ([]*Cell) the cells within the radius.
+func (fibre *Fibre) FindCellsWithin(radius float64, cells []*Cell) []*Cell {
+	var nearbyCells []*Cell
+	for _, cell := range cells {
+		if fibre.DistanceToPoint(cell.position) <= radius {
+			nearbyCells = append(nearbyCells, cell)
+		}
+	}
+	return nearbyCells
+}
+
 // FindPivot:  Determines which end of the fibre will be considered the pivot with regards to a cell.
 // The pivot is the end of the fibre that is furthest away from the center of the cell.
 // Additionally sets the direction vector such that pivot + direction vector = center of fibre.
@@ -38,6 +85,7 @@ func (fibre *Fibre) FindPivot(cell *Cell) {
 		fibre.pivot.y = endpoint1.y
 		fibre.direction.x *= -1
 		fibre.direction.y *= -1
+		fibre.flipped = !fibre.flipped
 	} else {
 		// If endpoint 2 is the pivot
 		fibre.pivot.x = endpoint2.x
@@ -52,44 +100,40 @@ func (fibre *Fibre) FindPivot(cell *Cell) {
 // Output:
 // (float64) The angle that the fibre needs to be rotated about its pivot.
 func (f *Fibre) ComputePhi(cell *Cell, S float64) float64 {
-	// phi (angle of rotation) = theta (angle of cell from pivot) - arcsin[(1 - 0.1*integrins*(1-stiffness)*perpendicular distance D) / hypotenuse]
+	// phi (angle of rotation) = theta (angle of cell from pivot) - arcsin[(1 - a*integrins*(1-stiffness)*perpendicular distance D) / hypotenuse]
+	// where a is AlignFactorCoefficient, 0.1 by default
 
 	D := f.FindPerpendicularDistance(cell)       // perpendicular distance from cell to fibre
 	d := ComputeDistance(f.pivot, cell.position) // distance from pivot point to cell
 	I := cell.integrin                           // Percentage of integrins expressed by the cell
 	theta := f.FindTheta(D, d)                   // Angle between fibre and line from pivot to cell.
+	if d == 0 {                                  // the cell sits on the pivot, so there is no direction to pull in
+		return 0
+	}
 
-	alignFactor := (1 - 0.1*I*(1-S))
-	phi := (theta - math.Asin(alignFactor*D/d))
+	alignFactor := (1 - AlignFactorCoefficient*I*(1-S))
+	// a soft matrix makes alignFactor negative (or below -1), so the ratio is clamped rather than left to give NaN
+	phi := (theta - ClampedAsin(alignFactor*D/d))
 	rotationSign := f.DetermineRotationDirection(cell)
 	return float64((rotationSign)) * phi
 }
 
-// Determines whether the fibre needs to be rotated in the clockwise (negative) or counter-clockwise (positive) direction
-// to align the fibre closer to the center of the cell acting on the fibre. Returns 1 if rotation should be counter-clockwise,
-// Otherwise returns 0.
+// DetermineRotationDirection: Determines whether the fibre needs to be rotated in the clockwise (negative) or
+// counter-clockwise (positive) direction to align the fibre closer to the center of the cell acting on the fibre.
+// The side of the fibre the cell is on is the sign of the cross product of the fibre's direction (which FindPivot
+// points from the pivot to the non-pivot end) with the line from the pivot to the cell. Unlike comparing y values
+// on the line through the pivot and the cell, this works when that line is vertical.
 // Input:
 // f (*Fibre) Pointer to the fibre being acted on
 // cell (*Cell) Pointer to the cell acting on the fibre
 // Output:
-// (int) -1 if rotation should be clockwise, 1 if rotation should be clockwise.
+// (int) -1 if rotation should be clockwise, 1 if rotation should be counter-clockwise (or the cell is on the fibre's line).
 func (f *Fibre) DetermineRotationDirection(cell *Cell) int {
-	nonPivot := f.GetNonPivot() // non-pivot end of the fibre
-
-	m, b := FindLine(f.pivot, cell.position) // y = mx + b of line between pivot and cell center
-	y := EvaluateLineAtX(nonPivot.x, m, b)
-
-	rotation := 1
-	if f.pivot.x < cell.position.x { // If pivot is to the left of cell.
-		if nonPivot.y > y { // Non-Pivot end is above the line from pivot to cell
-			rotation *= -1
-		}
-	} else { // If Pivot is to the right of the cell.
-		if nonPivot.y < y { // Non-Pivot end is below the line from pivot to cell
-			rotation *= -1
-		}
+	toCell := OrderedPair{cell.position.x - f.pivot.x, cell.position.y - f.pivot.y}
+	if CrossProduct2D(f.direction, toCell) < 0 { // the cell is clockwise of the fibre
+		return -1
 	}
-	return rotation
+	return 1
 }
 
 // UpdateDirection: Updates the direction of a fibre
@@ -106,9 +150,34 @@ func (f *Fibre) UpdateDirection(phi float64) {
 // Input: fibre (*Fibre) location of the center of the fibre.
 // Output: None.
 func (f *Fibre) UpdatePosition() {
-	magnitude := math.Sqrt(f.direction.x*f.direction.x + f.direction.y*f.direction.y)
-	f.position.x = f.pivot.x + f.direction.x*0.5*f.length/magnitude
-	f.position.y = f.pivot.y + f.direction.y*0.5*f.length/magnitude
+	direction := UnitVector(f.direction)
+	f.position.x = f.pivot.x + direction.x*0.5*f.length
+	f.position.y = f.pivot.y + direction.y*0.5*f.length
+}
+
+// ResetPivot: Moves the pivot back onto the end of the fibre after the fibre has been moved or resized
+// by something other than UpdateFibre, keeping pivot + direction = center of fibre.
+func (f *Fibre) ResetPivot() {
+	direction := UnitVector(f.direction)
+	f.pivot.x = f.position.x - direction.x*0.5*f.length
+	f.pivot.y = f.position.y - direction.y*0.5*f.length
+}
+
+// MaterialDirection: Returns the unit direction of the fibre that does not change sign when FindPivot
+// swaps the pivot end. Positions measured along it always refer to the same point on the fibre.
+func (f *Fibre) MaterialDirection() OrderedPair {
+	direction := f.direction
+	direction.Normalize()
+	if f.flipped {
+		direction = MultiplyVectorByConstant2D(direction, -1.0)
+	}
+	return direction
+}
+
+// MaterialPoint: Returns the point that is offset micrometres from the center of the fibre along MaterialDirection.
+func (f *Fibre) MaterialPoint(offset float64) OrderedPair {
+	direction := f.MaterialDirection()
+	return OrderedPair{f.position.x + offset*direction.x, f.position.y + offset*direction.y}
 }
 
 // FindPerpendicularDistance: Finds shortest distance from the center of a cell to a fibre.
@@ -118,22 +187,41 @@ func (f *Fibre) UpdatePosition() {
 // Output:
 // (float64) The distance from the center of the cell to the fibre.
 func (fibre *Fibre) FindPerpendicularDistance(cell *Cell) float64 {
-	// need to find coordinates of pivot point
-	A, B, C := FindHomogenousLine(fibre.position, fibre.pivot)
-	numerator := math.Abs(A*cell.position.x + B*cell.position.y + C)
-	denominator := math.Sqrt(A*A + B*B)
-	return numerator / denominator
+	// |direction x (cell - pivot)| for a unit direction. Unlike the line through the pivot and the center,
+	// the direction is still defined when the fibre has no length.
+	direction := UnitVector(fibre.direction)
+	if direction.Magnitude() == 0 { // no direction, so no line: measure to the pivot
+		return ComputeDistance(fibre.pivot, cell.position)
+	}
+	toCell := OrderedPair{cell.position.x - fibre.pivot.x, cell.position.y - fibre.pivot.y}
+	return math.Abs(CrossProduct2D(direction, toCell))
 }
 
-// FindNearestCell: Finds the cell nearest to the center of a Fibre object.
+// DistanceToPoint: Finds the shortest distance from a point to the fibre, treating the fibre as a line segment.
+// Input: p (OrderedPair) the point.
+// Output: (float64) The distance from p to the closest point on the fibre.
+func (fibre *Fibre) DistanceToPoint(p OrderedPair) float64 {
+	endpoint1, endpoint2 := fibre.GetEndpoints()
+	segment := OrderedPair{endpoint2.x - endpoint1.x, endpoint2.y - endpoint1.y}
+	lengthSquared := DotProduct2D(segment, segment)
+	if lengthSquared == 0 {
+		return ComputeDistance(p, endpoint1)
+	}
+	// position of the closest point along the segment, clamped to the ends of the fibre
+	t := DotProduct2D(OrderedPair{p.x - endpoint1.x, p.y - endpoint1.y}, segment) / lengthSquared
+	t = math.Max(0, math.Min(1, t))
+	closest := OrderedPair{endpoint1.x + t*segment.x, endpoint1.y + t*segment.y}
+	return ComputeDistance(p, closest)
+}
+
+// FindNearestCell: Finds the cell nearest to a Fibre object, measuring to the closest point on the fibre.
 // Input: fibre (*Fibre) a pointer to the Fibre object.
 // cells ([]*Cell) A slice of pointers to cell objects. This slice contains all the cells in the ECM.
 func (fibre *Fibre) FindNearestCell(cells []*Cell) *Cell {
-	// Placeholder function.
 	nearestCell := cells[0]
-	currentDistance := fibre.FindPerpendicularDistance(cells[0])
+	currentDistance := fibre.DistanceToPoint(cells[0].position)
 	for _, cell := range cells {
-		newDistance := fibre.FindPerpendicularDistance(cell)
+		newDistance := fibre.DistanceToPoint(cell.position)
 		if newDistance < currentDistance {
 			nearestCell = cell
 			currentDistance = newDistance
@@ -147,12 +235,12 @@ func (fibre *Fibre) FindNearestCell(cells []*Cell) *Cell {
 // Output: endpoint1, endpoint2 (OrderedPair): The two ends of the Fibre object.
 func (fibre *Fibre) GetEndpoints() (OrderedPair, OrderedPair) {
 	var endpoint1, endpoint2 OrderedPair
-	magnitude := fibre.direction.Magnitude()
+	direction := UnitVector(fibre.direction) // both ends are at the center if the direction is zero
 	//calculate the ends of the fibres
-	endpoint1.x = fibre.position.x + fibre.direction.x*0.5*fibre.length/magnitude
-	endpoint1.y = fibre.position.y + fibre.direction.y*0.5*fibre.length/magnitude
-	endpoint2.x = fibre.position.x - fibre.direction.x*0.5*fibre.length/magnitude
-	endpoint2.y = fibre.position.y - fibre.direction.y*0.5*fibre.length/magnitude
+	endpoint1.x = fibre.position.x + direction.x*0.5*fibre.length
+	endpoint1.y = fibre.position.y + direction.y*0.5*fibre.length
+	endpoint2.x = fibre.position.x - direction.x*0.5*fibre.length
+	endpoint2.y = fibre.position.y - direction.y*0.5*fibre.length
 
 	return endpoint1, endpoint2
 }
@@ -179,13 +267,13 @@ func (fibre *Fibre) GetNonPivot() OrderedPair {
 // FindTheta: Find the angle between the line describing the fibre and the line between the pivot and the cell.
 // Input: Opposite (float64) The length of the opposite side of the triangle
 // hypotenuse (float64) The length of the hypotenuse of the triangle
-// Output: (float64) the angle (in radians) calculated by arcsin(opposite/hypotenuse).
+// Output: (float64) the angle (in radians) calculated by arcsin(opposite/hypotenuse), or 0 if the hypotenuse is 0.
+// Rounding can make opposite slightly longer than the hypotenuse, so the ratio is clamped to 1.
 func (f *Fibre) FindTheta(opposite, hypotenuse float64) float64 {
-	theta := math.Asin(opposite / hypotenuse) // theta = arcsin(opposite / hypotenuse)
-	if theta != theta {
-		panic("Error in FindTheta: arcsin returned NaN.")
+	if hypotenuse == 0 {
+		return 0
 	}
-	return theta
+	return ClampedAsin(opposite / hypotenuse) // theta = arcsin(opposite / hypotenuse)
 }
 
 // CopyFibre: Returns a pointer to a copy of a Fibre object.
@@ -198,19 +286,8 @@ func (f *Fibre) CopyFibre() *Fibre {
 	newFibre.position = f.position
 	newFibre.width = f.width
 	newFibre.pivot = f.pivot
+	newFibre.flipped = f.flipped
+	newFibre.restDirection = f.restDirection
+	newFibre.restPosition = f.restPosition
 	return &newFibre
 }
-
-// Finds the hypotenuse between the center of the cell and the pivot point of the fibre.
-func (fibre *Fibre) FindHypotenuse(currCell *Cell) float64 {
-	// Takes the position of the fibre
-	posFibre := fibre.position
-	var fibreCoord OrderedPair
-
-	fibreCoord.x = posFibre.x + (fibre.length)/2
-	fibreCoord.y = posFibre.y + (fibre.length)/2
-
-	// Get the hypotenuse
-	hypotenuse := ComputeDistance(fibreCoord, currCell.position)
-	return hypotenuse
-}
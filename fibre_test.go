@@ -0,0 +1,200 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestDetermineRotationDirection(t *testing.T) {
+	type test struct {
+		pivot, direction, cell OrderedPair
+		answer                 int
+	}
+
+	tests := make([]test, 6)
+	// the pivot is to the left of the cell and the fibre points above it, so it turns clockwise
+	tests[0] = test{OrderedPair{0, 0}, OrderedPair{1, 1}, OrderedPair{10, 0}, -1}
+	tests[1] = test{OrderedPair{0, 0}, OrderedPair{1, -1}, OrderedPair{10, 0}, 1}
+	// vertical lines from the pivot to the cell, which used to give an infinite slope
+	tests[2] = test{OrderedPair{0, 0}, OrderedPair{1, 1}, OrderedPair{0, 10}, 1}
+	tests[3] = test{OrderedPair{0, 0}, OrderedPair{-1, 1}, OrderedPair{0, 10}, -1}
+	tests[4] = test{OrderedPair{5, 10}, OrderedPair{1, -1}, OrderedPair{5, 0}, -1}
+	// a vertical fibre with the cell on its line
+	tests[5] = test{OrderedPair{5, 0}, OrderedPair{0, 1}, OrderedPair{5, 20}, 1}
+
+	for i, test := range tests {
+		fibre := Fibre{length: 10, pivot: test.pivot, direction: UnitVector(test.direction)}
+		fibre.UpdatePosition()
+		cell := Cell{position: test.cell}
+		outcome := fibre.DetermineRotationDirection(&cell)
+
+		if outcome != test.answer {
+			t.Errorf("Error! For input test dataset %d, your code gives %d, and the correct rotation direction is %d.", i, outcome, test.answer)
+		}
+	}
+}
+
+// TestUpdateFibreProperties checks random fibres and cells, including vertical and horizontal fibres, zero
+// directions and lengths, and cells on the pivot, on the fibre's line or directly above or below an end.
+// A fibre must never get a NaN or infinite coordinate, must keep its length and, while the alignment factor
+// is between 0 and 1, must turn towards the cell without overshooting it.
+func TestUpdateFibreProperties(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	directions := []OrderedPair{{0, 1}, {0, -1}, {1, 0}, {-1, 0}, {0, 0}, {1e-300, 0}}
+
+	for i := 0; i < 20000; i++ {
+		var fibre Fibre
+		fibre.length = []float64{0, 1e-9, 75, random.Float64() * 200}[random.Intn(4)]
+		fibre.position = OrderedPair{random.Float64() * 100, random.Float64() * 100}
+		if random.Intn(2) == 0 {
+			fibre.direction = directions[random.Intn(len(directions))]
+		} else {
+			angle := random.Float64() * 2 * math.Pi
+			fibre.direction = OrderedPair{math.Cos(angle), math.Sin(angle)}
+		}
+		fibre.ResetPivot()
+		endpoint1, endpoint2 := fibre.GetEndpoints()
+
+		var cell Cell
+		cell.integrin = random.Float64() * 100
+		switch random.Intn(5) {
+		case 0:
+			cell.position = OrderedPair{random.Float64() * 100, random.Float64() * 100}
+		case 1: // directly above or below an end
+			cell.position = OrderedPair{endpoint1.x, random.Float64() * 100}
+		case 2: // on an end
+			cell.position = endpoint2
+		case 3: // on the center
+			cell.position = fibre.position
+		case 4: // on the fibre's line
+			along := (random.Float64() - 0.5) * 4 * fibre.length
+			direction := UnitVector(fibre.direction)
+			cell.position = OrderedPair{fibre.position.x + along*direction.x, fibre.position.y + along*direction.y}
+		}
+		stiffness := random.Float64()
+		alignFactor := 1 - AlignFactorCoefficient*cell.integrin*(1-stiffness)
+
+		fibre.FindPivot(&cell)
+		before := AngleToCell(&fibre, &cell)
+		fibre.UpdateFibre(&cell, stiffness)
+		after := AngleToCell(&fibre, &cell)
+
+		for _, value := range []float64{fibre.position.x, fibre.position.y, fibre.direction.x, fibre.direction.y, fibre.pivot.x, fibre.pivot.y} {
+			if math.IsNaN(value) || math.IsInf(value, 0) {
+				t.Fatalf("Error! For input test dataset %d, your code gives the fibre %+v, and the correct fibre has finite coordinates.", i, fibre)
+			}
+		}
+		if length := 2 * ComputeDistance(fibre.pivot, fibre.position); math.Abs(length-fibre.length) > 1e-9*math.Max(1, fibre.length) && fibre.direction.Magnitude() > 0 {
+			t.Fatalf("Error! For input test dataset %d, your code gives a fibre of length %v, and the correct length is %v.", i, length, fibre.length)
+		}
+		if alignFactor >= 0 && alignFactor <= 1 && (after > before+1e-9 || after < -1e-9) {
+			t.Fatalf("Error! For input test dataset %d, your code turns the fibre from %v to %v radians from the cell, and the correct angle is between 0 and %v.", i, before, after, before)
+		}
+	}
+}
+
+// AngleToCell: Returns the angle between a fibre's direction and the line from its pivot to a cell.
+func AngleToCell(fibre *Fibre, cell *Cell) float64 {
+	return CalculateAngleBetweenVectors2D(fibre.direction, OrderedPair{cell.position.x - fibre.pivot.x, cell.position.y - fibre.pivot.y})
+}
+
+// TestUpdateECMProperties runs one generation of the whole model with every tunable parameter at one of its
+// allowed bounds or somewhere in between, including the crosslink, steering and recoil settings that are
+// checked by CheckNetworkOptions and CheckSteeringOptions. No generation may break an invariant.
+func TestUpdateECMProperties(t *testing.T) {
+	random := rand.New(rand.NewSource(2))
+	values := make([]float64, len(ModelParameters))
+	for i, p := range ModelParameters {
+		values[i] = *p.Value
+	}
+	width, stiffness, speed, density, springs, steering := ECMwidth, ECMstiffness, CellSpeed, CrosslinkDensity, CrosslinkStiffness, CellSteering
+	persistenceTime, tumbleRate, noise, recoilTime, softBody := PersistenceTime, TumbleRate, NoiseAmplitude, RecoilTime, SoftBodyCells
+	defer func() {
+		for i, p := range ModelParameters {
+			*p.Value = values[i]
+		}
+		ECMwidth, ECMstiffness, CellSpeed, CrosslinkDensity, CrosslinkStiffness, CellSteering = width, stiffness, speed, density, springs, steering
+		PersistenceTime, TumbleRate, NoiseAmplitude, RecoilTime, SoftBodyCells = persistenceTime, tumbleRate, noise, recoilTime, softBody
+	}()
+
+	// pick: Returns the lower bound, the upper bound or a value between them.
+	pick := func(min, max float64) float64 {
+		return []float64{min, max, min + random.Float64()*(max-min)}[random.Intn(3)]
+	}
+	for i := 0; i < 100; i++ {
+		for _, p := range ModelParameters {
+			*p.Value = pick(p.Min, p.Max)
+		}
+		CrosslinkDensity = pick(0, 1)
+		CrosslinkStiffness = pick(1e-9, 100)
+		CellSteering = SteeringModels[SteeringModelNames()[random.Intn(len(SteeringModels))]]
+		PersistenceTime = pick(1e-9, 100)
+		TumbleRate = pick(0, 100)
+		NoiseAmplitude = pick(0, 100)
+		RecoilTime = pick(0, 100)
+		SoftBodyCells = random.Intn(2) == 0
+		CheckNetworkOptions()
+		CheckSteeringOptions()
+		if err := CheckModelParameters(); err != nil {
+			t.Fatalf("Error! For input test dataset %d, your code rejects the parameters: %v.", i, err)
+		}
+
+		SeedRandom(int64(i))
+		ecm := InitializeECM(1+random.Intn(60), 1+random.Intn(4), 200, pick(0, 100), pick(0, 1))
+		if violation := FindInvariantViolation(nil, ecm, 0); violation != nil {
+			t.Fatalf("Error! For input test dataset %d, your code breaks %q at the start: %s", i, violation.Error(), violation.State)
+		}
+		_, next, _ := ecm.UpdateECM(pick(1e-3, 1), 0, nil)
+		if violation := FindInvariantViolation(ecm, next, 1); violation != nil {
+			t.Fatalf("Error! For input test dataset %d, your code breaks %q: %s", i, violation.Error(), violation.State)
+		}
+	}
+}
+
+// TestUpdateFibreFromCells puts two cells by a horizontal fibre 20 uM long centered at (50, 50) and checks the
+// combined rotation, the new center and that the pivot stays on an end.
+func TestUpdateFibreFromCells(t *testing.T) {
+	coefficient := AlignFactorCoefficient
+	defer func() { AlignFactorCoefficient = coefficient }()
+	AlignFactorCoefficient = 0.1
+
+	// a cell 20 uM along from the pivot and 10 uM off the fibre, with an align factor of 1 - 0.1*5*(1-0.5)
+	d := math.Sqrt(20*20 + 10*10)
+	phi := math.Asin(10/d) - math.Asin(0.75*10/d)
+
+	type test struct {
+		cells  []OrderedPair
+		angle  float64 // of the fibre as an undirected line, in radians
+		center OrderedPair
+	}
+
+	tests := make([]test, 3)
+	// on opposite sides of the fibre and at opposite ends: each turns it anticlockwise about the far end,
+	// so together they turn it about its center
+	tests[0] = test{[]OrderedPair{{60, 60}, {40, 40}}, phi, OrderedPair{50, 50}}
+	// on opposite sides at the same end: the turns cancel out and the center is pulled towards the pivot
+	tests[1] = test{[]OrderedPair{{60, 60}, {60, 40}}, 0, OrderedPair{40 + 10*math.Cos(phi), 50}}
+	// a single cell
+	tests[2] = test{[]OrderedPair{{60, 60}}, phi, OrderedPair{40 + 10*math.Cos(phi), 50 + 10*math.Sin(phi)}}
+
+	for i, test := range tests {
+		fibre := NewTestFibre(50, 50, 20, 0)
+		cells := make([]*Cell, len(test.cells))
+		for j, position := range test.cells {
+			cells[j] = &Cell{integrin: 5, position: position}
+		}
+		fibre.UpdateFibreFromCells(cells, 0.5)
+
+		answer := OrderedPair{math.Cos(test.angle), math.Sin(test.angle)}
+		if math.Abs(CrossProduct2D(fibre.direction, answer)) > 1e-9 {
+			t.Errorf("Error! For input test dataset %d, your code gives the direction %v, and the correct direction is along %v.", i, fibre.direction, answer)
+		}
+		if ComputeDistance(fibre.position, test.center) > 1e-9 {
+			t.Errorf("Error! For input test dataset %d, your code gives the center %v, and the correct center is %v.", i, fibre.position, test.center)
+		}
+		if rule := FibreInvariant(fibre, &Fibre{length: 20}); rule != "" {
+			t.Errorf("Error! For input test dataset %d, your code breaks %q, and the correct fibre keeps it.", i, rule)
+		}
+	}
+}
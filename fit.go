@@ -0,0 +1,516 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FitParameterNames are the parameters the fitting tool can search over.
+var FitParameterNames = []string{"stiffness", "integrin", "cellSpeed", "noiseAmplitude"}
+
+// FitParameter is one parameter being fitted: its bounds and, for grid search, the values to try.
+type FitParameter struct {
+	name     string
+	min, max float64
+	values   []float64
+}
+
+// TrackSummary holds the motility statistics that simulated and observed tracks are compared on.
+type TrackSummary struct {
+	msd         []float64 // mean squared displacement at lags of 0, 1, 2, ... steps
+	speeds      []float64 // every instantaneous speed
+	persistence float64   // velocity autocorrelation one step apart
+}
+
+// FitResult is one evaluated parameter combination and how well its simulations matched the observed tracks.
+type FitResult struct {
+	values                                        map[string]float64
+	msdError, speedKS, persistenceError, distance float64
+	summary                                       TrackSummary
+}
+
+// Fitter holds everything needed to simulate a parameter combination and compare it with the observed tracks.
+type Fitter struct {
+	executable string
+	observed   TrackSummary
+	parameters []FitParameter
+	fixed      map[string]string // the flags passed to every run, e.g. numGens and timeStep
+	replicates int
+	workers    int
+	seed       int64
+	out        string
+	results    []FitResult
+}
+
+// SummarizeTracks: Computes the statistics used for fitting from a set of trajectories.
+// Input: trajectories ([]Trajectory) the tracks, maxLag (int) the longest MSD lag in steps.
+func SummarizeTracks(trajectories []Trajectory, maxLag int) TrackSummary {
+	var summary TrackSummary
+	summary.msd = MeanSquaredDisplacement(trajectories, maxLag)
+	summary.speeds = SpeedSamples(trajectories)
+	autocorrelation := VelocityAutocorrelation(trajectories, 1)
+	summary.persistence = autocorrelation[1]
+	return summary
+}
+
+// CompareSummaries: Measures how different two track summaries are.
+// msdError is the root mean square of log10(simulated MSD / observed MSD) over every lag,
+// speedKS is the Kolmogorov-Smirnov distance between the speed distributions,
+// and persistenceError is the absolute difference in persistence. The distance is their sum.
+// Undefined components count as 1 so that failed or empty simulations are never the best fit.
+func CompareSummaries(simulated, observed TrackSummary) (float64, float64, float64, float64) {
+	msdError := 0.0
+	numLags := 0
+	for lag := 1; lag < len(simulated.msd) && lag < len(observed.msd); lag++ {
+		if simulated.msd[lag] > 0 && observed.msd[lag] > 0 {
+			ratio := math.Log10(simulated.msd[lag] / observed.msd[lag])
+			msdError += ratio * ratio
+			numLags++
+		}
+	}
+	if numLags == 0 {
+		msdError = 1
+	} else {
+		msdError = math.Sqrt(msdError / float64(numLags))
+	}
+
+	speedKS := KolmogorovSmirnov(simulated.speeds, observed.speeds)
+	persistenceError := math.Abs(simulated.persistence - observed.persistence)
+	if math.IsNaN(persistenceError) {
+		persistenceError = 1
+	}
+	return msdError, speedKS, persistenceError, msdError + speedKS + persistenceError
+}
+
+// KolmogorovSmirnov: Returns the largest difference between the empirical distribution functions of two samples.
+// Returns 1 if either sample is empty.
+func KolmogorovSmirnov(sample1, sample2 []float64) float64 {
+	if len(sample1) == 0 || len(sample2) == 0 {
+		return 1
+	}
+	a := append([]float64{}, sample1...)
+	b := append([]float64{}, sample2...)
+	sort.Float64s(a)
+	sort.Float64s(b)
+	i, j := 0, 0
+	largest := 0.0
+	for i < len(a) && j < len(b) {
+		value := math.Min(a[i], b[j])
+		for i < len(a) && a[i] <= value {
+			i++
+		}
+		for j < len(b) && b[j] <= value {
+			j++
+		}
+		difference := math.Abs(float64(i)/float64(len(a)) - float64(j)/float64(len(b)))
+		largest = math.Max(largest, difference)
+	}
+	return largest
+}
+
+// ParseFitParameter: Parses the search space of a fit parameter. A range start:stop:step gives the bounds and
+// the grid values, and a list a,b,c gives the grid values with bounds from the smallest to the largest.
+func ParseFitParameter(name, spec string) (FitParameter, error) {
+	parameter := FitParameter{name: name}
+	values, err := ParseSweepValues(spec)
+	if err != nil {
+		return parameter, err
+	}
+	parameter.min, parameter.max = math.Inf(1), math.Inf(-1)
+	for _, value := range values {
+		number, _ := strconv.ParseFloat(value, 64)
+		parameter.values = append(parameter.values, number)
+		parameter.min = math.Min(parameter.min, number)
+		parameter.max = math.Max(parameter.max, number)
+	}
+	return parameter, nil
+}
+
+// RunFit: Parses the flags of the "fit" command and searches for the parameters whose simulations best match
+// a file of observed cell tracks. Writes every evaluated combination to FitResults.csv, a report to FitReport.txt
+// and the observed and best simulated MSD curves to FitMSD.svg.
+func RunFit(args []string) {
+	flags := flag.NewFlagSet("fit", flag.ExitOnError)
+	observedFile := flags.String("observed", "", "csv file of observed tracks with rows time,label,x,y")
+	method := flags.String("method", "grid", "search method: grid, nelder-mead or abc")
+	specs := make(map[string]*string)
+	for _, name := range FitParameterNames {
+		specs[name] = flags.String(name, "", "search space of "+name+": a range start:stop:step or a list a,b,c. Leave empty to keep it fixed")
+	}
+	numFibres := flags.Int("numFibres", DefaultRunParameters.numFibres, "number of fibres in each simulation")
+	width := flags.Float64("width", DefaultRunParameters.width, "width of the simulated ECM")
+	observedWidth := flags.Float64("observedWidth", 0, "width of the board the observed tracks wrap around on (0 if they don't wrap, as for microscopy)")
+	replicates := flags.Int("replicates", 2, "simulations per parameter combination")
+	workers := flags.Int("workers", runtime.NumCPU(), "number of simulations to run at once")
+	iterations := flags.Int("iterations", 40, "largest number of combinations nelder-mead evaluates")
+	samples := flags.Int("samples", 100, "number of combinations abc samples")
+	acceptFraction := flags.Float64("accept", 0.1, "fraction of abc samples accepted into the posterior")
+	seed := flags.Int64("seed", DefaultSeed(), "seed of the first simulation (at least 1)")
+	out := flags.String("out", "fit", "directory to write the simulations and results to")
+	flags.DurationVar(&TimeLimit, "timeout", TimeLimit, "time limit of each simulation, e.g. 10m (0 for no limit); runs that hit it count as failed")
+	flags.Parse(args)
+	if *seed < 1 {
+		// every run is given a seed counting up from this one, and "run" reads 0 as "seed from the clock"
+		fmt.Println("Error: -seed must be at least 1.")
+		os.Exit(2)
+	}
+
+	if *observedFile == "" {
+		fmt.Println("Error: -observed is required.")
+		os.Exit(2)
+	}
+	positionArray, err := ReadPositionFile(*observedFile)
+	if err != nil || len(positionArray) == 0 {
+		fmt.Println("Error: could not read any tracks from " + *observedFile + ".")
+		os.Exit(1)
+	}
+
+	// tracks from microscopy don't wrap around, but tracks from this program do
+	trajectories := ExtractTrajectories(positionArray, *observedWidth)
+	maxSteps := 0
+	var timeSteps []float64
+	for _, trajectory := range trajectories {
+		maxSteps = int(math.Max(float64(maxSteps), float64(len(trajectory.positions)-1)))
+		for i := 1; i < len(trajectory.times); i++ {
+			timeSteps = append(timeSteps, trajectory.times[i]-trajectory.times[i-1])
+		}
+	}
+	if maxSteps < 2 {
+		fmt.Println("Error: the observed tracks need at least 3 time points.")
+		os.Exit(1)
+	}
+	sort.Float64s(timeSteps)
+	timeStep := timeSteps[len(timeSteps)/2] // median, in case of missing frames
+
+	var fitter Fitter
+	fitter.observed = SummarizeTracks(trajectories, maxSteps)
+	fitter.replicates, fitter.workers, fitter.seed, fitter.out = *replicates, *workers, *seed, *out
+	fitter.fixed = map[string]string{
+		"numGens":   strconv.Itoa(maxSteps),
+		"numCells":  strconv.Itoa(len(trajectories)),
+		"numFibres": strconv.Itoa(*numFibres),
+		"timeStep":  FormatValue(timeStep),
+		"width":     FormatValue(*width),
+	}
+	for _, name := range FitParameterNames {
+		if *specs[name] == "" {
+			continue
+		}
+		parameter, err := ParseFitParameter(name, *specs[name])
+		if err != nil {
+			fmt.Println("Error in -" + name + ": " + err.Error())
+			os.Exit(2)
+		}
+		fitter.parameters = append(fitter.parameters, parameter)
+	}
+	if len(fitter.parameters) == 0 {
+		fmt.Println("Error: give a search space for at least one of " + strings.Join(FitParameterNames, ", ") + ".")
+		os.Exit(2)
+	}
+	fitter.executable, err = os.Executable()
+	if err != nil {
+		panic("Error finding the program to run simulations with.")
+	}
+
+	fmt.Printf("Fitting %d observed tracks (%d steps of %g hours) with %s.\n", len(trajectories), maxSteps, timeStep, *method)
+	start := time.Now()
+	var report string
+	switch *method {
+	case "grid":
+		fitter.GridSearch()
+	case "nelder-mead":
+		fitter.NelderMead(*iterations)
+	case "abc":
+		report = fitter.ApproximateBayesian(*samples, *acceptFraction)
+	default:
+		fmt.Println("Error: unknown method " + *method + ".")
+		os.Exit(2)
+	}
+	fmt.Printf("Fit finished in %s.\n", time.Since(start).Truncate(time.Second))
+	fitter.WriteResults(*method, report)
+}
+
+// Evaluate: Simulates each parameter combination fitter.replicates times (all in parallel) and compares the pooled
+// trajectories of each combination with the observed tracks.
+// Input: points ([]map[string]float64) the parameter combinations.
+// Output: ([]FitResult) the results in the same order. They are also added to fitter.results.
+func (fitter *Fitter) Evaluate(points []map[string]float64) []FitResult {
+	var jobs []SweepJob
+	for _, point := range points {
+		evaluation := len(fitter.results) + len(jobs)/fitter.replicates
+		values := make(map[string]string)
+		for name, value := range fitter.fixed {
+			values[name] = value
+		}
+		for name, value := range point {
+			values[name] = FormatValue(value)
+		}
+		for replicate := 0; replicate < fitter.replicates; replicate++ {
+			jobs = append(jobs, SweepJob{
+				combination: evaluation,
+				replicate:   replicate,
+				seed:        fitter.seed + int64(evaluation*fitter.replicates+replicate),
+				values:      values,
+				dir:         filepath.Join(fitter.out, fmt.Sprintf("evaluation%04d_replicate%02d", evaluation, replicate)),
+			})
+		}
+	}
+
+	errs := make([]error, len(jobs))
+	RunParallel(len(jobs), fitter.workers, func(i int) {
+		errs[i] = jobs[i].Execute(fitter.executable)
+	})
+
+	width, _ := strconv.ParseFloat(fitter.fixed["width"], 64)
+	maxLag := len(fitter.observed.msd) - 1
+	results := make([]FitResult, len(points))
+	for p, point := range points {
+		var trajectories []Trajectory
+		for _, job := range jobs[p*fitter.replicates : (p+1)*fitter.replicates] {
+			if errs[p*fitter.replicates+job.replicate] != nil {
+				fmt.Println("Simulation failed:", errs[p*fitter.replicates+job.replicate])
+				continue
+			}
+			positionArray, err := ReadPositionFile(filepath.Join(job.dir, "CellPosition.csv"))
+			if err != nil {
+				continue
+			}
+			trajectories = append(trajectories, ExtractTrajectories(positionArray, width)...)
+		}
+		result := FitResult{values: point, summary: SummarizeTracks(trajectories, maxLag)}
+		result.msdError, result.speedKS, result.persistenceError, result.distance = CompareSummaries(result.summary, fitter.observed)
+		results[p] = result
+		fmt.Printf("Evaluation %d: %s distance %.4f\n", len(fitter.results)+p, FormatPoint(point, fitter.parameters), result.distance)
+	}
+	fitter.results = append(fitter.results, results...)
+	return results
+}
+
+// GridSearch: Evaluates every combination of the grid values of the fit parameters.
+func (fitter *Fitter) GridSearch() {
+	names := make([]string, len(fitter.parameters))
+	values := make([][]string, len(fitter.parameters))
+	for i, parameter := range fitter.parameters {
+		names[i] = parameter.name
+		for _, value := range parameter.values {
+			values[i] = append(values[i], FormatValue(value))
+		}
+	}
+	var points []map[string]float64
+	for _, combination := range ParameterGrid(names, values) {
+		point := make(map[string]float64)
+		for name, value := range combination {
+			point[name] = ParseValue(value)
+		}
+		points = append(points, point)
+	}
+	fitter.Evaluate(points)
+}
+
+// NelderMead: Minimizes the distance with the Nelder-Mead simplex method. The search works on every parameter
+// scaled to [0, 1] between its bounds, starts from the middle of the search space, and clamps points to the bounds.
+// Input: maxEvaluations (int) the largest number of parameter combinations to evaluate.
+func (fitter *Fitter) NelderMead(maxEvaluations int) {
+	n := len(fitter.parameters)
+	toPoint := func(x []float64) map[string]float64 {
+		point := make(map[string]float64)
+		for i, parameter := range fitter.parameters {
+			point[parameter.name] = parameter.min + math.Max(0, math.Min(1, x[i]))*(parameter.max-parameter.min)
+		}
+		return point
+	}
+	evaluate := func(xs ...[]float64) []float64 {
+		points := make([]map[string]float64, len(xs))
+		for i, x := range xs {
+			points[i] = toPoint(x)
+		}
+		distances := make([]float64, len(xs))
+		for i, result := range fitter.Evaluate(points) {
+			distances[i] = result.distance
+		}
+		return distances
+	}
+
+	// the initial simplex is the center plus a step of 0.25 along each axis. All vertices are evaluated together.
+	simplex := make([][]float64, n+1)
+	for i := range simplex {
+		simplex[i] = make([]float64, n)
+		for j := range simplex[i] {
+			simplex[i][j] = 0.5
+		}
+		if i > 0 {
+			simplex[i][i-1] += 0.25
+		}
+	}
+	distances := evaluate(simplex...)
+	evaluations := n + 1
+
+	for evaluations < maxEvaluations {
+		// order the vertices from best to worst
+		order := make([]int, n+1)
+		for i := range order {
+			order[i] = i
+		}
+		sort.Slice(order, func(a, b int) bool { return distances[order[a]] < distances[order[b]] })
+		sortedSimplex := make([][]float64, n+1)
+		sortedDistances := make([]float64, n+1)
+		for i, index := range order {
+			sortedSimplex[i], sortedDistances[i] = simplex[index], distances[index]
+		}
+		simplex, distances = sortedSimplex, sortedDistances
+
+		centroid := make([]float64, n)
+		for _, vertex := range simplex[:n] {
+			for j := range centroid {
+				centroid[j] += vertex[j] / float64(n)
+			}
+		}
+		along := func(coefficient float64) []float64 {
+			x := make([]float64, n)
+			for j := range x {
+				x[j] = math.Max(0, math.Min(1, centroid[j]+coefficient*(simplex[n][j]-centroid[j])))
+			}
+			return x
+		}
+
+		reflected := along(-1)
+		reflectedDistance := evaluate(reflected)[0]
+		evaluations++
+		switch {
+		case reflectedDistance < distances[0]:
+			expanded := along(-2)
+			expandedDistance := evaluate(expanded)[0]
+			evaluations++
+			if expandedDistance < reflectedDistance {
+				simplex[n], distances[n] = expanded, expandedDistance
+			} else {
+				simplex[n], distances[n] = reflected, reflectedDistance
+			}
+		case reflectedDistance < distances[n-1]:
+			simplex[n], distances[n] = reflected, reflectedDistance
+		default:
+			contracted := along(0.5)
+			contractedDistance := evaluate(contracted)[0]
+			evaluations++
+			if contractedDistance < distances[n] {
+				simplex[n], distances[n] = contracted, contractedDistance
+				continue
+			}
+			// shrink every vertex towards the best one
+			for i := 1; i <= n; i++ {
+				for j := range simplex[i] {
+					simplex[i][j] = simplex[0][j] + 0.5*(simplex[i][j]-simplex[0][j])
+				}
+			}
+			copy(distances[1:], evaluate(simplex[1:]...))
+			evaluations += n
+		}
+	}
+}
+
+// ApproximateBayesian: Rejection ABC. Draws parameter combinations uniformly between the bounds, simulates them
+// all, and accepts the acceptFraction closest to the observed tracks as samples from the posterior.
+// Output: (string) the posterior mean and standard deviation of each parameter, for the report.
+func (fitter *Fitter) ApproximateBayesian(numSamples int, acceptFraction float64) string {
+	points := make([]map[string]float64, numSamples)
+	for i := range points {
+		points[i] = make(map[string]float64)
+		for _, parameter := range fitter.parameters {
+			points[i][parameter.name] = parameter.min + rng.Float64()*(parameter.max-parameter.min)
+		}
+	}
+	results := fitter.Evaluate(points)
+	sort.Slice(results, func(a, b int) bool { return results[a].distance < results[b].distance })
+	numAccepted := int(math.Max(1, math.Round(acceptFraction*float64(numSamples))))
+	if numAccepted > len(results) {
+		numAccepted = len(results)
+	}
+
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "ABC posterior from %d of %d samples (distance <= %.4f):\n", numAccepted, numSamples, results[numAccepted-1].distance)
+	for _, parameter := range fitter.parameters {
+		values := make([]float64, numAccepted)
+		for i := range values {
+			values[i] = results[i].values[parameter.name]
+		}
+		mean, sd := MeanAndStandardDeviation(values)
+		fmt.Fprintf(&builder, "  %s = %.4g +/- %.4g\n", parameter.name, mean, sd)
+	}
+	return builder.String()
+}
+
+// Best: Returns the evaluated combination with the smallest distance.
+func (fitter *Fitter) Best() FitResult {
+	best := fitter.results[0]
+	for _, result := range fitter.results {
+		if result.distance < best.distance {
+			best = result
+		}
+	}
+	return best
+}
+
+// FormatPoint: Formats a parameter combination as "name=value name=value".
+func FormatPoint(point map[string]float64, parameters []FitParameter) string {
+	fields := make([]string, len(parameters))
+	for i, parameter := range parameters {
+		fields[i] = fmt.Sprintf("%s=%.4g", parameter.name, point[parameter.name])
+	}
+	return strings.Join(fields, " ")
+}
+
+// WriteResults: Writes FitResults.csv, FitReport.txt and FitMSD.svg to the output directory and prints the report.
+func (fitter *Fitter) WriteResults(method, extraReport string) {
+	if len(fitter.results) == 0 {
+		fmt.Println("No parameter combinations were evaluated.")
+		return
+	}
+	var header []string
+	for _, parameter := range fitter.parameters {
+		header = append(header, parameter.name)
+	}
+	header = append(header, "msdError", "speedKS", "persistenceError", "distance")
+	rows := [][]string{header}
+	for _, result := range fitter.results {
+		var row []string
+		for _, parameter := range fitter.parameters {
+			row = append(row, FormatValue(result.values[parameter.name]))
+		}
+		row = append(row, FormatValue(result.msdError), FormatValue(result.speedKS), FormatValue(result.persistenceError), FormatValue(result.distance))
+		rows = append(rows, row)
+	}
+	WriteCSV(filepath.Join(fitter.out, "FitResults.csv"), rows)
+
+	best := fitter.Best()
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "Method: %s, %d parameter combinations, %d simulations each.\n", method, len(fitter.results), fitter.replicates)
+	fmt.Fprintf(&builder, "Best fit: %s\n", FormatPoint(best.values, fitter.parameters))
+	fmt.Fprintf(&builder, "  MSD error (RMS log10 ratio): %.4f\n", best.msdError)
+	fmt.Fprintf(&builder, "  Speed distribution KS distance: %.4f\n", best.speedKS)
+	fmt.Fprintf(&builder, "  Persistence difference: %.4f (observed %.4f, simulated %.4f)\n",
+		best.persistenceError, fitter.observed.persistence, best.summary.persistence)
+	fmt.Fprintf(&builder, "  Mean speed: observed %.4g, simulated %.4g uM/hour\n", Mean(fitter.observed.speeds), Mean(best.summary.speeds))
+	fmt.Fprintf(&builder, "  Total distance: %.4f\n", best.distance)
+	builder.WriteString(extraReport)
+	report := builder.String()
+	fmt.Print(report)
+	if err := os.WriteFile(filepath.Join(fitter.out, "FitReport.txt"), []byte(report), 0644); err != nil {
+		panic("Error writing fit report.")
+	}
+
+	timeStep := ParseValue(fitter.fixed["timeStep"])
+	lagTimes := make([]float64, len(fitter.observed.msd))
+	for lag := range lagTimes {
+		lagTimes[lag] = float64(lag) * timeStep
+	}
+	WriteLinePlotSVG(filepath.Join(fitter.out, "FitMSD.svg"), "Observed and best fitting MSD", "Lag time (hours)", "MSD (uM^2)",
+		[]Series{{Name: "observed", X: lagTimes, Y: fitter.observed.msd}, {Name: "best fit", X: lagTimes, Y: best.summary.msd}})
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestKolmogorovSmirnov(t *testing.T) {
+	type test struct {
+		sample1 []float64
+		sample2 []float64
+		answer  float64
+	}
+
+	tests := make([]test, 4)
+	tests[0].sample1 = []float64{1, 2, 3}
+	tests[0].sample2 = []float64{3, 2, 1}
+	tests[0].answer = 0
+
+	tests[1].sample1 = []float64{1, 2}
+	tests[1].sample2 = []float64{3, 4}
+	tests[1].answer = 1
+
+	tests[2].sample1 = []float64{1, 2, 3, 4}
+	tests[2].sample2 = []float64{3, 4, 5, 6}
+	tests[2].answer = 0.5
+
+	tests[3].sample1 = []float64{}
+	tests[3].sample2 = []float64{1}
+	tests[3].answer = 1
+
+	for i, test := range tests {
+		outcome := KolmogorovSmirnov(test.sample1, test.sample2)
+		if math.Abs(outcome-test.answer) > 1e-9 {
+			t.Errorf("Error! For input test dataset %d, your code gives %v, and the correct distance is %v", i, outcome, test.answer)
+		}
+	}
+}
+
+// TestReadPositionFile checks that positions written by WriteToFile are read back exactly, including times that
+// need more than one decimal, and that files with the old times rounded to one decimal and a header still read.
+func TestReadPositionFile(t *testing.T) {
+	dir := t.TempDir()
+	positionArray := [][]float64{{0, 1, 10, 20}, {0.05, 1, 10.5, 20.25}, {0.1, 2, 1e-7, 499.9999}, {1234.125, 2, 0, 0}}
+	WriteToFile(positionArray, filepath.Join(dir, "CellPosition.csv"))
+	outcome, err := ReadPositionFile(filepath.Join(dir, "CellPosition.csv"))
+	if err != nil || fmt.Sprint(outcome) != fmt.Sprint(positionArray) {
+		t.Errorf("Error! Your code reads back %v (%v), and the correct positions are %v.", outcome, err, positionArray)
+	}
+
+	old := "time,label,x,y\n0.0,1.0,10,20\n0.8,1.0,10.5,20.25\n"
+	if err := os.WriteFile(filepath.Join(dir, "Old.csv"), []byte(old), 0644); err != nil {
+		t.Fatal(err)
+	}
+	outcome, err = ReadPositionFile(filepath.Join(dir, "Old.csv"))
+	if answer := "[[0 1 10 20] [0.8 1 10.5 20.25]]"; err != nil || fmt.Sprint(outcome) != answer {
+		t.Errorf("Error! Your code reads the old format as %v (%v), and the correct positions are %s.", outcome, err, answer)
+	}
+}
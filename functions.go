@@ -1,13 +1,39 @@
 package main
 
 import (
+	"context"
 	"math"
+	"math/rand"
+	"time"
 )
 
+// rng is the random number generator used by the whole simulation. It is seeded from the clock unless
+// SeedRandom is called, so that a run can be repeated exactly by giving it the same seed.
+// Like the parameters in datatypes.go it is global and isn't safe to use from more than one goroutine, so
+// only one simulation may run in a process at a time. This is why sweep and fit start each run as its own
+// process, and why the web app runs one simulation at a time.
+var rng = rand.New(rand.NewSource(time.Now().UnixNano()))
+
+// MaxDefaultSeed is the largest seed DefaultSeed picks. It keeps the seeds short enough to type back in.
+const MaxDefaultSeed = 1000000
+
+// SeedRandom: Reseeds the simulation's random number generator.
+// Input: seed (int64) the seed.
+func SeedRandom(seed int64) {
+	rng = rand.New(rand.NewSource(seed))
+}
+
+// DefaultSeed: Picks a seed from the clock between 1 and MaxDefaultSeed for the first of a set of runs.
+// Seed 0 tells "run" to seed from the clock, which would make that run unrepeatable, so it is never picked.
+func DefaultSeed() int64 {
+	return 1 + time.Now().UnixNano()%MaxDefaultSeed
+}
+
 // SimulateCellMotility takes a ECM object of cells and fibres and updates it over certain number of generations with a specified timestep.
-// Input: a initialECM, numGens and a timestep
+// Input: a context, initialECM, numGens and a timestep
 // Output: A slice of numGens+1 ECM objects that model cell and fibre movement.
-func SimulateCellMotility(initialECM *ECM, numGens int, time float64) ([]*ECM, [][]float64) {
+// ctx is checked before every generation; if it has ended, only the generations simulated so far are returned.
+func SimulateCellMotility(ctx context.Context, initialECM *ECM, numGens int, time float64) ([]*ECM, [][]float64) {
 	// range over some number of generations
 	timeFrames := make([]*ECM, numGens+1)
 	timeFrames[0] = initialECM
@@ -15,10 +41,22 @@ func SimulateCellMotility(initialECM *ECM, numGens int, time float64) ([]*ECM, [
 	// make array to store cell identities and positions as they are updated
 	positionArray := InitializePositionArray(initialECM, numGens)
 
+	ObserveGeneration(func() LiveFrame { return initialECM.ToLiveFrame(0, 0) })
+	if CheckInvariants {
+		CheckGeneration(nil, initialECM, 0)
+	}
+
 	var timePoint float64
 	for gen := 1; gen <= numGens; gen++ {
+		if ctx.Err() != nil {
+			return timeFrames[:gen], positionArray
+		}
 		timePoint, timeFrames[gen], positionArray = timeFrames[gen-1].UpdateECM(time, timePoint, positionArray)
+		if CheckInvariants {
+			CheckGeneration(timeFrames[gen-1], timeFrames[gen], gen)
+		}
 		// fmt.Println("Generation i: ", timeFrames[gen].cells[0].position)
+		ObserveGeneration(func() LiveFrame { return timeFrames[gen].ToLiveFrame(gen, timePoint) })
 	}
 	return timeFrames, positionArray
 }
@@ -32,23 +70,33 @@ func ComputeDistance(p1, p2 OrderedPair) float64 {
 }
 
 // Magnitude: Calculate the magnitude of an ordered pair.
-// Formula for magnitude = (x^2+y^2)^(1/2)
+// Formula for magnitude = (x^2+y^2)^(1/2), computed with math.Hypot so that squaring very small or very
+// large components can't underflow to 0 or overflow to +Inf.
 func (p1 *OrderedPair) Magnitude() float64 {
-	var mag float64
-	mag = math.Sqrt((p1.x * p1.x) + (p1.y * p1.y))
-	return mag
+	return math.Hypot(p1.x, p1.y)
 }
 
 // Normalize: Normalizes an ordered pair so that its magnitude = 1.
+// The zero vector (and a vector with an infinite or NaN component) has no direction and is left unchanged.
 // Input: p1 (*OrderedPair) a pointer to the OrderedPair to be normalized.
 func (p1 *OrderedPair) Normalize() {
 	magnitude := p1.Magnitude()
+	if magnitude == 0 || math.IsInf(magnitude, 0) || math.IsNaN(magnitude) {
+		return
+	}
 	p1.x /= magnitude
 	p1.y /= magnitude
 }
 
+// UnitVector: Returns a normalized copy of v, or v itself if it has no direction (see Normalize).
+func UnitVector(v OrderedPair) OrderedPair {
+	v.Normalize()
+	return v
+}
+
 // FindLine: Find the equation of the line between two Ordered Pairs.
 // Calculates the equation of the line in the form "y = mx+b"
+// The slope is infinite for a vertical line, so use CrossProduct2D to tell which side of a line a point is on.
 // Input:
 // p1, p2 (Ordered Pair) The two ordered pairs that the line should pass through
 // Output:
@@ -83,9 +131,13 @@ func EvaluateLineAtX(x, m, b float64) float64 {
 // Input:
 // v1 (OrderedPair): The vector be projected
 // v2 (OrderedPair): The vector being projected onto.
-// Output: (OrderedPair) The resulting projection vector.
+// Output: (OrderedPair) The resulting projection vector, the zero vector if v2 is the zero vector.
 func ProjectVector(v1, v2 OrderedPair) OrderedPair {
-	scalingFactor := DotProduct2D(v1, v2) / (v2.Magnitude() * v2.Magnitude())
+	lengthSquared := DotProduct2D(v2, v2)
+	if lengthSquared == 0 {
+		return OrderedPair{}
+	}
+	scalingFactor := DotProduct2D(v1, v2) / lengthSquared
 	newVector := MultiplyVectorByConstant2D(v2, scalingFactor)
 	return newVector
 }
@@ -113,11 +165,25 @@ func MultiplyVectorByConstant2D(v1 OrderedPair, constant float64) OrderedPair {
 // Input:
 // v1, v2 (OrderedPair): The two vectors being compared.
 // Output:
-// (float64) The angle between v1 and v2 in radians.
+// (float64) The angle between v1 and v2 in radians, 0 if either is the zero vector.
 func CalculateAngleBetweenVectors2D(v1, v2 OrderedPair) float64 {
-	cosTheta := DotProduct2D(v1, v2) / (v1.Magnitude() * v2.Magnitude())
-	theta := math.Acos(cosTheta)
-	return theta
+	// atan2 of the sine and cosine (both scaled by |v1||v2|) is accurate for nearly parallel vectors,
+	// where arccos of the cosine loses half its digits, and never needs dividing by a zero magnitude
+	return math.Atan2(math.Abs(CrossProduct2D(v1, v2)), DotProduct2D(v1, v2))
+}
+
+// ClampedAsin: Returns arcsin(x) with x clamped to [-1, 1], so a ratio that rounding (or a negative alignment
+// factor) pushes past 1 can't give NaN. NaN gives 0.
+func ClampedAsin(x float64) float64 {
+	return math.Asin(ClampUnit(x))
+}
+
+// ClampUnit: Clamps x to [-1, 1], mapping NaN to 0.
+func ClampUnit(x float64) float64 {
+	if math.IsNaN(x) {
+		return 0
+	}
+	return math.Max(-1, math.Min(1, x))
 }
 
 // FindAngleChange: Calculates the cosine of the angle between two vectors and
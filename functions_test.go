@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	//"io/ioutil"
 	"math"
@@ -612,3 +613,43 @@ func TestCalculateAngleBetweenVectors2D(t *testing.T) {
 		}
 	}
 }
+
+// TestSimulateCellMotilityCancelled stops the simulation after some generation and checks that the generations
+// so far, and only those, are returned along with their positions.
+func TestSimulateCellMotilityCancelled(t *testing.T) {
+	type test struct {
+		cancelAfter int // generation after which the context ends, -1 for never
+		answer      int // number of time frames returned
+	}
+
+	tests := make([]test, 3)
+	tests[0] = test{0, 1}
+	tests[1] = test{2, 3}
+	tests[2] = test{-1, 6}
+
+	defer SetGenerationObserver(nil)
+	for i, test := range tests {
+		SeedRandom(1)
+		initialECM := InitializeECM(50, 3, 200, 10, 0.95)
+		ctx, cancel := context.WithCancel(context.Background())
+		SetGenerationObserver(func(frame LiveFrame) {
+			if frame.Generation == test.cancelAfter {
+				cancel()
+			}
+		})
+		timeFrames, positionArray := SimulateCellMotility(ctx, initialECM, 5, 0.75)
+		cancel()
+
+		if len(timeFrames) != test.answer || len(positionArray) != test.answer*3 {
+			t.Errorf("Error! For input test dataset %d, your code gives %d time frames and %d positions, and the correct numbers are %d and %d.", i, len(timeFrames), len(positionArray), test.answer, test.answer*3)
+			continue
+		}
+		// the positions are those of the frames returned
+		for j, row := range positionArray {
+			cell := timeFrames[j/3].cells[j%3]
+			if row[0] != 0.75*float64(j/3) || row[1] != float64(cell.label) || row[2] != cell.position.x || row[3] != cell.position.y {
+				t.Errorf("Error! For input test dataset %d, your code gives the position row %v, and the correct row is for cell %d at %+v.", i, row, cell.label, cell.position)
+			}
+		}
+	}
+}
@@ -7,10 +7,11 @@ import (
 	"encoding/csv"
 	"os"
 	"strconv"
+	"strings"
 )
 
-// WriteToFile writes given array to a csv file
-func WriteToFile(positionArray [][]float64) {
+// WriteToFile writes given array to the named csv file
+func WriteToFile(positionArray [][]float64, filename string) {
 	stringArray := make([][]string, len(positionArray))
 	// convert every value to string
 	for index, row := range positionArray { //range over every row
@@ -18,7 +19,7 @@ func WriteToFile(positionArray [][]float64) {
 			continue
 		}
 		stringRow := make([]string, 4)
-		stringRow[0] = strconv.FormatFloat(row[0], 'f', 1, 64)
+		stringRow[0] = strconv.FormatFloat(row[0], 'f', -1, 64)
 		stringRow[1] = strconv.FormatFloat(row[1], 'f', 1, 64)
 		stringRow[2] = strconv.FormatFloat(row[2], 'f', -1, 64)
 		stringRow[3] = strconv.FormatFloat(row[3], 'f', -1, 64)
@@ -26,7 +27,7 @@ func WriteToFile(positionArray [][]float64) {
 	}
 
 	// make a CSV file to record cell positions at every time-point
-	outFilePosition, err1 := os.Create("CellPosition.csv")
+	outFilePosition, err1 := os.Create(filename)
 
 	if err1 != nil {
 		panic("Error creating output csv file for positions.")
@@ -44,22 +45,53 @@ func WriteToFile(positionArray [][]float64) {
 
 }
 
+// ReadPositionFile reads a csv file of cell positions in the format written by WriteToFile
+// ([timepoint, cell label, x, y] per row), e.g. tracks exported from microscopy.
+// Rows that can't be parsed (such as a header) are skipped.
+// Output: the rows as [][]float64, or an error if the file can't be read.
+func ReadPositionFile(filename string) ([][]float64, error) {
+	rows, err := ReadCSV(filename)
+	if err != nil {
+		return nil, err
+	}
+	var positionArray [][]float64
+	for _, row := range rows {
+		if len(row) < 4 {
+			continue
+		}
+		values := make([]float64, 4)
+		valid := true
+		for i := range values {
+			value, err := strconv.ParseFloat(strings.TrimSpace(row[i]), 64)
+			if err != nil {
+				valid = false
+				break
+			}
+			values[i] = value
+		}
+		if valid {
+			positionArray = append(positionArray, values)
+		}
+	}
+	return positionArray, nil
+}
+
 // PlotGraph takes an array of positions in string form [timepoint, cell label, x, y] and plots both individual RMSD and average RMSD across all cells
-func PlotGraph(positionArray [][]float64, numCells int) map[float64][][]float64 {
+func PlotGraph(positionArray [][]float64) map[float64][][]float64 {
 
 	// separate position arrays by cell
-	positionList := SeparateByCell(positionArray, numCells)
+	positionList := SeparateByCell(positionArray)
 	/*
 		// Calculate Root Mean-Squared Deviation (RMSD) for every cell at every timepoint
-		totalList := make([][]float64, numCells*2)
+		totalList := make([][]float64, len(positionList)*2)
 		for _, cellList := range positionList {
 			xValues, yValues := GetRMSD(cellList) // returns time and RMSD values
 			totalList = append(totalList, xValues, yValues...)
 			PlotIndividualRMSD(xValues, yValues)
 		}
 
-		if numCells > 1 { // if there is more than 1 cell, let's look at the average RMSD
-			meanXValues, meanYValues := GetMeanRMSD(totalList, numCells) // Calculate mean RMSD over all cells
+		if len(positionList) > 1 { // if there is more than 1 cell, let's look at the average RMSD
+			meanXValues, meanYValues := GetMeanRMSD(totalList, len(positionList)) // Calculate mean RMSD over all cells
 
 			PlotMeanRMSD(meanXValues, meanYValues)
 		}
@@ -68,28 +100,16 @@ func PlotGraph(positionArray [][]float64, numCells int) map[float64][][]float64
 }
 
 // SeparateByCell takes a position array in the form [[timepoint1, cell label, x, y], [timepoint2, cell label, x, y]...] and converts it to [[cell1: timepoint, x, y], [cell2: timepoint, x, y]...]
-func SeparateByCell(positionArray [][]float64, numCells int) map[float64][][]float64 {
-	cellMap := make(map[float64][][]float64, numCells)
-	for i := 1; i <= numCells; i++ { // we will make a new key for every cell
-		counter := 0
-		for _, row := range positionArray { // range over every row in position array
-			if row == nil { // skip nil rows
-				continue
-			}
-			// fmt.Println(row)
-			if float64(i+1) == row[1] { // if current row corresponds to desired cell label
-				if cellMap[float64(i)] == nil { // the key does not already exist in the map
-					cellMap[float64(i)] = make([][]float64, len(positionArray)) // create an empty array
-				}
-				cellMap[float64(i)][counter] = make([]float64, 3)
-				cellMap[float64(i)][counter][0] = row[0] // timepoint
-				cellMap[float64(i)][counter][1] = row[2] // x-position
-				cellMap[float64(i)][counter][2] = row[3] // y-position
-				counter++
-			}
+// The labels are taken from the rows, since cells that are born during the run get new labels.
+func SeparateByCell(positionArray [][]float64) map[float64][][]float64 {
+	cellMap := make(map[float64][][]float64)
+	for _, row := range positionArray { // range over every row in position array
+		if row == nil { // skip nil rows
+			continue
 		}
+		// the rows of each cell are kept in the order they were written
+		cellMap[row[1]] = append(cellMap[row[1]], []float64{row[0], row[2], row[3]}) // timepoint, x-position, y-position
 	}
-	// fmt.Println(cellMap)
 	return cellMap
 }
 
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestSeparateByCell(t *testing.T) {
+	// cell 1 divides into cells 4 and 5 at time 1, and there is no cell 2
+	positionArray := [][]float64{
+		{0, 1, 10, 20},
+		{0, 3, 30, 40},
+		nil,
+		{1, 3, 31, 41},
+		{1, 4, 11, 21},
+		{1, 5, 9, 19},
+		{2, 4, 12, 22},
+	}
+	answer := map[float64][][]float64{
+		1: {{0, 10, 20}},
+		3: {{0, 30, 40}, {1, 31, 41}},
+		4: {{1, 11, 21}, {2, 12, 22}},
+		5: {{1, 9, 19}},
+	}
+
+	outcome := SeparateByCell(positionArray)
+	if len(outcome) != len(answer) {
+		t.Fatalf("Error! Your code gives %d cells, and the correct number is %d", len(outcome), len(answer))
+	}
+	for label, rows := range answer {
+		if len(outcome[label]) != len(rows) {
+			t.Errorf("Error! For cell %v, your code gives %v, and the correct rows are %v", label, outcome[label], rows)
+			continue
+		}
+	rowLoop:
+		for i := range rows {
+			for j := range rows[i] {
+				if outcome[label][i][j] != rows[i][j] {
+					t.Errorf("Error! For cell %v, your code gives %v, and the correct rows are %v", label, outcome[label], rows)
+					break rowLoop
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"flag"
+	htemplate "html/template"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RunsDir is where the web app keeps one directory per run, each with a run.json describing it.
+var RunsDir = "runs"
+
+// HistoryThumbnailSize is the width in pixels of the thumbnail each web run writes for the history page.
+const HistoryThumbnailSize = 240
+
+// RunRecord describes one run of the web app. It is saved as run.json in the run's directory.
+type RunRecord struct {
+	ID         string            `json:"id"`
+	Created    time.Time         `json:"created"`
+	Parameters map[string]string `json:"parameters"` // the submitted form, field name -> value
+	State      string            `json:"state"`      // "running", "complete", "cancelled", "timed out" or "failed"
+	Message    string            `json:"message"`
+	Status     RunStatus         `json:"status"`
+	Animation  string            `json:"animation"` // path of the animation inside the run directory, "" for none
+	dir        string            // directory holding run.json and the run's outputs
+}
+
+// runIDPattern is what run IDs look like. IDs come from URLs, so anything else is rejected.
+var runIDPattern = regexp.MustCompile(`^[0-9A-Za-z_-]+$`)
+
+// NewRunRecord: Creates the directory of a new run and saves its record with state "running".
+// The ID is the start time, with a suffix if a run already started in the same second.
+// Input: form (url.Values) the submitted form.
+func NewRunRecord(form url.Values) (*RunRecord, error) {
+	record := &RunRecord{Created: time.Now(), Parameters: make(map[string]string), State: "running"}
+	for key, values := range form {
+		if len(values) > 0 {
+			record.Parameters[key] = values[0]
+		}
+	}
+	if err := os.MkdirAll(RunsDir, 0755); err != nil {
+		return nil, err
+	}
+	base := record.Created.Format("2006-01-02_15-04-05")
+	record.ID = base
+	for i := 2; ; i++ {
+		record.dir = filepath.Join(RunsDir, record.ID)
+		err := os.Mkdir(record.dir, 0755)
+		if err == nil {
+			break
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		record.ID = base + "-" + strconv.Itoa(i)
+	}
+	return record, record.Save()
+}
+
+// Dir: Returns the directory of the run.
+func (record *RunRecord) Dir() string {
+	return record.dir
+}
+
+// URL: Returns the URL path of a file in the run's directory.
+func (record *RunRecord) URL(name string) string {
+	return path.Join("/runs", record.ID, filepath.ToSlash(name))
+}
+
+// Save: Writes the record to run.json in the run's directory.
+func (record *RunRecord) Save() error {
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(record.Dir(), "run.json"), data, 0644)
+}
+
+// FlagParameters: Returns the value of every flag in a flag set, so command line runs record their
+// parameters the same way as the web form.
+func FlagParameters(flags *flag.FlagSet) map[string]string {
+	parameters := make(map[string]string)
+	flags.VisitAll(func(f *flag.Flag) {
+		parameters[f.Name] = f.Value.String()
+	})
+	return parameters
+}
+
+// Finish: Records how the run ended and saves the record.
+// Input: animation (string) path of the animation ("" for none), status (RunStatus) how far the run got,
+// failure (string) why it failed ("" if it didn't).
+func (record *RunRecord) Finish(animation string, status RunStatus, failure string) error {
+	record.Status = status
+	record.State = status.State
+	record.Message = status.String()
+	if failure != "" {
+		record.State = "failed"
+		record.Message = "The simulation failed: " + failure
+	}
+	if animation != "" {
+		if relative, err := filepath.Rel(record.Dir(), animation); err == nil {
+			record.Animation = relative
+		}
+	}
+	return record.Save()
+}
+
+// DisplayState: Returns the state to show for the run. A run still marked "running" that isn't the
+// current run was interrupted, e.g. by the server stopping.
+func (record *RunRecord) DisplayState() string {
+	if record.State == "running" {
+		if run := CurrentRun(); run == nil || run.RunID() != record.ID || run.Finished() {
+			return "interrupted"
+		}
+	}
+	return record.State
+}
+
+// Runtime: Returns the run time rounded to a tenth of a second, or "" if the run hasn't finished.
+func (record *RunRecord) Runtime() string {
+	if record.Status.Seconds == 0 {
+		return ""
+	}
+	return time.Duration(record.Status.Seconds * float64(time.Second)).Round(100 * time.Millisecond).String()
+}
+
+// HasThumbnail: Reports whether the run wrote Thumbnail.png.
+func (record *RunRecord) HasThumbnail() bool {
+	_, err := os.Stat(filepath.Join(record.Dir(), "Thumbnail.png"))
+	return err == nil
+}
+
+// AnimationHTML: Returns the HTML showing the run's animation, or "" if it has none.
+func (record *RunRecord) AnimationHTML() htemplate.HTML {
+	if record.Animation == "" {
+		return ""
+	}
+	return htemplate.HTML(AnimationHTML(record.URL(record.Animation)))
+}
+
+// Files: Returns the paths of every file the run wrote, relative to its directory, in sorted order.
+func (record *RunRecord) Files() []string {
+	var files []string
+	filepath.Walk(record.Dir(), func(name string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			relative, _ := filepath.Rel(record.Dir(), name)
+			files = append(files, filepath.ToSlash(relative))
+		}
+		return nil
+	})
+	sort.Strings(files)
+	return files
+}
+
+// LoadRunRecord: Reads the record of the web run with the given ID.
+func LoadRunRecord(id string) (*RunRecord, error) {
+	if !runIDPattern.MatchString(id) {
+		return nil, os.ErrNotExist
+	}
+	record, err := ReadRunRecord(filepath.Join(RunsDir, id))
+	if err != nil {
+		return nil, err
+	}
+	record.ID = id
+	return record, nil
+}
+
+// ReadRunRecord: Reads run.json from the output directory of a run.
+func ReadRunRecord(dir string) (*RunRecord, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "run.json"))
+	if err != nil {
+		return nil, err
+	}
+	record := &RunRecord{dir: dir}
+	if err := json.Unmarshal(data, record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// LoadRunRecords: Reads the records of every run in RunsDir, newest first. Directories without a
+// readable run.json are skipped.
+func LoadRunRecords() []*RunRecord {
+	entries, err := os.ReadDir(RunsDir)
+	if err != nil {
+		return nil
+	}
+	var records []*RunRecord
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		if record, err := LoadRunRecord(entry.Name()); err == nil {
+			records = append(records, record)
+		}
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].Created.After(records[j].Created) })
+	return records
+}
+
+// historyHandler: Serves the list of past runs at /history and each run's pages below it:
+// /history/<id> shows the run, /history/<id>/download zips its files and POST /history/<id>/delete removes it.
+func historyHandler(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/history"), "/"), "/")
+	if parts[0] == "" {
+		ServePage(w, "history.html", LoadRunRecords())
+		return
+	}
+	record, err := LoadRunRecord(parts[0])
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	switch {
+	case len(parts) == 1:
+		ServePage(w, "run.html", record)
+	case len(parts) == 2 && parts[1] == "download":
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", "attachment; filename=\""+record.ID+".zip\"")
+		if err := ZipDirectory(w, record.Dir()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	case len(parts) == 2 && parts[1] == "delete" && r.Method == http.MethodPost:
+		if record.DisplayState() == "running" {
+			http.Error(w, "Can't delete a run that is still going. Cancel it first.", http.StatusConflict)
+			return
+		}
+		if err := os.RemoveAll(record.Dir()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, "/history", http.StatusSeeOther)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// ZipDirectory: Writes every file below dir to w as a zip archive, with paths relative to dir.
+func ZipDirectory(w io.Writer, dir string) error {
+	archive := zip.NewWriter(w)
+	err := filepath.Walk(dir, func(name string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		relative, err := filepath.Rel(dir, name)
+		if err != nil {
+			return err
+		}
+		header, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(relative)
+		header.Method = zip.Deflate
+		entry, err := archive.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		file, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		_, err = io.Copy(entry, file)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return archive.Close()
+}
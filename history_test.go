@@ -0,0 +1,63 @@
+package main
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRunRecords(t *testing.T) {
+	oldRunsDir := RunsDir
+	RunsDir = filepath.Join(t.TempDir(), "runs")
+	defer func() { RunsDir = oldRunsDir }()
+
+	first, err := NewRunRecord(url.Values{"numGens": {"10"}})
+	if err != nil {
+		t.Fatalf("Error! NewRunRecord failed: %v", err)
+	}
+	second, err := NewRunRecord(url.Values{"numGens": {"20"}})
+	if err != nil {
+		t.Fatalf("Error! NewRunRecord failed: %v", err)
+	}
+	if first.ID == second.ID {
+		t.Errorf("Error! Two runs were both given the ID %q", first.ID)
+	}
+	animation := filepath.Join(second.Dir(), "gifs", "CellMigration.out.gif")
+	if err := second.Finish(animation, RunStatus{State: "complete", Requested: 20, Generations: 20}, ""); err != nil {
+		t.Fatalf("Error! Finish failed: %v", err)
+	}
+
+	type test struct {
+		id     string
+		answer string // numGens of the loaded run, "" if it shouldn't load
+	}
+
+	tests := make([]test, 4)
+	tests[0].id, tests[0].answer = first.ID, "10"
+	tests[1].id, tests[1].answer = second.ID, "20"
+	tests[2].id, tests[2].answer = "..", ""
+	tests[3].id, tests[3].answer = "../"+second.ID, ""
+
+	for i, test := range tests {
+		record, err := LoadRunRecord(test.id)
+		outcome := ""
+		if err == nil {
+			outcome = record.Parameters["numGens"]
+		}
+		if outcome != test.answer {
+			t.Errorf("Error! For input test dataset %d, your code gives numGens %q, and the correct numGens is %q", i, outcome, test.answer)
+		}
+	}
+
+	record, _ := LoadRunRecord(second.ID)
+	if record.State != "complete" || record.Animation != filepath.Join("gifs", "CellMigration.out.gif") {
+		t.Errorf("Error! The finished run was saved with state %q and animation %q", record.State, record.Animation)
+	}
+	if err := os.RemoveAll(first.Dir()); err != nil {
+		t.Fatal(err)
+	}
+	if records := LoadRunRecords(); len(records) != 1 || records[0].ID != second.ID {
+		t.Errorf("Error! After deleting a run, your code lists %d runs, and the correct number is 1", len(records))
+	}
+}
@@ -2,7 +2,6 @@ package main
 
 import (
 	"math"
-	"math/rand"
 )
 
 // InitializeECM generates a new ECM object
@@ -16,6 +15,8 @@ func InitializeECM(numFibres, numCells int, width, speed float64, stiffness floa
 	var newECM ECM
 	newECM.fibres = InitializeFibres(numFibres, width)
 	newECM.cells = InitializeCells(numCells, width)
+	newECM.lineage = NewLineage(newECM.cells)
+	newECM.crosslinks = FindCrosslinks(newECM.fibres)
 	return &newECM
 }
 
@@ -30,17 +31,19 @@ func InitializeFibres(numFibres int, width float64) []*Fibre {
 
 		var newFibre Fibre
 
-		newFibre.length = rand.NormFloat64()*5.0 + 75.0 // the length is normally distributed with a mean of 75 micrometres and sd of 5 micrometres
+		newFibre.length = RandomFibreLength() // the length is normally distributed, by default with a mean of 75 micrometres and sd of 5 micrometres
 
-		newFibre.width = 0.2 // the width is 200nm = 0.2 micrometres
+		newFibre.width = FibreWidth // by default 200nm = 0.2 micrometres
 
 		// place fibres randomly on ECM. This value represents centre of the fibre
-		newFibre.position.x = rand.Float64() * width
-		newFibre.position.y = rand.Float64() * width
+		newFibre.position.x = rng.Float64() * width
+		newFibre.position.y = rng.Float64() * width
 
 		// randomly assign x-direction and calculate y-direction such that the vector is a unit vector (length = 1)
-		newFibre.direction.x = ((rand.Float64() - 0.5) * 2) // some random float in the interval [-1.0, 1.0)
+		newFibre.direction.x = ((rng.Float64() - 0.5) * 2) // some random float in the interval [-1.0, 1.0)
 		newFibre.direction.y = GenerateYDirection(newFibre.direction.x)
+		newFibre.ResetPivot() // keeps pivot + direction = center of fibre before any cell has pulled on it
+		newFibre.SetRest()
 
 		FibreArray[i] = &newFibre
 	}
@@ -60,25 +63,26 @@ func InitializeCells(numCells int, width float64) []*Cell {
 
 		var newCell Cell
 		newCell.label = i + 1
+		newCell.cellType = AssignCellType()
 
-		newCell.radius = 15.0 // in micrometres
-		newCell.height = 2.6  // in micrometres
+		newCell.radius = CellRadius // in micrometres
+		newCell.height = CellHeight // in micrometres
 		// newCell.speed = cellSpeed
-		newCell.integrin = 50                                                     // in %
-		newCell.shapeFactor = 16.7 * math.Sqrt(0.5*newCell.radius*newCell.height) // In Eqn S3, c = 16.7 * sqrt(0.5 * r * h)
-		newCell.viscocity = 100                                                   // in Poise
+		newCell.integrin = CellIntegrin                                   // in %
+		newCell.shapeFactor = ShapeFactor(newCell.radius, newCell.height) // In Eqn S3, c = k * sqrt(0.5 * r * h)
+		newCell.viscocity = CellViscosity                                 // in Poise
 
 		// place cell randomly on ECM
 
-		// newCell.position.x = width/4 + rand.Float64()*width/2
-		// newCell.position.y = width/4 + rand.Float64()*width/2
-		n := 0.125
-		newCell.position.x = width*n + rand.Float64()*width*(1-2*n)
-		newCell.position.y = width*n + rand.Float64()*width*(1-2*n)
+		// newCell.position.x = width/4 + rng.Float64()*width/2
+		// newCell.position.y = width/4 + rng.Float64()*width/2
+		n := PlacementMargin
+		newCell.position.x = width*n + rng.Float64()*width*(1-2*n)
+		newCell.position.y = width*n + rng.Float64()*width*(1-2*n)
 
 		// generate random direction for cell
-		newCell.projection.x = ((rand.Float64() - 0.5) * 2) // some random float in the interval [-1.0, 1.0)
-		newCell.projection.y = GenerateYDirection(newCell.position.x)
+		newCell.projection.x = ((rng.Float64() - 0.5) * 2) // some random float in the interval [-1.0, 1.0)
+		newCell.projection.y = GenerateYDirection(newCell.projection.x)
 
 		newCell.perimeterVertices = make([]OrderedPair, numDivisions)
 		newCell.springs = make([]PseudoSpring, numDivisions*2)
@@ -113,7 +117,7 @@ func InitializeCells(numCells int, width float64) []*Cell {
 func GenerateYDirection(xDirection float64) float64 {
 
 	// determine sign of y randomly
-	someInt := rand.Intn(2)
+	someInt := rng.Intn(2)
 	var sign float64
 	if someInt%2 == 0 {
 		sign = 1.0
@@ -122,7 +126,7 @@ func GenerateYDirection(xDirection float64) float64 {
 	}
 
 	// use pythagorean theorem to ensure magnitude of (x,y) is 1
-	y := sign * math.Sqrt(1-math.Pow(xDirection, 2))
+	y := sign * math.Sqrt(math.Max(0, 1-math.Pow(xDirection, 2))) // |x| > 1 would give NaN
 
 	return y
 }
@@ -131,7 +135,7 @@ func GenerateYDirection(xDirection float64) float64 {
 // Input: initial ECM object and number of generations
 // Output: A 2-dimensional array where there is a new []float64 array for every cell at every generation, containing the time point value, the cell label as well as the x and y coordinates.
 func InitializePositionArray(initialECM *ECM, numGens int) [][]float64 {
-	newArray := make([][]float64, (numGens+1)*len(initialECM.cells))
+	newArray := make([][]float64, 0, (numGens+1)*len(initialECM.cells))
 	initialTime := 0.0
 	for _, cell := range initialECM.cells {
 		values := make([]float64, 4)
@@ -139,6 +143,7 @@ func InitializePositionArray(initialECM *ECM, numGens int) [][]float64 {
 		values[1] = float64(cell.label)
 		values[2] = cell.position.x
 		values[3] = cell.position.y
+		newArray = append(newArray, values)
 	}
 	return newArray
 }
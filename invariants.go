@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strings"
+)
+
+// InvariantTolerance is how far a unit length, a fibre length or a pivot position may be off before the
+// invariant checker reports it, relative to the size of the quantity (or absolute below 1).
+const InvariantTolerance = 1e-6
+
+// InvariantViolation describes the first entity found breaking one of the invariants of the model.
+type InvariantViolation struct {
+	Generation int
+	Entity     string // e.g. "fibre 12" (its index) or "cell 3" (its label)
+	Rule       string // which invariant, e.g. "fibre direction is a unit vector"
+	State      string // the entity's fields, and its fields in the previous generation if it had any
+}
+
+// Error: Describes the violation in one line, e.g. "generation 40, cell 3: cell is on the board".
+func (v *InvariantViolation) Error() string {
+	return fmt.Sprintf("generation %d, %s: %s", v.Generation, v.Entity, v.Rule)
+}
+
+// FindInvariantViolation: Checks a generation of the simulation and returns the first invariant it breaks,
+// or nil if there is none. The invariants are:
+// every coordinate is finite, fibre lengths aren't negative, fibre directions are unit vectors, each fibre's pivot is on one of its ends,
+// cells are on the board (between 0 and ECMwidth), and fibres keep their lengths from one generation to the next.
+// Lengths can only be compared when no cell type degrades fibres, since removing degraded fibres shifts the rest.
+// Input: previous (*ECM) the generation before, nil for the first one, current (*ECM) the generation to check,
+// generation (int) its number.
+func FindInvariantViolation(previous, current *ECM, generation int) *InvariantViolation {
+	compareLengths := previous != nil
+	for _, cellType := range CellTypes {
+		if cellType.degradationRate > 0 {
+			compareLengths = false
+		}
+	}
+
+	for i, fibre := range current.fibres {
+		var before *Fibre
+		if compareLengths && i < len(previous.fibres) {
+			before = previous.fibres[i]
+		}
+		if rule := FibreInvariant(fibre, before); rule != "" {
+			state := fmt.Sprintf("%+v", *fibre)
+			if before != nil {
+				state += "\nprevious generation: " + fmt.Sprintf("%+v", *before)
+			}
+			return &InvariantViolation{generation, fmt.Sprintf("fibre %d", i), rule, state}
+		}
+	}
+	for _, cell := range current.cells {
+		if rule := CellInvariant(cell); rule != "" {
+			state := DescribeCell(cell)
+			if previous != nil {
+				for _, old := range previous.cells {
+					if old.label == cell.label {
+						state += "\nprevious generation: " + DescribeCell(old)
+					}
+				}
+			}
+			return &InvariantViolation{generation, fmt.Sprintf("cell %d", cell.label), rule, state}
+		}
+	}
+	return nil
+}
+
+// FibreInvariant: Returns the first invariant a fibre breaks, or "" if it breaks none.
+// Input: fibre (*Fibre) the fibre, before (*Fibre) the same fibre a generation earlier, nil to skip the length check.
+func FibreInvariant(fibre, before *Fibre) string {
+	if !IsFinite(fibre.length, fibre.position.x, fibre.position.y, fibre.pivot.x, fibre.pivot.y, fibre.direction.x, fibre.direction.y) {
+		return "fibre coordinates are finite"
+	}
+	if fibre.length < 0 {
+		return "fibre length is not negative"
+	}
+	if !WithinTolerance(fibre.direction.Magnitude(), 1) {
+		return "fibre direction is a unit vector"
+	}
+	endpoint1, endpoint2 := fibre.GetEndpoints()
+	offset := math.Min(ComputeDistance(fibre.pivot, endpoint1), ComputeDistance(fibre.pivot, endpoint2))
+	if offset > InvariantTolerance*math.Max(1, fibre.length) {
+		return "fibre pivot is on one of its ends"
+	}
+	if before != nil && !WithinTolerance(fibre.length, before.length) {
+		return "fibre length is preserved"
+	}
+	return ""
+}
+
+// CellInvariant: Returns the first invariant a cell breaks, or "" if it breaks none.
+func CellInvariant(cell *Cell) string {
+	if !IsFinite(cell.position.x, cell.position.y, cell.projection.x, cell.projection.y) {
+		return "cell coordinates are finite"
+	}
+	for _, vertex := range cell.perimeterVertices {
+		if !IsFinite(vertex.x, vertex.y) {
+			return "cell perimeter coordinates are finite"
+		}
+	}
+	if cell.position.x < 0 || cell.position.x > ECMwidth || cell.position.y < 0 || cell.position.y > ECMwidth {
+		return "cell is on the board"
+	}
+	return ""
+}
+
+// DescribeCell: Writes out the fields of a cell for the invariant reports. The springs are given by their
+// resting lengths, since the addresses of their ends mean nothing once the run has stopped.
+func DescribeCell(cell *Cell) string {
+	restLengths := make([]float64, len(cell.springs))
+	for i, spring := range cell.springs {
+		restLengths[i] = spring.x0
+	}
+	return fmt.Sprintf("{radius:%v height:%v integrin:%v shapeFactor:%v viscocity:%v position:%+v projection:%+v perimeterVertices:%+v springRestLengths:%v label:%d cellType:%d}",
+		cell.radius, cell.height, cell.integrin, cell.shapeFactor, cell.viscocity, cell.position, cell.projection,
+		cell.perimeterVertices, restLengths, cell.label, cell.cellType)
+}
+
+// CheckInvariantOptions: Panics if the invariants are to be checked in a run that can't check them.
+// The checks are written for the 2D model, which is the only one SimulateCellMotility runs.
+func CheckInvariantOptions() {
+	if CheckInvariants && Dimensions == 3 {
+		panic("Error: the invariants can only be checked in 2D runs.")
+	}
+}
+
+// IsFinite: Reports whether none of the values is NaN or infinite.
+func IsFinite(values ...float64) bool {
+	for _, value := range values {
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			return false
+		}
+	}
+	return true
+}
+
+// WithinTolerance: Reports whether value is within InvariantTolerance of expected, relative to expected when it is above 1.
+func WithinTolerance(value, expected float64) bool {
+	return math.Abs(value-expected) <= InvariantTolerance*math.Max(1, math.Abs(expected))
+}
+
+// WriteToFile: Writes the violation, the state of the entity and every cell and fibre of the generation it was
+// found in to a text file, so the run can be debugged after it has stopped.
+func (v *InvariantViolation) WriteToFile(ecm *ECM, filename string) {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "Invariant violated: %s\n\n%s\n%s\n\nECM width %g\n\n", v.Error(), v.Entity, v.State, ECMwidth)
+	fmt.Fprintf(&builder, "Cells (%d):\n", len(ecm.cells))
+	for _, cell := range ecm.cells {
+		fmt.Fprintf(&builder, "cell %d: %s\n", cell.label, DescribeCell(cell))
+	}
+	fmt.Fprintf(&builder, "\nFibres (%d):\n", len(ecm.fibres))
+	for i, fibre := range ecm.fibres {
+		fmt.Fprintf(&builder, "fibre %d: %+v\n", i, *fibre)
+	}
+	if err := os.WriteFile(filename, []byte(builder.String()), 0644); err != nil {
+		panic("Error writing " + filename + ".")
+	}
+}
+
+// CheckGeneration: Stops the run with a panic naming the first invariant a generation breaks, after writing the
+// whole generation to InvariantViolation.txt. Called after every UpdateECM when CheckInvariants is set.
+func CheckGeneration(previous, current *ECM, generation int) {
+	violation := FindInvariantViolation(previous, current, generation)
+	if violation == nil {
+		return
+	}
+	violation.WriteToFile(current, OutputPath("InvariantViolation.txt"))
+	panic("Error: invariant violated at " + violation.Error() + ". " + violation.Entity + " is " + violation.State +
+		"\nThe whole generation was written to InvariantViolation.txt.")
+}
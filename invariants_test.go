@@ -0,0 +1,66 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestFindInvariantViolation(t *testing.T) {
+	type test struct {
+		change func(e *ECM)
+		answer string // the violation's Error(), "" for none
+	}
+
+	tests := make([]test, 7)
+	tests[0].change = func(e *ECM) {}
+	tests[0].answer = ""
+	tests[1].change = func(e *ECM) { e.cells[1].position.x = math.NaN() }
+	tests[1].answer = "generation 3, cell 7: cell coordinates are finite"
+	tests[2].change = func(e *ECM) { e.cells[0].position.y = 101 }
+	tests[2].answer = "generation 3, cell 4: cell is on the board"
+	tests[3].change = func(e *ECM) { e.fibres[1].direction = OrderedPair{0.5, 0} }
+	tests[3].answer = "generation 3, fibre 1: fibre direction is a unit vector"
+	tests[4].change = func(e *ECM) { e.fibres[0].pivot.x += 1 }
+	tests[4].answer = "generation 3, fibre 0: fibre pivot is on one of its ends"
+	tests[5].change = func(e *ECM) { e.fibres[1].length = 12; e.fibres[1].ResetPivot() }
+	tests[5].answer = "generation 3, fibre 1: fibre length is preserved"
+	// the first violation is reported, fibres before cells
+	tests[6].change = func(e *ECM) { e.cells[0].position.x = -1; e.fibres[1].position.y = math.Inf(1) }
+	tests[6].answer = "generation 3, fibre 1: fibre coordinates are finite"
+
+	width := ECMwidth
+	defer func() { ECMwidth = width }()
+	ECMwidth = 100
+	for i, test := range tests {
+		previous := &ECM{}
+		previous.cells = []*Cell{{label: 4, position: OrderedPair{10, 10}}, {label: 7, position: OrderedPair{90, 50}}}
+		previous.fibres = []*Fibre{
+			{length: 10, position: OrderedPair{50, 50}, direction: OrderedPair{0, 1}},
+			{length: 20, position: OrderedPair{20, 80}, direction: OrderedPair{0.6, -0.8}},
+		}
+		for _, fibre := range previous.fibres {
+			fibre.ResetPivot()
+		}
+		current := previous.CopyECM()
+		test.change(current)
+
+		outcome := ""
+		if violation := FindInvariantViolation(previous, current, 3); violation != nil {
+			outcome = violation.Error()
+		}
+		if outcome != test.answer {
+			t.Errorf("Error! For input test dataset %d, your code gives %q, and the correct violation is %q.", i, outcome, test.answer)
+		}
+	}
+}
+
+func TestDescribeCell(t *testing.T) {
+	cell := Cell{label: 2, radius: 1, position: OrderedPair{5, 5}}
+	cell.perimeterVertices = []OrderedPair{{6, 5}, {5, 6}}
+	cell.springs = []PseudoSpring{{&cell.perimeterVertices[0], &cell.perimeterVertices[1], 1.5}, {&cell.perimeterVertices[1], &cell.position, 1}}
+	outcome := DescribeCell(&cell)
+	if !strings.Contains(outcome, "springRestLengths:[1.5 1]") || strings.Contains(outcome, "0x") {
+		t.Errorf("Error! Your code describes the cell as %s, and the correct description gives the springs' rest lengths [1.5 1].", outcome)
+	}
+}
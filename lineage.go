@@ -0,0 +1,248 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Event types recorded in the lineage event log.
+const (
+	EventBirth = "birth"
+	EventDeath = "death"
+)
+
+// Lineage keeps track of every cell that ever existed during a simulation, who its parent was,
+// and when it was born and died. A single Lineage is shared by all ECM time frames of a run.
+type Lineage struct {
+	nodes     map[int]*LineageNode
+	roots     []int
+	events    []CellEvent
+	nextLabel int
+}
+
+// LineageNode is a single cell in the lineage tree.
+type LineageNode struct {
+	Label     int     `json:"label"`
+	Parent    int     `json:"parent"` // 0 for cells present at the start of the simulation
+	Children  []int   `json:"children"`
+	BirthTime float64 `json:"birthTime"`
+	DeathTime float64 `json:"deathTime"` // -1 if the cell is still alive at the end of the simulation
+	Fate      string  `json:"fate"`      // "divided", "apoptosis" or "alive"
+}
+
+// CellEvent is a birth or death event of a single cell.
+type CellEvent struct {
+	time          float64
+	kind          string
+	label, parent int
+	position      OrderedPair
+}
+
+// NewLineage creates a lineage whose roots are the given initial cells.
+// Input: cells ([]*Cell) the cells present at time 0.
+// Output: (*Lineage) pointer to the new Lineage object.
+func NewLineage(cells []*Cell) *Lineage {
+	var lineage Lineage
+	lineage.nodes = make(map[int]*LineageNode)
+	for _, cell := range cells {
+		lineage.nodes[cell.label] = &LineageNode{Label: cell.label, DeathTime: -1, Fate: "alive"}
+		lineage.roots = append(lineage.roots, cell.label)
+		if cell.label >= lineage.nextLabel {
+			lineage.nextLabel = cell.label + 1
+		}
+	}
+	return &lineage
+}
+
+// RecordBirth: Adds a newly born cell to the lineage and logs the birth event.
+// Input: cell (*Cell) the new cell, parent (int) label of the parent cell, time (float64) time of birth.
+func (l *Lineage) RecordBirth(cell *Cell, parent int, time float64) {
+	l.nodes[cell.label] = &LineageNode{Label: cell.label, Parent: parent, BirthTime: time, DeathTime: -1, Fate: "alive"}
+	if parentNode, ok := l.nodes[parent]; ok {
+		parentNode.Children = append(parentNode.Children, cell.label)
+	}
+	l.events = append(l.events, CellEvent{time: time, kind: EventBirth, label: cell.label, parent: parent, position: cell.position})
+}
+
+// RecordDeath: Marks a cell as dead in the lineage and logs the death event.
+// Input: cell (*Cell) the cell that died, fate (string) why the cell is gone, time (float64) time of death.
+func (l *Lineage) RecordDeath(cell *Cell, fate string, time float64) {
+	var parent int
+	if node, ok := l.nodes[cell.label]; ok {
+		node.DeathTime = time
+		node.Fate = fate
+		parent = node.Parent
+	}
+	l.events = append(l.events, CellEvent{time: time, kind: EventDeath, label: cell.label, parent: parent, position: cell.position})
+}
+
+// NewLabel: Returns an unused cell label.
+func (l *Lineage) NewLabel() int {
+	label := l.nextLabel
+	l.nextLabel++
+	return label
+}
+
+// CheckLineageOptions: Panics if the daughter placement or the lineage format isn't one of the known values.
+func CheckLineageOptions() {
+	switch DaughterPlacement {
+	case "random", "projection":
+	default:
+		panic("Error: unknown daughter placement " + DaughterPlacement + ".")
+	}
+	switch LineageFormat {
+	case "newick", "json":
+	default:
+		panic("Error: unknown lineage format " + LineageFormat + ".")
+	}
+}
+
+// ProliferateCells: Lets each cell divide or undergo apoptosis during a time step.
+// Division and apoptosis are modelled as Poisson processes: division happens with rate ln(2)/CellDoublingTime
+// and apoptosis with rate CellApoptosisRate. A dividing cell is replaced by two daughters with new labels.
+// Input:
+// cells ([]*Cell): The cells on the ECM after they have moved this generation.
+// lineage (*Lineage): The lineage used to hand out labels and record events.
+// time (float64): The time step in hours.
+// timePoint (float64): The current simulation time.
+// Output: ([]*Cell) the cells alive at the end of the time step.
+func ProliferateCells(cells []*Cell, lineage *Lineage, time, timePoint float64) []*Cell {
+	if lineage == nil || (CellDoublingTime <= 0 && CellApoptosisRate <= 0) {
+		return cells
+	}
+	var pDivide, pDie float64
+	if CellDoublingTime > 0 {
+		pDivide = 1 - math.Exp(-math.Ln2*time/CellDoublingTime)
+	}
+	if CellApoptosisRate > 0 {
+		pDie = 1 - math.Exp(-CellApoptosisRate*time)
+	}
+
+	newCells := make([]*Cell, 0, len(cells))
+	for _, cell := range cells {
+		if rng.Float64() < pDie {
+			lineage.RecordDeath(cell, "apoptosis", timePoint)
+			continue
+		}
+		if rng.Float64() < pDivide {
+			daughter1, daughter2 := cell.Divide(lineage.NewLabel(), lineage.NewLabel())
+			lineage.RecordDeath(cell, "divided", timePoint)
+			lineage.RecordBirth(daughter1, cell.label, timePoint)
+			lineage.RecordBirth(daughter2, cell.label, timePoint)
+			newCells = append(newCells, daughter1, daughter2)
+			continue
+		}
+		newCells = append(newCells, cell)
+	}
+	return newCells
+}
+
+// Divide: Splits a cell into two daughter cells. The daughters are placed half a radius away from the
+// parent's center on opposite sides. With DaughterPlacement "projection" the division axis is the parent's
+// projection vector, otherwise it is random.
+// Input: label1, label2 (int) the labels of the two daughters.
+// Output: (*Cell, *Cell) the two daughter cells.
+func (c *Cell) Divide(label1, label2 int) (*Cell, *Cell) {
+	var axis OrderedPair
+	if DaughterPlacement == "projection" && c.projection.Magnitude() > 0 {
+		axis = c.projection
+		axis.Normalize()
+	} else {
+		angle := rng.Float64() * 2 * math.Pi
+		axis.x = math.Cos(angle)
+		axis.y = math.Sin(angle)
+	}
+	offset := MultiplyVectorByConstant2D(axis, 0.5*c.radius)
+
+	daughter1 := c.CopyCell()
+	daughter1.label = label1
+	daughter1.Translate(offset)
+
+	daughter2 := c.CopyCell()
+	daughter2.label = label2
+	daughter2.Translate(MultiplyVectorByConstant2D(offset, -1.0))
+	daughter2.projection = MultiplyVectorByConstant2D(c.projection, -1.0)
+
+	return daughter1, daughter2
+}
+
+// ToNewick: Converts the lineage into Newick format. Every initial cell is the root of its own tree,
+// so the output has one tree per line. Branch lengths are cell lifetimes in hours.
+// Input: endTime (float64) the time at the end of the simulation, used as the end of living cells' branches.
+// Output: (string) the lineage forest in Newick format.
+func (l *Lineage) ToNewick(endTime float64) string {
+	var builder strings.Builder
+	for _, root := range l.roots {
+		builder.WriteString(l.newickSubtree(root, endTime))
+		builder.WriteString(";\n")
+	}
+	return builder.String()
+}
+
+// newickSubtree: Recursively builds the Newick string of the subtree rooted at label.
+func (l *Lineage) newickSubtree(label int, endTime float64) string {
+	node := l.nodes[label]
+	var subtree string
+	if len(node.Children) > 0 {
+		children := make([]string, len(node.Children))
+		for i, child := range node.Children {
+			children[i] = l.newickSubtree(child, endTime)
+		}
+		subtree = "(" + strings.Join(children, ",") + ")"
+	}
+	end := node.DeathTime
+	if end < 0 {
+		end = endTime
+	}
+	return subtree + "cell" + strconv.Itoa(label) + ":" + strconv.FormatFloat(end-node.BirthTime, 'f', -1, 64)
+}
+
+// WriteLineageToFile: Writes the lineage tree to a file in either "newick" or "json" format.
+// Input: l (*Lineage) the lineage, filename (string) the output file without extension, format (string)
+// the output format, endTime (float64) the time at the end of the simulation.
+func (l *Lineage) WriteLineageToFile(filename, format string, endTime float64) {
+	var contents []byte
+	switch format {
+	case "json":
+		nodes := make([]*LineageNode, 0, len(l.nodes))
+		for _, node := range l.nodes {
+			nodes = append(nodes, node)
+		}
+		sort.Slice(nodes, func(i, j int) bool { return nodes[i].Label < nodes[j].Label })
+		var err error
+		contents, err = json.MarshalIndent(nodes, "", "  ")
+		if err != nil {
+			panic("Error encoding lineage as JSON.")
+		}
+		filename += ".json"
+	case "newick":
+		contents = []byte(l.ToNewick(endTime))
+		filename += ".nwk"
+	default:
+		panic("Error: unknown lineage format " + format + ".")
+	}
+	err := os.WriteFile(filename, contents, 0644)
+	if err != nil {
+		panic("Error writing lineage file.")
+	}
+}
+
+// WriteEventsToFile: Writes every birth and death event to a csv file with the columns
+// time, event, cell label, parent label, x, y.
+func (l *Lineage) WriteEventsToFile(filename string) {
+	var builder strings.Builder
+	for _, event := range l.events {
+		fmt.Fprintf(&builder, "%s,%s,%d,%d,%s,%s\n",
+			strconv.FormatFloat(event.time, 'f', 1, 64), event.kind, event.label, event.parent,
+			strconv.FormatFloat(event.position.x, 'f', -1, 64), strconv.FormatFloat(event.position.y, 'f', -1, 64))
+	}
+	err := os.WriteFile(filename, []byte(builder.String()), 0644)
+	if err != nil {
+		panic("Error writing output csv file for cell events.")
+	}
+}
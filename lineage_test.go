@@ -0,0 +1,245 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestToNewick(t *testing.T) {
+	var parent Cell
+	parent.label = 1
+	lineage := NewLineage([]*Cell{&parent})
+
+	var daughter1, daughter2 Cell
+	daughter1.label = lineage.NewLabel()
+	daughter2.label = lineage.NewLabel()
+	lineage.RecordDeath(&parent, "divided", 2.0)
+	lineage.RecordBirth(&daughter1, parent.label, 2.0)
+	lineage.RecordBirth(&daughter2, parent.label, 2.0)
+	lineage.RecordDeath(&daughter2, "apoptosis", 3.5)
+
+	answer := "(cell2:3,cell3:1.5)cell1:2;\n"
+	outcome := lineage.ToNewick(5.0)
+	if outcome != answer {
+		t.Errorf("Error! Your code gives %q, and the correct Newick string is %q", outcome, answer)
+	}
+
+	if len(lineage.events) != 4 {
+		t.Errorf("Error! Expected 4 events to be logged, got %d", len(lineage.events))
+	}
+}
+
+// TestUpdateECMRecordsDivisions makes every cell divide in the first time step and checks that the positions
+// recorded at the end of the step are those of the daughters, not of their parents.
+func TestUpdateECMRecordsDivisions(t *testing.T) {
+	doublingTime, apoptosisRate := CellDoublingTime, CellApoptosisRate
+	defer func() { CellDoublingTime, CellApoptosisRate = doublingTime, apoptosisRate }()
+	CellDoublingTime, CellApoptosisRate = 1e-9, 0
+
+	SeedRandom(1)
+	ecm := InitializeECM(10, 3, 200, 10, 0.5)
+	positionArray := InitializePositionArray(ecm, 1)
+	_, next, positionArray := ecm.UpdateECM(1, 0, positionArray)
+
+	if len(next.cells) != 6 {
+		t.Fatalf("Error! For 3 dividing cells, your code gives %d cells, and the correct number is 6", len(next.cells))
+	}
+	if len(positionArray) != 3+6 {
+		t.Fatalf("Error! Your code records %d positions, and the correct number is %d", len(positionArray), 3+6)
+	}
+	for i, cell := range next.cells {
+		row := positionArray[3+i]
+		answer := []float64{1, float64(cell.label), cell.position.x, cell.position.y}
+		for j := range answer {
+			if row[j] != answer[j] {
+				t.Errorf("Error! For daughter %d, your code records %v, and the correct row is %v", i, row, answer)
+				break
+			}
+		}
+	}
+}
+
+func TestCheckLineageOptions(t *testing.T) {
+	type test struct {
+		placement, format string
+		answer            bool // whether the options are rejected
+	}
+
+	tests := make([]test, 6)
+	tests[0] = test{"random", "newick", false}
+	tests[1] = test{"projection", "json", false}
+	tests[2] = test{"projected", "newick", true}
+	tests[3] = test{"", "json", true}
+	tests[4] = test{"random", "nexus", true}
+	tests[5] = test{"projection", "Newick", true}
+
+	placement, format := DaughterPlacement, LineageFormat
+	defer func() { DaughterPlacement, LineageFormat = placement, format }()
+	for i, test := range tests {
+		DaughterPlacement, LineageFormat = test.placement, test.format
+		outcome := Panics(CheckLineageOptions)
+		if outcome != test.answer {
+			t.Errorf("Error! For input test dataset %d, your code gives %v, and the correct answer is %v.", i, outcome, test.answer)
+		}
+	}
+}
+
+// TestProliferateCells lets 4000 cells divide and die for one time step and checks that the numbers of divisions
+// and deaths are within 4 standard deviations of those the doubling time and apoptosis rate give.
+func TestProliferateCells(t *testing.T) {
+	type test struct {
+		doublingTime, apoptosisRate, time float64
+	}
+
+	tests := make([]test, 4)
+	tests[0] = test{2, 0.1, 0.5}
+	tests[1] = test{10, 0, 0.75}
+	tests[2] = test{0, 1, 0.25}
+	tests[3] = test{0.5, 2, 1}
+
+	doublingTime, apoptosisRate, width := CellDoublingTime, CellApoptosisRate, ECMwidth
+	defer func() { CellDoublingTime, CellApoptosisRate, ECMwidth = doublingTime, apoptosisRate, width }()
+	ECMwidth = 500
+	const numCells = 4000
+	for i, test := range tests {
+		CellDoublingTime, CellApoptosisRate = test.doublingTime, test.apoptosisRate
+		cells := make([]*Cell, numCells)
+		for j := range cells {
+			cells[j] = &Cell{label: j + 1, radius: 10, position: OrderedPair{250, 250}}
+		}
+		lineage := NewLineage(cells)
+
+		SeedRandom(int64(i + 1))
+		newCells := ProliferateCells(cells, lineage, test.time, 1)
+
+		var numDivided, numDied int
+		for _, event := range lineage.events {
+			if event.kind == EventDeath && lineage.nodes[event.label].Fate == "divided" {
+				numDivided++
+			} else if event.kind == EventDeath {
+				numDied++
+			}
+		}
+		if len(newCells) != numCells+numDivided-numDied {
+			t.Errorf("Error! For input test dataset %d, your code gives %d cells after %d divisions and %d deaths, and the correct number is %d", i, len(newCells), numDivided, numDied, numCells+numDivided-numDied)
+		}
+
+		var pDie, pDivide float64
+		if test.apoptosisRate > 0 {
+			pDie = 1 - math.Exp(-test.apoptosisRate*test.time)
+		}
+		if test.doublingTime > 0 {
+			pDivide = (1 - pDie) * (1 - math.Exp(-math.Ln2*test.time/test.doublingTime))
+		}
+		for _, count := range []struct {
+			name    string
+			outcome int
+			p       float64
+		}{{"deaths", numDied, pDie}, {"divisions", numDivided, pDivide}} {
+			mean := numCells * count.p
+			sd := math.Sqrt(numCells * count.p * (1 - count.p))
+			if math.Abs(float64(count.outcome)-mean) > 4*sd {
+				t.Errorf("Error! For input test dataset %d, your code gives %d %s, and the expected number is %.1f +- %.1f", i, count.outcome, count.name, mean, 4*sd)
+			}
+		}
+	}
+}
+
+// TestDivide checks that daughters are placed half a radius from the parent on opposite sides, along the
+// parent's projection for "projection" and along an axis that changes between divisions for "random".
+func TestDivide(t *testing.T) {
+	type test struct {
+		placement  string
+		projection OrderedPair
+		axis       OrderedPair // the expected division axis, or (0, 0) if it is random
+	}
+
+	tests := make([]test, 4)
+	tests[0] = test{"projection", OrderedPair{3, 4}, OrderedPair{0.6, 0.8}}
+	tests[1] = test{"projection", OrderedPair{-2, 0}, OrderedPair{-1, 0}}
+	// without a projection there is no axis to divide along
+	tests[2] = test{"projection", OrderedPair{0, 0}, OrderedPair{0, 0}}
+	tests[3] = test{"random", OrderedPair{3, 4}, OrderedPair{0, 0}}
+
+	placement, width := DaughterPlacement, ECMwidth
+	defer func() { DaughterPlacement, ECMwidth = placement, width }()
+	ECMwidth = 500
+	SeedRandom(1)
+	for i, test := range tests {
+		DaughterPlacement = test.placement
+		parent := Cell{label: 1, radius: 10, position: OrderedPair{100, 200}, projection: test.projection}
+		var axes []OrderedPair
+		for j := 0; j < 10; j++ {
+			daughter1, daughter2 := parent.Divide(2, 3)
+			if daughter1.label != 2 || daughter2.label != 3 {
+				t.Errorf("Error! For input test dataset %d, your code labels the daughters %d and %d, and the correct labels are 2 and 3", i, daughter1.label, daughter2.label)
+			}
+			offset1 := OrderedPair{daughter1.position.x - parent.position.x, daughter1.position.y - parent.position.y}
+			offset2 := OrderedPair{daughter2.position.x - parent.position.x, daughter2.position.y - parent.position.y}
+			if math.Abs(offset1.Magnitude()-5) > 1e-9 || math.Abs(offset1.x+offset2.x) > 1e-9 || math.Abs(offset1.y+offset2.y) > 1e-9 {
+				t.Errorf("Error! For input test dataset %d, your code places the daughters at %v and %v, and they should be 5 uM from (100, 200) on opposite sides", i, daughter1.position, daughter2.position)
+			}
+			if daughter2.projection.x != -test.projection.x || daughter2.projection.y != -test.projection.y {
+				t.Errorf("Error! For input test dataset %d, your code gives the second daughter the projection %v, and the correct projection is the parent's reversed", i, daughter2.projection)
+			}
+			axes = append(axes, MultiplyVectorByConstant2D(offset1, 0.2))
+		}
+		if test.axis.Magnitude() > 0 {
+			for _, axis := range axes {
+				if math.Abs(axis.x-test.axis.x) > 1e-9 || math.Abs(axis.y-test.axis.y) > 1e-9 {
+					t.Errorf("Error! For input test dataset %d, your code divides along %v, and the correct axis is %v", i, axis, test.axis)
+					break
+				}
+			}
+		} else if math.Abs(axes[0].x-axes[1].x) < 1e-9 && math.Abs(axes[0].y-axes[1].y) < 1e-9 {
+			t.Errorf("Error! For input test dataset %d, your code divides along %v twice, and the axis should be random", i, axes[0])
+		}
+	}
+}
+
+// TestProliferateCellsLinks makes every cell divide twice and checks the parent and child links of the lineage.
+func TestProliferateCellsLinks(t *testing.T) {
+	doublingTime, apoptosisRate, width := CellDoublingTime, CellApoptosisRate, ECMwidth
+	defer func() { CellDoublingTime, CellApoptosisRate, ECMwidth = doublingTime, apoptosisRate, width }()
+	CellDoublingTime, CellApoptosisRate, ECMwidth = 1e-9, 0, 500
+
+	cells := []*Cell{{label: 1, radius: 10, position: OrderedPair{100, 100}}, {label: 2, radius: 10, position: OrderedPair{300, 300}}}
+	lineage := NewLineage(cells)
+	SeedRandom(1)
+	cells = ProliferateCells(cells, lineage, 1, 1)
+	cells = ProliferateCells(cells, lineage, 1, 2)
+
+	// cells 1 and 2 divide into 3, 4 and 5, 6, which divide into 7, 8 up to 13, 14
+	answer := map[int][]int{1: {3, 4}, 2: {5, 6}, 3: {7, 8}, 4: {9, 10}, 5: {11, 12}, 6: {13, 14}}
+	if len(cells) != 8 {
+		t.Fatalf("Error! Your code gives %d cells after two divisions of 2 cells, and the correct number is 8", len(cells))
+	}
+	for parent, children := range answer {
+		node := lineage.nodes[parent]
+		if node == nil || len(node.Children) != 2 || node.Children[0] != children[0] || node.Children[1] != children[1] {
+			t.Errorf("Error! For cell %d, your code gives the node %v, and the correct children are %v", parent, node, children)
+			continue
+		}
+		// the first generation divides at time 1 and the second at time 2
+		deathTime := 1.0
+		if parent > 2 {
+			deathTime = 2
+		}
+		if node.Fate != "divided" || node.DeathTime != deathTime {
+			t.Errorf("Error! For cell %d, your code gives the fate %q at %v, and the correct fate is \"divided\" at %v", parent, node.Fate, node.DeathTime, deathTime)
+		}
+		for _, child := range children {
+			if lineage.nodes[child] == nil || lineage.nodes[child].Parent != parent {
+				t.Errorf("Error! For cell %d, your code gives the node %v, and the correct parent is %d", child, lineage.nodes[child], parent)
+			}
+		}
+	}
+	for _, cell := range cells {
+		if node := lineage.nodes[cell.label]; node == nil || node.Fate != "alive" || len(node.Children) != 0 {
+			t.Errorf("Error! For living cell %d, your code gives the node %v, and it should be alive with no children", cell.label, node)
+		}
+	}
+	if roots := lineage.roots; len(roots) != 2 || roots[0] != 1 || roots[1] != 2 {
+		t.Errorf("Error! Your code gives the roots %v, and the correct roots are [1 2]", roots)
+	}
+}
@@ -0,0 +1,417 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// LiveFrame is one generation of a run as sent to the browser's live viewer.
+type LiveFrame struct {
+	Generation int          `json:"generation"`
+	Time       float64      `json:"time"`
+	Width      float64      `json:"width"`
+	Cells      []LiveCell   `json:"cells"`
+	Fibres     [][4]float64 `json:"fibres"` // x1, y1, x2, y2 of a sample of at most LiveFibreLimit fibres
+}
+
+// LiveCell is one cell in a LiveFrame. Polygon holds the perimeter of soft-body cells.
+type LiveCell struct {
+	Label   int          `json:"label"`
+	X       float64      `json:"x"`
+	Y       float64      `json:"y"`
+	Radius  float64      `json:"radius"`
+	Colour  string       `json:"colour"`
+	Polygon [][2]float64 `json:"polygon,omitempty"`
+}
+
+// generationObserver is called with every generation of a running simulation, including generation 0.
+// It may block, e.g. while the run is paused. nil when nobody is watching. Guarded by currentRunLock.
+var generationObserver func(frame LiveFrame)
+
+// SetGenerationObserver: Sets the function called with every generation, nil for none.
+func SetGenerationObserver(observer func(frame LiveFrame)) {
+	currentRunLock.Lock()
+	defer currentRunLock.Unlock()
+	generationObserver = observer
+}
+
+// ObserveGeneration: Passes a generation to the observer, building the frame only if someone is watching.
+// The observer is called without holding the lock, since it blocks while the run is paused.
+func ObserveGeneration(makeFrame func() LiveFrame) {
+	currentRunLock.Lock()
+	observer := generationObserver
+	currentRunLock.Unlock()
+	if observer != nil {
+		observer(makeFrame())
+	}
+}
+
+// ToLiveFrame: Converts an ECM to a LiveFrame. Coordinates are rounded to 0.1 micrometres and only every
+// k-th fibre is kept so there are at most LiveFibreLimit, which keeps each frame small enough to stream.
+func (e *ECM) ToLiveFrame(generation int, time float64) LiveFrame {
+	frame := LiveFrame{Generation: generation, Time: time, Width: ECMwidth}
+	step := LiveFibreStep(len(e.fibres))
+	for i := 0; i < len(e.fibres); i += step {
+		f := e.fibres[i]
+		half := MultiplyVectorByConstant2D(f.direction, 0.5*f.length/f.direction.Magnitude())
+		frame.Fibres = append(frame.Fibres, [4]float64{Round1(f.position.x - half.x), Round1(f.position.y - half.y),
+			Round1(f.position.x + half.x), Round1(f.position.y + half.y)})
+	}
+	for _, c := range e.cells {
+		cell := LiveCell{Label: c.label, X: Round1(c.position.x), Y: Round1(c.position.y), Radius: c.radius, Colour: SVGColour(CellColour(c))}
+		if SoftBodyCells {
+			for _, vertex := range c.perimeterVertices {
+				cell.Polygon = append(cell.Polygon, [2]float64{Round1(vertex.x), Round1(vertex.y)})
+			}
+		}
+		frame.Cells = append(frame.Cells, cell)
+	}
+	return frame
+}
+
+// ToLiveFrame: Converts a 3D ECM to a LiveFrame by projecting it onto the xy plane.
+func (e *ECM3D) ToLiveFrame(generation int, time float64) LiveFrame {
+	frame := LiveFrame{Generation: generation, Time: time, Width: ECMwidth}
+	step := LiveFibreStep(len(e.fibres))
+	for i := 0; i < len(e.fibres); i += step {
+		endpoint1, endpoint2 := e.fibres[i].GetEndpoints()
+		frame.Fibres = append(frame.Fibres, [4]float64{Round1(endpoint1.x), Round1(endpoint1.y), Round1(endpoint2.x), Round1(endpoint2.y)})
+	}
+	for _, c := range e.cells {
+		frame.Cells = append(frame.Cells, LiveCell{Label: c.label, X: Round1(c.position.x), Y: Round1(c.position.y),
+			Radius: c.radius, Colour: "rgb(200,150,200)"})
+	}
+	return frame
+}
+
+// LiveFibreStep: Returns k such that keeping every k-th of numFibres fibres keeps at most LiveFibreLimit.
+func LiveFibreStep(numFibres int) int {
+	if LiveFibreLimit <= 0 || numFibres <= LiveFibreLimit {
+		return 1
+	}
+	return int(math.Ceil(float64(numFibres) / float64(LiveFibreLimit)))
+}
+
+// Round1: Rounds x to one decimal place.
+func Round1(x float64) float64 {
+	return math.Round(x*10) / 10
+}
+
+// LiveRun is a simulation running in the background for the web app. It keeps every frame sent so far,
+// so a viewer that connects late still sees the whole run, and lets the viewer pause or cancel it.
+// The run is cancelled through its context, either from the Cancel button, when its time limit passes
+// or when every viewer has been gone for ViewerGracePeriod.
+type LiveRun struct {
+	lock     sync.Mutex
+	changed  *sync.Cond // broadcast whenever a frame is added or the state changes
+	numGens  int
+	frames   [][]byte // JSON of each LiveFrame so far
+	paused   bool
+	finished bool
+	result   []byte // JSON sent with the done event
+	ctx      context.Context
+	cancel   context.CancelFunc
+	viewers  int    // number of open event streams
+	runID    string // ID of the run's RunRecord
+}
+
+// LiveResult is sent to the viewer when a run ends.
+type LiveResult struct {
+	Status    string `json:"status"` // "complete", "cancelled", "timed out" or "failed"
+	Message   string `json:"message"`
+	Animation string `json:"animation"` // HTML showing the animation, empty if there isn't one
+	Run       string `json:"run"`       // link to the run's page in the history
+}
+
+// currentRun is the run the web app is showing. Only one simulation runs at a time because the
+// simulation parameters are global.
+var currentRun *LiveRun
+var currentRunLock sync.Mutex
+var liveRunsStopped bool // set when the server starts shutting down
+
+// Reasons StartLiveRun can't start a run.
+var ErrRunActive = errors.New("a simulation is already running")
+var ErrShuttingDown = errors.New("the server is shutting down")
+
+// StartLiveRun: Makes a new LiveRun the current run and sets the generation observer to feed it.
+// The caller sets numGens and the time limit with Start before starting the simulation.
+// Output: the run, or ErrRunActive if another run hasn't finished yet, or ErrShuttingDown.
+func StartLiveRun() (*LiveRun, error) {
+	currentRunLock.Lock()
+	defer currentRunLock.Unlock()
+	if liveRunsStopped {
+		return nil, ErrShuttingDown
+	}
+	if currentRun != nil && !currentRun.Finished() {
+		return nil, ErrRunActive
+	}
+	run := NewLiveRun()
+	currentRun = run
+	generationObserver = run.Observe
+	return run, nil
+}
+
+// NewLiveRun: Returns a run that nobody is watching yet and that hasn't been started.
+func NewLiveRun() *LiveRun {
+	run := &LiveRun{}
+	run.changed = sync.NewCond(&run.lock)
+	run.ctx, run.cancel = context.WithCancel(context.Background())
+	return run
+}
+
+// StopLiveRuns: Stops new runs from starting, then gives the current run up to grace to finish. A run that is
+// still going after that, or is paused, is cancelled, which saves the generations so far, and waited for.
+func StopLiveRuns(grace time.Duration) {
+	currentRunLock.Lock()
+	liveRunsStopped = true
+	run := currentRun
+	currentRunLock.Unlock()
+	if run == nil || run.WaitUntilFinished(grace) {
+		return
+	}
+	fmt.Println("Stopping the running simulation. Its results so far will be saved.")
+	run.Cancel()
+	run.WaitUntilFinished(0)
+}
+
+// Start: Sets the number of generations, the time limit (0 for none) and the RunRecord ID of the run.
+// Output: (context.Context) the context to run the simulation with.
+func (run *LiveRun) Start(numGens int, timeLimit time.Duration, runID string) context.Context {
+	run.lock.Lock()
+	defer run.lock.Unlock()
+	run.numGens = numGens
+	run.runID = runID
+	if timeLimit > 0 {
+		run.ctx, run.cancel = context.WithTimeout(run.ctx, timeLimit)
+	}
+	// wake a paused run when it is cancelled or times out
+	context.AfterFunc(run.ctx, func() {
+		run.lock.Lock()
+		defer run.lock.Unlock()
+		run.changed.Broadcast()
+	})
+	return run.ctx
+}
+
+// CurrentRun: Returns the run the web app is showing, or nil if there hasn't been one.
+func CurrentRun() *LiveRun {
+	currentRunLock.Lock()
+	defer currentRunLock.Unlock()
+	return currentRun
+}
+
+// Observe: Records a frame for the viewers, then waits while the run is paused.
+func (run *LiveRun) Observe(frame LiveFrame) {
+	data, err := json.Marshal(frame)
+	if err != nil {
+		panic("Error encoding live frame: " + err.Error())
+	}
+	run.lock.Lock()
+	defer run.lock.Unlock()
+	run.frames = append(run.frames, data)
+	run.changed.Broadcast()
+	for run.paused && run.ctx.Err() == nil {
+		run.changed.Wait()
+	}
+}
+
+// SetPaused: Pauses or resumes the run.
+func (run *LiveRun) SetPaused(paused bool) {
+	run.lock.Lock()
+	defer run.lock.Unlock()
+	run.paused = paused
+	run.changed.Broadcast()
+}
+
+// Cancel: Stops the run at the next generation or frame. The generations so far are still written out.
+func (run *LiveRun) Cancel() {
+	// Start replaces cancel when the run has a time limit
+	run.lock.Lock()
+	defer run.lock.Unlock()
+	run.cancel()
+}
+
+// Finish: Marks the run as over and records the result sent to viewers.
+// Input: animation (string) the exported animation's path ("" for none), status (RunStatus) how far the run got,
+// failure (string) why the run failed ("" if it didn't).
+func (run *LiveRun) Finish(animation string, status RunStatus, failure string) {
+	run.lock.Lock()
+	defer run.lock.Unlock()
+	result := LiveResult{Status: status.State, Message: status.String()}
+	if run.runID != "" {
+		result.Run = "/history/" + run.runID
+	}
+	if animation != "" {
+		result.Animation = AnimationHTML("/" + animation)
+	}
+	if failure != "" {
+		result.Status = "failed"
+		result.Message = "The simulation failed: " + failure
+	}
+	run.result, _ = json.Marshal(result)
+	run.finished = true
+	run.cancel() // release the timer of the time limit
+	run.changed.Broadcast()
+}
+
+// AddViewer: Records that a viewer opened an event stream.
+func (run *LiveRun) AddViewer() {
+	run.lock.Lock()
+	defer run.lock.Unlock()
+	run.viewers++
+}
+
+// RemoveViewer: Records that a viewer went away. If nobody is watching ViewerGracePeriod later
+// (browsers reconnect dropped streams by themselves), the tab was closed, so the run is cancelled.
+func (run *LiveRun) RemoveViewer() {
+	run.lock.Lock()
+	defer run.lock.Unlock()
+	run.viewers--
+	if run.viewers > 0 || run.finished {
+		return
+	}
+	time.AfterFunc(ViewerGracePeriod, func() {
+		run.lock.Lock()
+		abandoned := run.viewers == 0 && !run.finished
+		run.lock.Unlock()
+		if abandoned {
+			fmt.Println("Nobody is watching the simulation any more. Cancelling it.")
+			run.Cancel()
+		}
+	})
+}
+
+// RunID: Returns the ID of the run's RunRecord.
+func (run *LiveRun) RunID() string {
+	run.lock.Lock()
+	defer run.lock.Unlock()
+	return run.runID
+}
+
+// WaitUntilFinished: Blocks until the run is over or timeout passes. With a timeout, a paused run isn't waited for;
+// with 0 it waits until the run is over however long that takes.
+// Output: (bool) whether the run is over.
+func (run *LiveRun) WaitUntilFinished(timeout time.Duration) bool {
+	var expired bool
+	if timeout > 0 {
+		timer := time.AfterFunc(timeout, func() {
+			run.lock.Lock()
+			defer run.lock.Unlock()
+			expired = true
+			run.changed.Broadcast()
+		})
+		defer timer.Stop()
+	}
+	run.lock.Lock()
+	defer run.lock.Unlock()
+	for !run.finished && !expired && !(timeout > 0 && run.paused) {
+		run.changed.Wait()
+	}
+	return run.finished
+}
+
+// Finished: Reports whether the run is over.
+func (run *LiveRun) Finished() bool {
+	run.lock.Lock()
+	defer run.lock.Unlock()
+	return run.finished
+}
+
+// WaitForFrames: Blocks until there are frames after the first next, the run finishes or stop is closed.
+// Output: the new frames, and the result if the run has finished (nil otherwise).
+func (run *LiveRun) WaitForFrames(next int, stop <-chan struct{}) ([][]byte, []byte) {
+	// wake the wait below if the viewer goes away
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-stop:
+			run.lock.Lock()
+			run.changed.Broadcast()
+			run.lock.Unlock()
+		case <-done:
+		}
+	}()
+
+	run.lock.Lock()
+	defer run.lock.Unlock()
+	for len(run.frames) <= next && !run.finished {
+		select {
+		case <-stop:
+			return nil, nil
+		default:
+		}
+		run.changed.Wait()
+	}
+	frames := run.frames[next:]
+	if run.finished && len(frames) == 0 {
+		return nil, run.result
+	}
+	return frames, nil
+}
+
+// liveEventsHandler: Streams the current run to the browser as Server-Sent Events. Each generation is a
+// "frame" event holding a LiveFrame and the end of the run is a "done" event holding a LiveResult.
+func liveEventsHandler(w http.ResponseWriter, r *http.Request) {
+	run := CurrentRun()
+	flusher, ok := w.(http.Flusher)
+	if run == nil || !ok {
+		http.Error(w, "No simulation to watch.", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	run.AddViewer()
+	defer run.RemoveViewer()
+
+	next := 0
+	for {
+		frames, result := run.WaitForFrames(next, r.Context().Done())
+		if frames == nil && result == nil {
+			return // the viewer went away
+		}
+		for _, frame := range frames {
+			fmt.Fprintf(w, "event: frame\ndata: %s\n\n", frame)
+		}
+		next += len(frames)
+		if result != nil {
+			fmt.Fprintf(w, "event: done\ndata: %s\n\n", result)
+		}
+		flusher.Flush()
+		if result != nil {
+			return
+		}
+	}
+}
+
+// liveControlHandler: Pauses, resumes or cancels the current run. The action is the last part of the path,
+// e.g. POST /live/pause.
+func liveControlHandler(w http.ResponseWriter, r *http.Request) {
+	run := CurrentRun()
+	if run == nil {
+		http.Error(w, "No simulation is running.", http.StatusNotFound)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Use POST.", http.StatusMethodNotAllowed)
+		return
+	}
+	switch r.URL.Path {
+	case "/live/pause":
+		run.SetPaused(true)
+	case "/live/resume":
+		run.SetPaused(false)
+	case "/live/cancel":
+		run.Cancel()
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
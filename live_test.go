@@ -0,0 +1,118 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLiveFibreStep(t *testing.T) {
+	type test struct {
+		numFibres int
+		limit     int
+		answer    int
+	}
+
+	tests := make([]test, 4)
+	tests[0].numFibres, tests[0].limit, tests[0].answer = 7500, 1500, 5
+	tests[1].numFibres, tests[1].limit, tests[1].answer = 7501, 1500, 6
+	tests[2].numFibres, tests[2].limit, tests[2].answer = 1000, 1500, 1
+	tests[3].numFibres, tests[3].limit, tests[3].answer = 7500, 0, 1
+
+	defer func(limit int) { LiveFibreLimit = limit }(LiveFibreLimit)
+	for i, test := range tests {
+		LiveFibreLimit = test.limit
+		outcome := LiveFibreStep(test.numFibres)
+		if outcome != test.answer {
+			t.Errorf("Error! For input test dataset %d, your code gives %d, and the correct step is %d", i, outcome, test.answer)
+		}
+	}
+}
+
+// Finishes: Reports whether f returns within a second.
+func Finishes(f func()) bool {
+	done := make(chan struct{})
+	go func() {
+		f()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(time.Second):
+		return false
+	}
+}
+
+func TestObservePaused(t *testing.T) {
+	run := NewLiveRun()
+	run.Start(3, 0, "")
+	run.Observe(LiveFrame{Generation: 0})
+	run.SetPaused(true)
+
+	observed := make(chan struct{})
+	go func() {
+		run.Observe(LiveFrame{Generation: 1})
+		close(observed)
+	}()
+	select {
+	case <-observed:
+		t.Errorf("Error! Your code carries on while the run is paused, and the correct Observe waits.")
+	case <-time.After(50 * time.Millisecond):
+	}
+	run.SetPaused(false)
+	if !Finishes(func() { <-observed }) {
+		t.Errorf("Error! Your code stays paused after the run is resumed, and the correct Observe returns.")
+	}
+	if frames, _ := run.WaitForFrames(1, nil); len(frames) != 1 {
+		t.Errorf("Error! Your code records %d frames after the first, and the correct number is 1.", len(frames))
+	}
+}
+
+func TestObserveCancelled(t *testing.T) {
+	run := NewLiveRun()
+	run.Start(3, 0, "")
+	run.SetPaused(true)
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		run.Cancel()
+	}()
+	if !Finishes(func() { run.Observe(LiveFrame{Generation: 0}) }) {
+		t.Errorf("Error! Your code stays paused after the run is cancelled, and the correct Observe returns.")
+	}
+}
+
+// TestCancelWhileStarting cancels a run while it is being given a time limit. Run with -race, which reports
+// Cancel reading the cancel function that Start replaces.
+func TestCancelWhileStarting(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		run := NewLiveRun()
+		cancelled := make(chan struct{})
+		go func() {
+			run.Cancel()
+			close(cancelled)
+		}()
+		ctx := run.Start(3, time.Hour, "")
+		<-cancelled
+		if ctx.Err() == nil {
+			t.Fatalf("Error! For attempt %d, your code leaves the run going after it is cancelled, and the correct context is cancelled.", i)
+		}
+	}
+}
+
+func TestWaitForFramesStopped(t *testing.T) {
+	run := NewLiveRun()
+	run.Start(3, 0, "")
+	stop := make(chan struct{})
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		close(stop)
+	}()
+	var frames [][]byte
+	var result []byte
+	if !Finishes(func() { frames, result = run.WaitForFrames(0, stop) }) {
+		t.Fatalf("Error! Your code keeps waiting after the viewer has gone, and the correct WaitForFrames returns.")
+	}
+	if frames != nil || result != nil {
+		t.Errorf("Error! Your code gives %d frames and the result %s, and the correct answer is neither.", len(frames), result)
+	}
+}
@@ -1,17 +1,25 @@
 package main
 
 import (
+	"context"
 	"fmt"
-	"gifhelper"
+	"os"
+	"path/filepath"
 	"time"
 )
 
 func main() {
-	RunWebApp()
+	if len(os.Args) > 1 {
+		RunCommand(os.Args[1], os.Args[2:])
+		return
+	}
+	RunWebApp(nil)
 }
 
 // RunSimulation: Simulates cells on an ECM matrix for a given number of generations
 // Input:
+// ctx (context.Context): Stops the run early when cancelled or when its deadline passes. The generations
+// simulated so far are still written out, but nothing is drawn.
 // numGens (int): Number of generations to simulate the ECM.
 // numCells (int): Number of cells to put on the ECM.
 // numFibres (int): Number of fibres to put on the ECM.
@@ -19,18 +27,26 @@ func main() {
 // width (float64): The width and length of the ECM "board".
 // cellSpeed (float64): The speed at which cells travel on the ECM.
 // stiffness (float64): The stiffness of the ECM matrix.
-func RunSimulation(numGens, numCells, numFibres int, timeStep, width, cellSpeed, stiffness float64) {
+// Output:
+// (string): Path of the exported animation, or "" if DrawGIF is false or the run was stopped.
+// (RunStatus): How far the run got. Also written to RunStatus.json.
+func RunSimulation(ctx context.Context, numGens, numCells, numFibres int, timeStep, width, cellSpeed, stiffness float64) (string, RunStatus) {
 	// arguments: number of generations (int), number of cells (int), number of fibres (int)
+	if Dimensions == 3 {
+		return RunSimulation3D(ctx, numGens, numCells, numFibres, timeStep, width, cellSpeed, stiffness)
+	}
 
 	fmt.Println("Commands read in successfully.")
 
 	initialECM := InitializeECM(numFibres, numCells, width, cellSpeed, stiffness)
 
 	fmt.Println("ECM initialized. Beginning simulation.")
+	WriteModelParameters(OutputPath("ModelParameters.json"))
 
 	start := time.Now()
 
-	timeFrames, positionArray := SimulateCellMotility(initialECM, numGens, timeStep)
+	timeFrames, positionArray := SimulateCellMotility(ctx, initialECM, numGens, timeStep)
+	status := RunStatus{Requested: numGens, Generations: len(timeFrames) - 1}
 
 	fmt.Printf("Num Gens: %d, Time Step: %4.3f, Num Cells: %d, Num Fibres: %d, "+
 		" Stiffness: %4.3f, Cell Speed: %4.3f, Run Time: %s.\n",
@@ -38,19 +54,122 @@ func RunSimulation(numGens, numCells, numFibres int, timeStep, width, cellSpeed,
 		numFibres, stiffness, cellSpeed,
 		time.Since(start).Truncate(time.Millisecond))
 	// write data to files
-	WriteToFile(positionArray)
+	WriteToFile(positionArray, OutputPath("CellPosition.csv"))
+	finalECM := timeFrames[len(timeFrames)-1]
+	finalECM.lineage.WriteEventsToFile(OutputPath("CellEvents.csv"))
+	finalECM.lineage.WriteLineageToFile(OutputPath("CellLineage"), LineageFormat, float64(len(timeFrames)-1)*timeStep)
+	WriteAlignmentToFile(timeFrames, timeStep, OutputPath("Alignment"))
+	WriteMotilityToFile(positionArray, width, timeStep, OutputPath("Motility"))
+	if RecoilTime > 0 {
+		WriteRecoilToFile(timeFrames, timeStep, OutputPath("FibreRecoil.csv"))
+	}
+	if SoftBodyCells {
+		WriteShapeToFile(timeFrames, timeStep, OutputPath("CellShape.csv"))
+	}
+	WriteSnapshotsToFile(timeFrames, positionArray, timeStep, OutputPath("Snapshot"))
 
 	// generate graph of mean-squared deviation from results
-	PlotGraph(positionArray, numCells)
+	PlotGraph(positionArray)
+
+	if ThumbnailSize > 0 {
+		thumbnail := finalECM.DrawToCanvas(ThumbnailSize, 1, FrameOverlay{time: float64(len(timeFrames)-1) * timeStep})
+		if err := WritePNG(thumbnail, OutputPath("Thumbnail.png")); err != nil {
+			panic("Error writing thumbnail: " + err.Error())
+		}
+	}
+
+	if !DrawGIF || ctx.Err() != nil {
+		return "", FinishRun(ctx, status, start)
+	}
 
 	fmt.Println("Simulation successful! Now drawing ECM.")
 
 	frequency := 1
 	canvasWidth := 2000
 
-	imageList := DrawECM(timeFrames, canvasWidth, frequency, 1)
+	exporter := NewAnimationExporter(OutputFormat, OutputPath("gifs/CellMigration"))
+	numFrames, err := DrawECM(ctx, timeFrames, canvasWidth, frequency, 1, timeStep, exporter.AddFrame)
+	if err != nil {
+		return "", FinishDrawing(ctx, exporter, err, status, start)
+	}
+	status.Frames = numFrames
+
+	fmt.Println("Images drawn. Now exporting " + OutputFormat + ".")
+	animation := exporter.Finish(ctx)
+	fmt.Println("Animation written to " + animation + ".")
+	return animation, FinishRun(ctx, status, start)
+}
+
+// OutputPath: Returns where to write an output file: name inside OutputDir.
+func OutputPath(name string) string {
+	return filepath.Join(OutputDir, name)
+}
+
+// FinishRun: Fills in the state and run time of a run's status, prints it and writes it to RunStatus.json.
+func FinishRun(ctx context.Context, status RunStatus, start time.Time) RunStatus {
+	status.State = StateFromContext(ctx)
+	if status.State != "complete" {
+		status.Frames = 0
+	}
+	status.Seconds = time.Since(start).Seconds()
+	status.WriteToFile(OutputPath("RunStatus.json"))
+	fmt.Println(status)
+	return status
+}
+
+// FinishDrawing: Finishes a run whose drawing stopped with err before every frame was drawn. The frames already
+// handed to the exporter are waited for; the run gets no animation. A frame that couldn't be written is an error.
+func FinishDrawing(ctx context.Context, exporter *AnimationExporter, err error, status RunStatus, start time.Time) RunStatus {
+	exporter.Wait()
+	if ctx.Err() == nil {
+		panic("Error writing frames: " + err.Error())
+	}
+	return FinishRun(ctx, status, start)
+}
+
+// RunSimulation3D: Simulates cells in a 3D cube of ECM. Takes the same inputs as RunSimulation.
+// Trajectories (with z) are written to CellPosition3D.csv and the GIF shows either a slice or a projection
+// of the cube depending on RenderMode3D.
+func RunSimulation3D(ctx context.Context, numGens, numCells, numFibres int, timeStep, width, cellSpeed, stiffness float64) (string, RunStatus) {
+	initialECM := InitializeECM3D(numFibres, numCells, width, cellSpeed, stiffness)
+
+	fmt.Println("3D ECM initialized. Beginning simulation.")
+	WriteModelParameters(OutputPath("ModelParameters.json"))
+
+	start := time.Now()
+
+	timeFrames, positionArray := SimulateCellMotility3D(ctx, initialECM, numGens, timeStep)
+	status := RunStatus{Requested: numGens, Generations: len(timeFrames) - 1}
+
+	fmt.Printf("3D Num Gens: %d, Time Step: %4.3f, Num Cells: %d, Num Fibres: %d, "+
+		" Stiffness: %4.3f, Cell Speed: %4.3f, Run Time: %s.\n",
+		numGens, timeStep, numCells,
+		numFibres, stiffness, cellSpeed,
+		time.Since(start).Truncate(time.Millisecond))
+
+	WriteToFile3D(positionArray, OutputPath("CellPosition3D.csv"))
+	if ThumbnailSize > 0 {
+		thumbnail := timeFrames[len(timeFrames)-1].DrawToCanvas3D(ThumbnailSize, RenderMode3D)
+		if err := WritePNG(thumbnail, OutputPath("Thumbnail.png")); err != nil {
+			panic("Error writing thumbnail: " + err.Error())
+		}
+	}
+
+	if !DrawGIF || ctx.Err() != nil {
+		return "", FinishRun(ctx, status, start)
+	}
+
+	fmt.Println("Simulation successful! Now drawing ECM.")
+
+	exporter := NewAnimationExporter(OutputFormat, OutputPath("gifs/CellMigration"))
+	numFrames, err := DrawECM3D(ctx, timeFrames, 2000, RenderMode3D, exporter.AddFrame)
+	if err != nil {
+		return "", FinishDrawing(ctx, exporter, err, status, start)
+	}
+	status.Frames = numFrames
 
-	fmt.Println("Images drawn. Now generating GIF.")
-	gifhelper.ImagesToGIF(imageList, "gifs/CellMigration")
-	fmt.Println("GIF drawn.")
+	fmt.Println("Images drawn. Now exporting " + OutputFormat + ".")
+	animation := exporter.Finish(ctx)
+	fmt.Println("Animation written to " + animation + ".")
+	return animation, FinishRun(ctx, status, start)
 }
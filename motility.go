@@ -0,0 +1,333 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"strconv"
+)
+
+// Trajectory is the path of one cell, unwrapped so that crossing an edge of the torus doesn't look like a jump.
+type Trajectory struct {
+	label     int
+	times     []float64
+	positions []OrderedPair
+}
+
+// MotilityStats are the summary statistics of one trajectory, or the mean over a population of trajectories.
+type MotilityStats struct {
+	Label            int // 0 for the population
+	Steps            int
+	MeanSpeed        float64 // uM per hour
+	Directionality   float64 // net displacement / path length, between 0 and 1
+	Persistence      float64 // velocity autocorrelation at a lag of one step
+	MeanTurningAngle float64 // radians, mean absolute turning angle between steps
+	Tortuosity       float64 // path length / net displacement, at least 1
+}
+
+// ExtractTrajectories: Splits rows of [time, label, x, y] into one trajectory per cell label and unwraps the
+// periodic boundary. Any step longer than half the board is assumed to have crossed an edge.
+// Input: positionArray ([][]float64) rows of cell positions, nil rows are skipped. width (float64) the board width.
+// Output: ([]Trajectory) the trajectories sorted by label, each sorted by time.
+func ExtractTrajectories(positionArray [][]float64, width float64) []Trajectory {
+	byLabel := make(map[int]*Trajectory)
+	for _, row := range positionArray {
+		if row == nil {
+			continue
+		}
+		label := int(row[1])
+		trajectory, ok := byLabel[label]
+		if !ok {
+			trajectory = &Trajectory{label: label}
+			byLabel[label] = trajectory
+		}
+		trajectory.times = append(trajectory.times, row[0])
+		trajectory.positions = append(trajectory.positions, OrderedPair{row[2], row[3]})
+	}
+
+	trajectories := make([]Trajectory, 0, len(byLabel))
+	for _, trajectory := range byLabel {
+		sort.Sort(byTime(*trajectory))
+		trajectory.Unwrap(width)
+		trajectories = append(trajectories, *trajectory)
+	}
+	sort.Slice(trajectories, func(i, j int) bool { return trajectories[i].label < trajectories[j].label })
+	return trajectories
+}
+
+// byTime sorts the points of a trajectory by time.
+type byTime Trajectory
+
+func (t byTime) Len() int           { return len(t.times) }
+func (t byTime) Less(i, j int) bool { return t.times[i] < t.times[j] }
+func (t byTime) Swap(i, j int) {
+	t.times[i], t.times[j] = t.times[j], t.times[i]
+	t.positions[i], t.positions[j] = t.positions[j], t.positions[i]
+}
+
+// Unwrap: Removes the jumps caused by the periodic boundary so the trajectory is continuous.
+// Input: width (float64) the width of the board. Does nothing if width <= 0.
+func (t *Trajectory) Unwrap(width float64) {
+	if width <= 0 {
+		return
+	}
+	var shift OrderedPair
+	previous := OrderedPair{}
+	for i := range t.positions {
+		raw := t.positions[i]
+		if i > 0 {
+			dx := raw.x - previous.x
+			dy := raw.y - previous.y
+			shift.x -= width * math.Round(dx/width)
+			shift.y -= width * math.Round(dy/width)
+		}
+		previous = raw
+		t.positions[i].x = raw.x + shift.x
+		t.positions[i].y = raw.y + shift.y
+	}
+}
+
+// Steps: Returns the displacement vectors between consecutive points of a trajectory.
+func (t Trajectory) Steps() []OrderedPair {
+	if len(t.positions) < 2 {
+		return nil
+	}
+	steps := make([]OrderedPair, len(t.positions)-1)
+	for i := range steps {
+		steps[i].x = t.positions[i+1].x - t.positions[i].x
+		steps[i].y = t.positions[i+1].y - t.positions[i].y
+	}
+	return steps
+}
+
+// Stats: Computes the motility statistics of a single trajectory.
+// Output: (MotilityStats) the statistics. Quantities that can't be computed (e.g. with fewer than 3 points) are NaN.
+func (t Trajectory) Stats() MotilityStats {
+	stats := MotilityStats{Label: t.label, MeanSpeed: math.NaN(), Directionality: math.NaN(), Persistence: math.NaN(),
+		MeanTurningAngle: math.NaN(), Tortuosity: math.NaN()}
+	steps := t.Steps()
+	stats.Steps = len(steps)
+	if len(steps) == 0 {
+		return stats
+	}
+
+	pathLength := 0.0
+	for _, step := range steps {
+		pathLength += step.Magnitude()
+	}
+	duration := t.times[len(t.times)-1] - t.times[0]
+	if duration > 0 {
+		stats.MeanSpeed = pathLength / duration
+	}
+	net := ComputeDistance(t.positions[0], t.positions[len(t.positions)-1])
+	if pathLength > 0 {
+		stats.Directionality = net / pathLength
+	}
+	if net > 0 {
+		stats.Tortuosity = pathLength / net
+	}
+
+	autocorrelation := VelocityAutocorrelation([]Trajectory{t}, 1)
+	if len(autocorrelation) > 1 {
+		stats.Persistence = autocorrelation[1]
+	}
+
+	angles := t.TurningAngles()
+	if len(angles) > 0 {
+		sum := 0.0
+		for _, angle := range angles {
+			sum += math.Abs(angle)
+		}
+		stats.MeanTurningAngle = sum / float64(len(angles))
+	}
+	return stats
+}
+
+// TurningAngles: Returns the signed angle (radians, in (-pi, pi]) between each pair of consecutive non-zero steps.
+func (t Trajectory) TurningAngles() []float64 {
+	var angles []float64
+	var previous OrderedPair
+	for _, step := range t.Steps() {
+		if step.Magnitude() == 0 {
+			continue
+		}
+		if previous.Magnitude() > 0 {
+			angles = append(angles, math.Atan2(CrossProduct2D(previous, step), DotProduct2D(previous, step)))
+		}
+		previous = step
+	}
+	return angles
+}
+
+// VelocityAutocorrelation: Computes the normalized velocity autocorrelation <v(t).v(t+lag)> / <v(t).v(t)>
+// over all trajectories for lags of 0 to maxLag steps.
+// Output: ([]float64) the autocorrelation at each lag, NaN where no pairs of steps are that far apart.
+func VelocityAutocorrelation(trajectories []Trajectory, maxLag int) []float64 {
+	sums := make([]float64, maxLag+1)
+	counts := make([]float64, maxLag+1)
+	for _, trajectory := range trajectories {
+		steps := trajectory.Steps()
+		for lag := 0; lag <= maxLag; lag++ {
+			for i := 0; i+lag < len(steps); i++ {
+				sums[lag] += DotProduct2D(steps[i], steps[i+lag])
+				counts[lag]++
+			}
+		}
+	}
+	autocorrelation := make([]float64, maxLag+1)
+	for lag := range autocorrelation {
+		if counts[lag] == 0 || sums[0] == 0 {
+			autocorrelation[lag] = math.NaN()
+			continue
+		}
+		autocorrelation[lag] = (sums[lag] / counts[lag]) / (sums[0] / counts[0])
+	}
+	return autocorrelation
+}
+
+// MeanSquaredDisplacement: Computes the mean squared displacement over all trajectories for lags of 0 to maxLag steps.
+// Output: ([]float64) the MSD at each lag in uM^2, NaN where no pairs of points are that far apart.
+func MeanSquaredDisplacement(trajectories []Trajectory, maxLag int) []float64 {
+	sums := make([]float64, maxLag+1)
+	counts := make([]float64, maxLag+1)
+	for _, trajectory := range trajectories {
+		for lag := 0; lag <= maxLag; lag++ {
+			for i := 0; i+lag < len(trajectory.positions); i++ {
+				distance := ComputeDistance(trajectory.positions[i], trajectory.positions[i+lag])
+				sums[lag] += distance * distance
+				counts[lag]++
+			}
+		}
+	}
+	msd := make([]float64, maxLag+1)
+	for lag := range msd {
+		if counts[lag] == 0 {
+			msd[lag] = math.NaN()
+			continue
+		}
+		msd[lag] = sums[lag] / counts[lag]
+	}
+	return msd
+}
+
+// TurningAngleHistogram: Counts the turning angles of all trajectories in numBins equal bins over (-pi, pi].
+func TurningAngleHistogram(trajectories []Trajectory, numBins int) []int {
+	counts := make([]int, numBins)
+	for _, trajectory := range trajectories {
+		for _, angle := range trajectory.TurningAngles() {
+			bin := int((angle + math.Pi) / (2 * math.Pi) * float64(numBins))
+			if bin >= numBins {
+				bin = numBins - 1
+			}
+			counts[bin]++
+		}
+	}
+	return counts
+}
+
+// SpeedSamples: Returns the instantaneous speed of every step of every trajectory.
+func SpeedSamples(trajectories []Trajectory) []float64 {
+	var speeds []float64
+	for _, trajectory := range trajectories {
+		for i, step := range trajectory.Steps() {
+			dt := trajectory.times[i+1] - trajectory.times[i]
+			if dt > 0 {
+				speeds = append(speeds, step.Magnitude()/dt)
+			}
+		}
+	}
+	return speeds
+}
+
+// PopulationStats: Averages the statistics of every trajectory, skipping NaNs.
+// Output: (MotilityStats) the population means with Label 0 and Steps the total number of steps.
+func PopulationStats(trajectories []Trajectory) MotilityStats {
+	var population MotilityStats
+	fields := make([][]float64, 5)
+	for _, trajectory := range trajectories {
+		stats := trajectory.Stats()
+		population.Steps += stats.Steps
+		for i, value := range []float64{stats.MeanSpeed, stats.Directionality, stats.Persistence, stats.MeanTurningAngle, stats.Tortuosity} {
+			if !math.IsNaN(value) && !math.IsInf(value, 0) {
+				fields[i] = append(fields[i], value)
+			}
+		}
+	}
+	means := make([]float64, len(fields))
+	for i, values := range fields {
+		means[i] = Mean(values)
+	}
+	population.MeanSpeed, population.Directionality, population.Persistence, population.MeanTurningAngle, population.Tortuosity =
+		means[0], means[1], means[2], means[3], means[4]
+	return population
+}
+
+// Mean: Returns the mean of a slice, or NaN if it is empty.
+func Mean(values []float64) float64 {
+	if len(values) == 0 {
+		return math.NaN()
+	}
+	sum := 0.0
+	for _, value := range values {
+		sum += value
+	}
+	return sum / float64(len(values))
+}
+
+// Row: Formats the statistics as a csv row: label, steps, mean speed, directionality, persistence,
+// mean turning angle (degrees), tortuosity. The population row is labelled "all".
+func (stats MotilityStats) Row() []string {
+	label := "all"
+	if stats.Label != 0 {
+		label = strconv.Itoa(stats.Label)
+	}
+	return []string{label, strconv.Itoa(stats.Steps), FormatValue(stats.MeanSpeed), FormatValue(stats.Directionality),
+		FormatValue(stats.Persistence), FormatValue(stats.MeanTurningAngle * 180 / math.Pi), FormatValue(stats.Tortuosity)}
+}
+
+// MotilityHeader is the header row of the motility summary table.
+var MotilityHeader = []string{"label", "steps", "meanSpeed", "directionality", "persistence", "meanTurningAngle", "tortuosity"}
+
+// WriteMotilityToFile: Computes the motility statistics of a run and writes them to files named with the given prefix.
+// prefix + "Summary.csv": one row per cell plus a population row (see MotilityStats.Row)
+// prefix + "Correlation.csv": lag time, velocity autocorrelation, mean squared displacement
+// prefix + "TurningAngles.csv": bin center (degrees), count
+// prefix + "MSD.svg": MSD against lag time
+// Input: positionArray ([][]float64) rows of [time, label, x, y], width (float64) the board width,
+// timeStep (float64) the time between rows of the same cell.
+func WriteMotilityToFile(positionArray [][]float64, width, timeStep float64, prefix string) {
+	trajectories := ExtractTrajectories(positionArray, width)
+
+	summaryRows := [][]string{MotilityHeader}
+	for _, trajectory := range trajectories {
+		summaryRows = append(summaryRows, trajectory.Stats().Row())
+	}
+	summaryRows = append(summaryRows, PopulationStats(trajectories).Row())
+	WriteCSV(prefix+"Summary.csv", summaryRows)
+
+	maxLag := 0
+	for _, trajectory := range trajectories {
+		if len(trajectory.positions)-1 > maxLag {
+			maxLag = len(trajectory.positions) - 1
+		}
+	}
+	autocorrelation := VelocityAutocorrelation(trajectories, maxLag)
+	msd := MeanSquaredDisplacement(trajectories, maxLag)
+	lagTimes := make([]float64, maxLag+1)
+	correlationRows := [][]string{{"lagTime", "velocityAutocorrelation", "msd"}}
+	for lag := 0; lag <= maxLag; lag++ {
+		lagTimes[lag] = float64(lag) * timeStep
+		correlationRows = append(correlationRows, []string{FormatValue(lagTimes[lag]), FormatValue(autocorrelation[lag]), FormatValue(msd[lag])})
+	}
+	WriteCSV(prefix+"Correlation.csv", correlationRows)
+
+	numBins := 36
+	angleRows := [][]string{{"turningAngle", "count"}}
+	for bin, count := range TurningAngleHistogram(trajectories, numBins) {
+		center := -180 + (float64(bin)+0.5)*360/float64(numBins)
+		angleRows = append(angleRows, []string{FormatValue(center), strconv.Itoa(count)})
+	}
+	WriteCSV(prefix+"TurningAngles.csv", angleRows)
+
+	WriteLinePlotSVG(prefix+"MSD.svg", "Mean squared displacement", "Lag time (hours)", "MSD (uM^2)",
+		[]Series{{Name: "MSD", X: lagTimes, Y: msd}})
+}
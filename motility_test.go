@@ -0,0 +1,54 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestExtractTrajectories(t *testing.T) {
+	// one cell walking right across the edge of a board of width 100, and one standing still
+	positionArray := [][]float64{
+		nil,
+		{0, 1, 90, 50},
+		{0, 2, 10, 10},
+		{1, 1, 98, 50},
+		{1, 2, 10, 10},
+		{2, 1, 6, 50},
+		{2, 2, 10, 10},
+	}
+	trajectories := ExtractTrajectories(positionArray, 100)
+	if len(trajectories) != 2 {
+		t.Fatalf("Error! Your code finds %d trajectories, and there are 2", len(trajectories))
+	}
+	last := trajectories[0].positions[2]
+	if math.Abs(last.x-106) > 1e-9 || last.y != 50 {
+		t.Errorf("Error! The unwrapped position is (%f, %f), and the correct position is (106, 50)", last.x, last.y)
+	}
+
+	stats := trajectories[0].Stats()
+	if math.Abs(stats.MeanSpeed-8) > 1e-9 {
+		t.Errorf("Error! Your code gives a mean speed of %f, and the correct speed is 8", stats.MeanSpeed)
+	}
+	if math.Abs(stats.Directionality-1) > 1e-9 {
+		t.Errorf("Error! Your code gives a directionality of %f, and the correct value is 1", stats.Directionality)
+	}
+	if stats.MeanTurningAngle != 0 {
+		t.Errorf("Error! Your code gives a mean turning angle of %f, and the correct value is 0", stats.MeanTurningAngle)
+	}
+	if !math.IsNaN(trajectories[1].Stats().Directionality) {
+		t.Errorf("Error! A cell that never moves should have an undefined directionality")
+	}
+}
+
+func TestMeanSquaredDisplacement(t *testing.T) {
+	var trajectory Trajectory
+	trajectory.times = []float64{0, 1, 2}
+	trajectory.positions = []OrderedPair{{0, 0}, {3, 4}, {6, 8}}
+	answer := []float64{0, 25, 100}
+	outcome := MeanSquaredDisplacement([]Trajectory{trajectory}, 2)
+	for i := range answer {
+		if math.Abs(outcome[i]-answer[i]) > 1e-9 {
+			t.Errorf("Error! For lag %d your code gives %f, and the correct MSD is %f", i, outcome[i], answer[i])
+		}
+	}
+}
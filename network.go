@@ -0,0 +1,177 @@
+package main
+
+import (
+	"math"
+)
+
+// Crosslink joins two fibres at the point where they intersect. The attachment points are stored as
+// signed distances from each fibre's center along the fibre, so they move with the fibres.
+type Crosslink struct {
+	fibre1, fibre2   int     // indices into ECM.fibres
+	offset1, offset2 float64 // uM from the center of each fibre
+}
+
+// CheckNetworkOptions: Panics if the crosslink settings can't be simulated. Crosslinks are springs, so they need a
+// positive stiffness whenever there are any.
+func CheckNetworkOptions() {
+	if CrosslinkDensity < 0 || CrosslinkDensity > 1 {
+		panic("Error: the crosslink density must be between 0 and 1.")
+	}
+	if CrosslinkDensity > 0 && CrosslinkStiffness <= 0 {
+		panic("Error: the crosslink stiffness must be above 0 when fibres are crosslinked.")
+	}
+}
+
+// FindCrosslinks: Finds every pair of intersecting fibres and joins each pair with probability CrosslinkDensity.
+// Fibres are bucketed on a grid so that only fibres in neighbouring buckets are tested against each other.
+// Fibres don't wrap around the edges of the board (cells find them by plain distance too), so two fibres are only
+// joined where they cross on the board, not where they would meet across an edge.
+// Input: fibres ([]*Fibre) all fibres on the ECM.
+// Output: ([]Crosslink) the crosslinks of the network.
+func FindCrosslinks(fibres []*Fibre) []Crosslink {
+	var crosslinks []Crosslink
+	if CrosslinkDensity <= 0 || len(fibres) == 0 {
+		return crosslinks
+	}
+
+	// the bucket size is the longest fibre so intersecting fibres are always in neighbouring buckets
+	bucketSize := 0.0
+	for _, fibre := range fibres {
+		bucketSize = math.Max(bucketSize, fibre.length)
+	}
+	if bucketSize == 0 {
+		return crosslinks
+	}
+	buckets := make(map[[2]int][]int)
+	for i, fibre := range fibres {
+		key := [2]int{int(math.Floor(fibre.position.x / bucketSize)), int(math.Floor(fibre.position.y / bucketSize))}
+		buckets[key] = append(buckets[key], i)
+	}
+
+	for i, fibre := range fibres {
+		bx := int(math.Floor(fibre.position.x / bucketSize))
+		by := int(math.Floor(fibre.position.y / bucketSize))
+		for dx := -1; dx <= 1; dx++ {
+			for dy := -1; dy <= 1; dy++ {
+				for _, j := range buckets[[2]int{bx + dx, by + dy}] {
+					if j <= i { // test each pair once
+						continue
+					}
+					if link, ok := IntersectFibres(fibres, i, j); ok && rng.Float64() < CrosslinkDensity {
+						crosslinks = append(crosslinks, link)
+					}
+				}
+			}
+		}
+	}
+	return crosslinks
+}
+
+// IntersectFibres: Checks whether two fibres cross and, if so, returns the crosslink that joins them.
+// Input: fibres ([]*Fibre) all fibres on the ECM, i, j (int) the indices of the two fibres.
+// Output: (Crosslink, bool) the crosslink at the intersection and whether the fibres intersect.
+func IntersectFibres(fibres []*Fibre, i, j int) (Crosslink, bool) {
+	f1, f2 := fibres[i], fibres[j]
+	d1 := f1.MaterialDirection()
+	d2 := f2.MaterialDirection()
+	denominator := CrossProduct2D(d1, d2)
+	if denominator == 0 { // parallel fibres
+		return Crosslink{}, false
+	}
+	delta := OrderedPair{f2.position.x - f1.position.x, f2.position.y - f1.position.y}
+	// solve f1.position + s*d1 = f2.position + t*d2
+	s := CrossProduct2D(delta, d2) / denominator
+	t := CrossProduct2D(delta, d1) / denominator
+	if math.Abs(s) > 0.5*f1.length || math.Abs(t) > 0.5*f2.length {
+		return Crosslink{}, false
+	}
+	return Crosslink{fibre1: i, fibre2: j, offset1: s, offset2: t}, true
+}
+
+// CrossProduct2D: Returns the z-component of the cross product of two vectors in R2.
+// Input: v1, v2 (OrderedPair) the two vectors.
+// Output: (float64) v1.x*v2.y - v1.y*v2.x
+func CrossProduct2D(v1, v2 OrderedPair) float64 {
+	return v1.x*v2.y - v1.y*v2.x
+}
+
+// RelaxNetwork: Relaxes the crosslinked fibre network. Every crosslink is a spring of rest length 0 between
+// its two attachment points. Each fibre is a rigid rod that translates with the net spring force and rotates
+// about its center with the net torque. The forces are applied RelaxationIterations times per generation.
+func (e *ECM) RelaxNetwork() {
+	if len(e.crosslinks) == 0 || CrosslinkStiffness <= 0 { // springs without stiffness exert no force
+		return
+	}
+	numLinks := make([]float64, len(e.fibres))
+	for _, link := range e.crosslinks {
+		numLinks[link.fibre1]++
+		numLinks[link.fibre2]++
+	}
+
+	forces := make([]OrderedPair, len(e.fibres))
+	torques := make([]float64, len(e.fibres))
+	for iteration := 0; iteration < RelaxationIterations; iteration++ {
+		for i := range forces {
+			forces[i] = OrderedPair{}
+			torques[i] = 0
+		}
+		for _, link := range e.crosslinks {
+			f1, f2 := e.fibres[link.fibre1], e.fibres[link.fibre2]
+			p1 := f1.MaterialPoint(link.offset1)
+			p2 := f2.MaterialPoint(link.offset2)
+			// spring force pulling p1 towards p2
+			force := OrderedPair{CrosslinkStiffness * (p2.x - p1.x), CrosslinkStiffness * (p2.y - p1.y)}
+			forces[link.fibre1].x += force.x
+			forces[link.fibre1].y += force.y
+			forces[link.fibre2].x -= force.x
+			forces[link.fibre2].y -= force.y
+			arm1 := OrderedPair{p1.x - f1.position.x, p1.y - f1.position.y}
+			arm2 := OrderedPair{p2.x - f2.position.x, p2.y - f2.position.y}
+			torques[link.fibre1] += CrossProduct2D(arm1, force)
+			torques[link.fibre2] -= CrossProduct2D(arm2, force)
+		}
+		for i, fibre := range e.fibres {
+			if numLinks[i] == 0 {
+				continue
+			}
+			// Jacobi style update. Dividing by the number of links keeps heavily crosslinked fibres from overshooting.
+			mobility := 0.5 / (CrosslinkStiffness * numLinks[i])
+			fibre.position.x += mobility * forces[i].x
+			fibre.position.y += mobility * forces[i].y
+			rotationalMobility := mobility / math.Max(0.25*fibre.length*fibre.length/3, 1)
+			fibre.UpdateDirection(rotationalMobility * torques[i])
+			fibre.ResetPivot()
+		}
+	}
+}
+
+// PruneCrosslinks: Removes crosslinks that reference removed fibres and renumbers the rest.
+// Input: newIndex ([]int) for every old fibre index, its new index or -1 if the fibre was removed.
+func (e *ECM) PruneCrosslinks(newIndex []int) {
+	remaining := e.crosslinks[:0]
+	for _, link := range e.crosslinks {
+		i, j := newIndex[link.fibre1], newIndex[link.fibre2]
+		if i < 0 || j < 0 {
+			continue
+		}
+		link.fibre1, link.fibre2 = i, j
+		remaining = append(remaining, link)
+	}
+	e.crosslinks = remaining
+}
+
+// CrosslinkNewFibre: Joins a newly added fibre to any fibres it crosses, with probability CrosslinkDensity.
+// Input: index (int) the index of the new fibre in ECM.fibres.
+func (e *ECM) CrosslinkNewFibre(index int) {
+	if CrosslinkDensity <= 0 {
+		return
+	}
+	for j := range e.fibres {
+		if j == index {
+			continue
+		}
+		if link, ok := IntersectFibres(e.fibres, j, index); ok && rng.Float64() < CrosslinkDensity {
+			e.crosslinks = append(e.crosslinks, link)
+		}
+	}
+}
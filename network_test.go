@@ -0,0 +1,131 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+// NewTestFibre: Returns a fibre of some length centered at (x, y) at some angle (degrees), with its pivot on an end.
+func NewTestFibre(x, y, length, degrees float64) *Fibre {
+	fibre := &Fibre{length: length, position: OrderedPair{x, y}}
+	fibre.direction = OrderedPair{math.Cos(degrees * math.Pi / 180), math.Sin(degrees * math.Pi / 180)}
+	fibre.ResetPivot()
+	fibre.SetRest()
+	return fibre
+}
+
+func TestIntersectFibres(t *testing.T) {
+	type test struct {
+		fibres           []*Fibre
+		answer           bool
+		offset1, offset2 float64
+	}
+
+	tests := make([]test, 5)
+	// a cross, meeting 2 uM right of the first center and 3 uM below the second
+	tests[0] = test{[]*Fibre{NewTestFibre(50, 50, 20, 0), NewTestFibre(52, 53, 20, 90)}, true, 2, -3}
+	// too short to reach each other
+	tests[1] = test{[]*Fibre{NewTestFibre(50, 50, 20, 0), NewTestFibre(52, 65, 20, 90)}, false, 0, 0}
+	// parallel
+	tests[2] = test{[]*Fibre{NewTestFibre(50, 50, 20, 30), NewTestFibre(51, 50, 20, 30)}, false, 0, 0}
+	// the lines cross at a point past the end of the first fibre
+	tests[3] = test{[]*Fibre{NewTestFibre(50, 50, 20, 0), NewTestFibre(65, 50, 20, 90)}, false, 0, 0}
+	// one fibre sticks out past the right edge of a 100 uM board and the other sits at the left edge:
+	// they would only meet across the wrap, and fibres don't wrap
+	tests[4] = test{[]*Fibre{NewTestFibre(95, 50, 20, 0), NewTestFibre(2, 50, 20, 90)}, false, 0, 0}
+
+	for i, test := range tests {
+		link, ok := IntersectFibres(test.fibres, 0, 1)
+		if ok != test.answer || (ok && (math.Abs(link.offset1-test.offset1) > 1e-9 || math.Abs(link.offset2-test.offset2) > 1e-9)) {
+			t.Errorf("Error! For input test dataset %d, your code gives %v %+v, and the correct answer is %v with offsets %v and %v.", i, ok, link, test.answer, test.offset1, test.offset2)
+		}
+	}
+}
+
+func TestFindCrosslinks(t *testing.T) {
+	density := CrosslinkDensity
+	defer func() { CrosslinkDensity = density }()
+
+	// a hash sign: 0 and 1 cross 2 and 3, and fibre 4 is far away. Fibre 3 sticks out past the bottom edge.
+	fibres := []*Fibre{NewTestFibre(50, 45, 30, 0), NewTestFibre(50, 55, 30, 0), NewTestFibre(45, 50, 30, 90),
+		NewTestFibre(55, 5, 30, 90), NewTestFibre(300, 300, 30, 45)}
+	fibres[3].position.y = 50
+	fibres[3].ResetPivot()
+	fibres = append(fibres, NewTestFibre(20, -3, 30, 90)) // mostly off the bottom of the board, crossing nothing
+
+	CrosslinkDensity = 1
+	outcome := make(map[[2]int]bool)
+	for _, link := range FindCrosslinks(fibres) {
+		outcome[[2]int{link.fibre1, link.fibre2}] = true
+	}
+	answer := [][2]int{{0, 2}, {0, 3}, {1, 2}, {1, 3}}
+	if len(outcome) != len(answer) {
+		t.Errorf("Error! Your code gives the crosslinks %v, and the correct crosslinks are %v.", outcome, answer)
+	}
+	for _, pair := range answer {
+		if !outcome[pair] {
+			t.Errorf("Error! Your code gives the crosslinks %v, and the correct crosslinks include %v.", outcome, pair)
+		}
+	}
+
+	CrosslinkDensity = 0
+	if links := FindCrosslinks(fibres); len(links) != 0 {
+		t.Errorf("Error! With a density of 0, your code gives %d crosslinks, and the correct number is 0.", len(links))
+	}
+}
+
+// TestRelaxNetwork checks that the spring of a crosslink pulls its two attachment points together symmetrically.
+func TestRelaxNetwork(t *testing.T) {
+	stiffness := CrosslinkStiffness
+	defer func() { CrosslinkStiffness = stiffness }()
+
+	for i, springStiffness := range []float64{1, 0.1, 5} {
+		CrosslinkStiffness = springStiffness
+		// the fibres were joined at their centers, then the second one was pulled 4 uM to the right
+		var e ECM
+		e.fibres = []*Fibre{NewTestFibre(50, 50, 20, 0), NewTestFibre(54, 50, 20, 90)}
+		e.crosslinks = []Crosslink{{fibre1: 0, fibre2: 1}}
+		e.RelaxNetwork()
+
+		p1 := e.fibres[0].MaterialPoint(0)
+		p2 := e.fibres[1].MaterialPoint(0)
+		midpoint := OrderedPair{(p1.x + p2.x) / 2, (p1.y + p2.y) / 2}
+		if ComputeDistance(midpoint, OrderedPair{52, 50}) > 1e-9 {
+			t.Errorf("Error! For input test dataset %d, your code moves the crosslink midpoint to %v, and the correct midpoint is (52, 50).", i, midpoint)
+		}
+		if gap := ComputeDistance(p1, p2); gap > 0.01 {
+			t.Errorf("Error! For input test dataset %d, your code leaves a gap of %v uM, and the correct gap is close to 0.", i, gap)
+		}
+		for j, fibre := range e.fibres {
+			if rule := FibreInvariant(fibre, nil); rule != "" {
+				t.Errorf("Error! For input test dataset %d, your code breaks %q for fibre %d, and the correct fibre keeps it.", i, rule, j)
+			}
+		}
+	}
+
+	// with no stiffness the springs do nothing rather than moving the fibres infinitely far
+	CrosslinkStiffness = 0
+	var e ECM
+	e.fibres = []*Fibre{NewTestFibre(50, 50, 20, 0), NewTestFibre(54, 50, 20, 90)}
+	e.crosslinks = []Crosslink{{fibre1: 0, fibre2: 1}}
+	e.RelaxNetwork()
+	if e.fibres[0].position != (OrderedPair{50, 50}) || e.fibres[1].position != (OrderedPair{54, 50}) {
+		t.Errorf("Error! With a stiffness of 0, your code moves the fibres to %v and %v, and the correct fibres stay put.", e.fibres[0].position, e.fibres[1].position)
+	}
+}
+
+func TestPruneCrosslinks(t *testing.T) {
+	var e ECM
+	e.crosslinks = []Crosslink{{fibre1: 0, fibre2: 1}, {fibre1: 1, fibre2: 3}, {fibre1: 2, fibre2: 3}, {fibre1: 0, fibre2: 3}}
+	// fibre 1 was removed
+	e.PruneCrosslinks([]int{0, -1, 1, 2})
+	answer := []Crosslink{{fibre1: 1, fibre2: 2}, {fibre1: 0, fibre2: 2}}
+	if len(e.crosslinks) != len(answer) {
+		t.Fatalf("Error! Your code gives the crosslinks %v, and the correct crosslinks are %v.", e.crosslinks, answer)
+	}
+	for i := range answer {
+		if e.crosslinks[i] != answer[i] {
+			t.Errorf("Error! Your code gives the crosslinks %v, and the correct crosslinks are %v.", e.crosslinks, answer)
+		}
+	}
+}
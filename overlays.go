@@ -0,0 +1,250 @@
+package main
+
+import (
+	"canvas"
+	"fmt"
+	"image/color"
+	"math"
+	"strconv"
+)
+
+// FrameOverlay holds what a frame needs to know about the rest of the simulation to draw its overlays.
+type FrameOverlay struct {
+	time   float64               // hours
+	trails map[int][]OrderedPair // past positions of each cell label, oldest first, ending at the current position
+}
+
+// CheckDrawingOptions: Panics if CellColouring or FibreColouring isn't one of the known options.
+func CheckDrawingOptions() {
+	switch CellColouring {
+	case "uniform", "label", "type":
+	default:
+		panic("Error: unknown cell colouring " + CellColouring + ".")
+	}
+	switch FibreColouring {
+	case "uniform", "orientation", "rotation":
+	default:
+		panic("Error: unknown fibre colouring " + FibreColouring + ".")
+	}
+}
+
+// FindTrails: Collects the positions of every cell in frame index over the last trailLength frames.
+// Input: timePoints ([]*ECM) all the frames, index (int) the frame being drawn, trailLength (int) frames of history.
+// Output: (map[int][]OrderedPair) positions by cell label, oldest first. Empty if trailLength is 0.
+func FindTrails(timePoints []*ECM, index, trailLength int) map[int][]OrderedPair {
+	trails := make(map[int][]OrderedPair)
+	if trailLength <= 0 {
+		return trails
+	}
+	for i := int(math.Max(0, float64(index-trailLength))); i <= index; i++ {
+		for _, c := range timePoints[i].cells {
+			trails[c.label] = append(trails[c.label], c.position)
+		}
+	}
+	return trails
+}
+
+// CellColour: Returns the colour to draw a cell in according to CellColouring.
+// "uniform" is the original pink, "label" gives each cell its own colour and "type" colours by cell type.
+func CellColour(c *Cell) [3]uint8 {
+	switch CellColouring {
+	case "label":
+		// golden ratio steps spread consecutive labels around the colour wheel
+		hue := math.Mod(float64(c.label)*0.618033988749895, 1)
+		return HSVToRGB(hue, 0.6, 1)
+	case "type":
+		return HexColour(DefaultColours[c.cellType%len(DefaultColours)])
+	}
+	return [3]uint8{200, 150, 200}
+}
+
+// FibreColour: Returns the colour to draw a fibre in according to FibreColouring.
+// "orientation" maps the fibre angle in [0, 180) degrees around the colour wheel. "rotation" goes from
+// the original blue for fibres at rest to red for fibres rotated 90 degrees or more from rest.
+func FibreColour(f *Fibre) [3]uint8 {
+	blue := [3]uint8{100, 100, 200}
+	switch FibreColouring {
+	case "orientation":
+		angle := math.Atan2(f.direction.y, f.direction.x)
+		if angle < 0 {
+			angle += math.Pi
+		}
+		return HSVToRGB(math.Mod(angle/math.Pi, 1), 0.7, 0.9)
+	case "rotation":
+		fraction := math.Min(math.Abs(f.AngleFromRest())/(math.Pi/2), 1)
+		return BlendColours(blue, [3]uint8{230, 60, 60}, fraction)
+	}
+	return blue
+}
+
+// HSVToRGB: Converts a colour from hue, saturation and value (all in [0, 1]) to RGB.
+func HSVToRGB(h, s, v float64) [3]uint8 {
+	h = math.Mod(h, 1) * 6
+	sector := int(h)
+	f := h - float64(sector)
+	p, q, t := v*(1-s), v*(1-s*f), v*(1-s*(1-f))
+	var r, g, b float64
+	switch sector {
+	case 0:
+		r, g, b = v, t, p
+	case 1:
+		r, g, b = q, v, p
+	case 2:
+		r, g, b = p, v, t
+	case 3:
+		r, g, b = p, q, v
+	case 4:
+		r, g, b = t, p, v
+	default:
+		r, g, b = v, p, q
+	}
+	return [3]uint8{uint8(math.Round(255 * r)), uint8(math.Round(255 * g)), uint8(math.Round(255 * b))}
+}
+
+// HexColour: Converts an SVG colour such as "#1f77b4" to RGB. Returns white if it can't be read.
+func HexColour(hex string) [3]uint8 {
+	if len(hex) != 7 || hex[0] != '#' {
+		return [3]uint8{255, 255, 255}
+	}
+	value, err := strconv.ParseUint(hex[1:], 16, 32)
+	if err != nil {
+		return [3]uint8{255, 255, 255}
+	}
+	return [3]uint8{uint8(value >> 16), uint8(value >> 8), uint8(value)}
+}
+
+// BlendColours: Linearly interpolates between two colours. fraction 0 gives from, 1 gives to.
+func BlendColours(from, to [3]uint8, fraction float64) [3]uint8 {
+	var blended [3]uint8
+	for i := range blended {
+		blended[i] = uint8(math.Round(float64(from[i]) + fraction*(float64(to[i])-float64(from[i]))))
+	}
+	return blended
+}
+
+// MakeCanvasColour: Converts an RGB triple to a canvas colour.
+func MakeCanvasColour(rgb [3]uint8) color.Color {
+	return canvas.MakeColor(rgb[0], rgb[1], rgb[2])
+}
+
+// DrawTrails: Draws each cell's recent path, fading from the cell's colour to the black background
+// with age. Steps that wrap around the edge of the board are skipped.
+func (e *ECM) DrawTrails(c *canvas.Canvas, trails map[int][]OrderedPair, canvasWidth int) {
+	scale := float64(canvasWidth) / ECMwidth
+	c.SetLineWidth(float64(canvasWidth) / 500)
+	for _, cell := range e.cells {
+		trail := trails[cell.label]
+		colour := CellColour(cell)
+		for i := 1; i < len(trail); i++ {
+			if math.Abs(trail[i].x-trail[i-1].x) > ECMwidth/2 || math.Abs(trail[i].y-trail[i-1].y) > ECMwidth/2 {
+				continue
+			}
+			age := float64(len(trail)-i) / float64(len(trail))
+			c.SetStrokeColor(MakeCanvasColour(BlendColours(colour, [3]uint8{0, 0, 0}, age)))
+			c.MoveTo(trail[i-1].x*scale, trail[i-1].y*scale)
+			c.LineTo(trail[i].x*scale, trail[i].y*scale)
+			c.Stroke()
+		}
+	}
+}
+
+// DrawProjectionArrows: Draws an arrow from each cell's centre along its projection vector,
+// twice as long as the cell radius.
+func (e *ECM) DrawProjectionArrows(c *canvas.Canvas, canvasWidth int) {
+	scale := float64(canvasWidth) / ECMwidth
+	c.SetLineWidth(float64(canvasWidth) / 400)
+	c.SetStrokeColor(canvas.MakeColor(255, 255, 255))
+	for _, cell := range e.cells {
+		direction := cell.projection
+		if direction.Magnitude() == 0 {
+			continue
+		}
+		direction = MultiplyVectorByConstant2D(direction, 2*cell.radius*scale/direction.Magnitude())
+		start := MultiplyVectorByConstant2D(cell.position, scale)
+		tip := OrderedPair{start.x + direction.x, start.y + direction.y}
+		c.MoveTo(start.x, start.y)
+		c.LineTo(tip.x, tip.y)
+		// two barbs at 150 degrees to the arrow
+		for _, angle := range []float64{5 * math.Pi / 6, -5 * math.Pi / 6} {
+			barb := MultiplyVectorByConstant2D(RotateVector(direction, angle), 0.3)
+			c.MoveTo(tip.x, tip.y)
+			c.LineTo(tip.x+barb.x, tip.y+barb.y)
+		}
+		c.Stroke()
+	}
+}
+
+// DrawTimeStamp: Writes the time in hours, e.g. "12.50 h", in the top left corner.
+func DrawTimeStamp(c *canvas.Canvas, time float64, canvasWidth int) {
+	height := float64(canvasWidth) / 30
+	c.SetStrokeColor(canvas.MakeColor(255, 255, 255))
+	DrawSevenSegment(c, fmt.Sprintf("%.2f h", time), height/2, height/2, height)
+}
+
+// DrawScaleBar: Draws a bar length micrometres long in the bottom left corner with its length written above it.
+func DrawScaleBar(c *canvas.Canvas, length float64, canvasWidth int) {
+	scale := float64(canvasWidth) / ECMwidth
+	margin := float64(canvasWidth) / 25
+	height := float64(canvasWidth) / 40
+	c.SetStrokeColor(canvas.MakeColor(255, 255, 255))
+	c.SetLineWidth(float64(canvasWidth) / 200)
+	c.MoveTo(margin, float64(canvasWidth)-margin)
+	c.LineTo(margin+length*scale, float64(canvasWidth)-margin)
+	c.Stroke()
+	DrawSevenSegment(c, strconv.FormatFloat(length, 'f', -1, 64), margin, float64(canvasWidth)-margin-1.5*height, height)
+}
+
+// sevenSegments lists which segments light up for each character, in the order
+// top, top right, bottom right, bottom, bottom left, top left, middle.
+var sevenSegments = map[rune][7]bool{
+	'0': {true, true, true, true, true, true, false},
+	'1': {false, true, true, false, false, false, false},
+	'2': {true, true, false, true, true, false, true},
+	'3': {true, true, true, true, false, false, true},
+	'4': {false, true, true, false, false, true, true},
+	'5': {true, false, true, true, false, true, true},
+	'6': {true, false, true, true, true, true, true},
+	'7': {true, true, true, false, false, false, false},
+	'8': {true, true, true, true, true, true, true},
+	'9': {true, true, true, true, false, true, true},
+	'-': {false, false, false, false, false, false, true},
+	'h': {false, false, true, false, true, true, true},
+}
+
+// DrawSevenSegment: Writes text as seven segment digits with the stroke colour, since the canvas can't draw text.
+// Supports digits, '-', '.', 'h' and spaces; anything else is drawn as a space.
+// Input: c the canvas, text (string), x, y (float64) the top left corner, height (float64) the character height.
+func DrawSevenSegment(c *canvas.Canvas, text string, x, y, height float64) {
+	w := height / 2
+	h := height / 2
+	c.SetLineWidth(height / 8)
+	for _, char := range text {
+		if char == '.' {
+			c.MoveTo(x, y+height)
+			c.LineTo(x+height/16, y+height)
+			c.Stroke()
+			x += w / 2
+			continue
+		}
+		segments, ok := sevenSegments[char]
+		if ok {
+			lines := [7][4]float64{
+				{x, y, x + w, y},
+				{x + w, y, x + w, y + h},
+				{x + w, y + h, x + w, y + 2*h},
+				{x, y + 2*h, x + w, y + 2*h},
+				{x, y + h, x, y + 2*h},
+				{x, y, x, y + h},
+				{x, y + h, x + w, y + h},
+			}
+			for i, on := range segments {
+				if on {
+					c.MoveTo(lines[i][0], lines[i][1])
+					c.LineTo(lines[i][2], lines[i][3])
+				}
+			}
+			c.Stroke()
+		}
+		x += 1.6 * w
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestHSVToRGB(t *testing.T) {
+	type test struct {
+		h, s, v float64
+		answer  [3]uint8
+	}
+
+	tests := make([]test, 4)
+	tests[0].h, tests[0].s, tests[0].v = 0, 1, 1
+	tests[0].answer = [3]uint8{255, 0, 0}
+
+	tests[1].h, tests[1].s, tests[1].v = 1.0/3, 1, 1
+	tests[1].answer = [3]uint8{0, 255, 0}
+
+	tests[2].h, tests[2].s, tests[2].v = 2.0/3, 1, 0.5
+	tests[2].answer = [3]uint8{0, 0, 128}
+
+	tests[3].h, tests[3].s, tests[3].v = 0.25, 0, 1
+	tests[3].answer = [3]uint8{255, 255, 255}
+
+	for i, test := range tests {
+		outcome := HSVToRGB(test.h, test.s, test.v)
+		if outcome != test.answer {
+			t.Errorf("Error! For input test dataset %d, your code gives %v, and the correct colour is %v", i, outcome, test.answer)
+		}
+	}
+}
+
+func TestHexColour(t *testing.T) {
+	if outcome := HexColour("#1f77b4"); outcome != [3]uint8{31, 119, 180} {
+		t.Errorf("Error! Your code gives %v for #1f77b4, and the correct colour is [31 119 180]", outcome)
+	}
+	for _, hex := range []string{"", "1f77b4", "#1f77bz"} {
+		if outcome := HexColour(hex); outcome != [3]uint8{255, 255, 255} {
+			t.Errorf("Error! %q should give white, and your code gives %v", hex, outcome)
+		}
+	}
+}
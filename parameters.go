@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ModelParameter is one tunable constant of the model. The value lives in a global in datatypes.go,
+// whose initial value is the default.
+type ModelParameter struct {
+	Name        string // used in parameter files, form fields and command line flags
+	Description string
+	Unit        string
+	Min, Max    float64 // allowed range, inclusive. Parameters the model divides by have a positive Min.
+	Default     float64 // filled in from the global at start up
+	Value       *float64
+}
+
+// ModelParameters are the constants of the model that can be tuned, in the order the form shows them.
+var ModelParameters = []ModelParameter{
+	{Name: "fibreLengthMean", Description: "Mean fibre length", Unit: "uM", Min: 1, Max: 1000, Value: &FibreLengthMean},
+	{Name: "fibreLengthSD", Description: "Standard deviation of fibre length", Unit: "uM", Min: 0, Max: 100, Value: &FibreLengthSD},
+	{Name: "fibreWidth", Description: "Fibre width", Unit: "uM", Min: 0.01, Max: 10, Value: &FibreWidth},
+	{Name: "cellRadius", Description: "Cell radius", Unit: "uM", Min: 1, Max: 200, Value: &CellRadius},
+	{Name: "cellHeight", Description: "Cell height", Unit: "uM", Min: 0.1, Max: 50, Value: &CellHeight},
+	{Name: "integrin", Description: "Integrins expressed by the cells", Unit: "%", Min: 0, Max: 100, Value: &CellIntegrin},
+	{Name: "viscosity", Description: "Cell viscosity", Unit: "Poise", Min: 0.001, Max: 1e6, Value: &CellViscosity},
+	{Name: "shapeFactorCoefficient", Description: "Shape factor coefficient k in c = k * sqrt(0.5 * radius * height)", Unit: "", Min: 0.1, Max: 1000, Value: &ShapeFactorCoefficient},
+	{Name: "fibreTraction", Description: "Pull of the fibres near a cell along their directions, as a fraction of its motile force. A cell away from fibres moves at cellSpeed, one heading along aligned fibres at up to (1 + fibreTraction) * cellSpeed", Unit: "", Min: 0, Max: 10, Value: &FibreTraction},
+	{Name: "interactionRadius", Description: "Interaction radius of cells and fibres", Unit: "uM", Min: 0, Max: 1000, Value: &InteractionRadius},
+	{Name: "alignFactorCoefficient", Description: "Alignment coefficient a in 1 - a * integrin * (1 - stiffness)", Unit: "per %", Min: 0, Max: 1, Value: &AlignFactorCoefficient},
+	{Name: "placementMargin", Description: "Margin kept free of cells at the start, as a fraction of the width", Unit: "", Min: 0, Max: 0.5, Value: &PlacementMargin},
+}
+
+func init() {
+	for i := range ModelParameters {
+		ModelParameters[i].Default = *ModelParameters[i].Value
+	}
+}
+
+// FindModelParameter: Returns the parameter with the given name, or nil if there isn't one.
+func FindModelParameter(name string) *ModelParameter {
+	for i := range ModelParameters {
+		if ModelParameters[i].Name == name {
+			return &ModelParameters[i]
+		}
+	}
+	return nil
+}
+
+// SetModelParameter: Sets a parameter after checking its name and range.
+func SetModelParameter(name string, value float64) error {
+	p := FindModelParameter(name)
+	if p == nil {
+		return fmt.Errorf("unknown model parameter %q", name)
+	}
+	if err := p.Check(value); err != nil {
+		return err
+	}
+	*p.Value = value
+	return nil
+}
+
+// Check: Returns an error if value is outside the parameter's range.
+func (p ModelParameter) Check(value float64) error {
+	if math.IsNaN(value) || value < p.Min || value > p.Max {
+		return fmt.Errorf("%s must be between %g and %g, not %g", p.Name, p.Min, p.Max, value)
+	}
+	return nil
+}
+
+// CheckModelParameters: Returns an error naming the first parameter outside its range, e.g. after flags set them.
+func CheckModelParameters() error {
+	for _, p := range ModelParameters {
+		if err := SetModelParameter(p.Name, *p.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ResetModelParameters: Sets every parameter back to its default.
+func ResetModelParameters() {
+	for _, p := range ModelParameters {
+		*p.Value = p.Default
+	}
+}
+
+// ParseModelParameters: Reads parameter values from JSON ({"cellRadius": 12, ...}) or from YAML written as
+// one "name: value" pair per line, with # starting a comment. Only the parameters given are returned.
+func ParseModelParameters(data []byte) (map[string]float64, error) {
+	values := make(map[string]float64)
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		if err := json.Unmarshal(trimmed, &values); err != nil {
+			return nil, err
+		}
+	} else {
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		for line := 1; scanner.Scan(); line++ {
+			text := strings.TrimSpace(strings.SplitN(scanner.Text(), "#", 2)[0])
+			if text == "" || text == "---" {
+				continue
+			}
+			name, value, found := strings.Cut(text, ":")
+			if !found {
+				return nil, fmt.Errorf("line %d: expected \"name: value\"", line)
+			}
+			number, err := strconv.ParseFloat(strings.Trim(strings.TrimSpace(value), `"'`), 64)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", line, err)
+			}
+			values[strings.TrimSpace(name)] = number
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+	for name := range values {
+		if FindModelParameter(name) == nil {
+			return nil, fmt.Errorf("unknown model parameter %q", name)
+		}
+	}
+	return values, nil
+}
+
+// LoadModelParameters: Reads a JSON or YAML parameter file (see ParseModelParameters) and sets the parameters
+// in it, except those named in skip, e.g. ones already given on the command line.
+func LoadModelParameters(filename string, skip map[string]bool) error {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	values, err := ParseModelParameters(data)
+	if err != nil {
+		return fmt.Errorf("%s: %v", filename, err)
+	}
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if skip[name] {
+			continue
+		}
+		if err := SetModelParameter(name, values[name]); err != nil {
+			return fmt.Errorf("%s: %v", filename, err)
+		}
+	}
+	return nil
+}
+
+// AddModelParameterFlags: Adds a flag for every model parameter, plus -params to read them from a file.
+// Output: (*string) the -params file name, to be passed to ApplyParameterFile after parsing.
+func AddModelParameterFlags(flags *flag.FlagSet) *string {
+	for _, p := range ModelParameters {
+		usage := p.Description
+		if p.Unit != "" {
+			usage += " (" + p.Unit + ")"
+		}
+		flags.Float64Var(p.Value, p.Name, *p.Value, usage)
+	}
+	return flags.String("params", "", "JSON or YAML file of model parameters (flags given as well take precedence)")
+}
+
+// ApplyParameterFile: Loads the -params file, if one was given, without overriding parameters set by flags,
+// then checks every parameter is in range.
+func ApplyParameterFile(flags *flag.FlagSet, filename string) error {
+	if filename != "" {
+		given := make(map[string]bool)
+		flags.Visit(func(f *flag.Flag) {
+			given[f.Name] = true
+		})
+		if err := LoadModelParameters(filename, given); err != nil {
+			return err
+		}
+	}
+	return CheckModelParameters()
+}
+
+// WriteModelParameters: Writes the value of every model parameter as JSON, which can be read back with -params.
+func WriteModelParameters(filename string) {
+	var builder strings.Builder
+	builder.WriteString("{\n")
+	for i, p := range ModelParameters {
+		fmt.Fprintf(&builder, "  %q: %s", p.Name, strconv.FormatFloat(*p.Value, 'g', -1, 64))
+		if i < len(ModelParameters)-1 {
+			builder.WriteString(",")
+		}
+		builder.WriteString("\n")
+	}
+	builder.WriteString("}\n")
+	if err := os.WriteFile(filename, []byte(builder.String()), 0644); err != nil {
+		panic("Error writing " + filepath.Base(filename) + ".")
+	}
+}
+
+// ShapeFactor: Returns c in Eqn S3, c = ShapeFactorCoefficient * sqrt(0.5 * r * h).
+func ShapeFactor(radius, height float64) float64 {
+	return ShapeFactorCoefficient * math.Sqrt(0.5*radius*height)
+}
+
+// ReferenceDragCoefficient: Returns the drag coefficient of a cell with the default radius and height in a medium
+// with the default viscosity, using the default shape factor coefficient. CellSpeed is the speed of such a cell.
+func ReferenceDragCoefficient() float64 {
+	value := func(name string) float64 { return FindModelParameter(name).Default }
+	return value("shapeFactorCoefficient") * math.Sqrt(0.5*value("cellRadius")*value("cellHeight")) * value("viscosity")
+}
+
+// RandomFibreLength: Draws a fibre length from the normal distribution with mean FibreLengthMean and
+// standard deviation FibreLengthSD. A wide distribution can give a negative length, which is taken as 0.
+func RandomFibreLength() float64 {
+	return math.Max(0, rng.NormFloat64()*FibreLengthSD+FibreLengthMean)
+}
+
+// parametersHandler: Reads a parameter file posted by the form's "Load" button and returns the values in it as
+// JSON, so the page can fill in the advanced fields.
+func parametersHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Use POST.", http.StatusMethodNotAllowed)
+		return
+	}
+	data, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	values, err := ParseModelParameters(data)
+	for name, value := range values {
+		if err == nil {
+			err = FindModelParameter(name).Check(value)
+		}
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(values)
+}
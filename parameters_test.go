@@ -0,0 +1,87 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseModelParameters(t *testing.T) {
+	type test struct {
+		data   string
+		answer map[string]float64 // nil if the data should be rejected
+	}
+
+	tests := make([]test, 5)
+	tests[0] = test{`{"cellRadius": 12, "integrin": 40}`, map[string]float64{"cellRadius": 12, "integrin": 40}}
+	tests[1] = test{"# cells\ncellRadius: 12\n---\nplacementMargin: \"0.2\" # a fifth\n", map[string]float64{"cellRadius": 12, "placementMargin": 0.2}}
+	tests[2] = test{"cellRadius 12\n", nil}
+	tests[3] = test{"cellRadius: twelve\n", nil}
+	tests[4] = test{`{"cellRadius": 12, "cellColour": 1}`, nil}
+
+	for i, test := range tests {
+		values, err := ParseModelParameters([]byte(test.data))
+		if test.answer == nil {
+			if err == nil {
+				t.Errorf("Error! For input test dataset %d, your code accepts %v, and it should be rejected", i, values)
+			}
+			continue
+		}
+		if err != nil || len(values) != len(test.answer) {
+			t.Errorf("Error! For input test dataset %d, your code gives %v (%v), and the correct values are %v", i, values, err, test.answer)
+			continue
+		}
+		for name, value := range test.answer {
+			if values[name] != value {
+				t.Errorf("Error! For input test dataset %d, your code gives %s = %v, and the correct value is %v", i, name, values[name], value)
+			}
+		}
+	}
+}
+
+func TestApplyParameterFile(t *testing.T) {
+	type test struct {
+		file   string
+		args   []string
+		answer map[string]float64 // nil if the parameters should be rejected
+	}
+
+	tests := make([]test, 5)
+	tests[0] = test{"cellRadius: 12\nintegrin: 40\n", nil, map[string]float64{"cellRadius": 12, "integrin": 40}}
+	// flags given as well take precedence over the file
+	tests[1] = test{"cellRadius: 12\nintegrin: 40\n", []string{"-integrin", "60"}, map[string]float64{"cellRadius": 12, "integrin": 60}}
+	tests[2] = test{"cellRadius: 12\n", []string{"-cellRadius", "20", "-viscosity", "5"}, map[string]float64{"cellRadius": 20, "viscosity": 5}}
+	tests[3] = test{"integrin: 140\n", nil, nil}
+	tests[4] = test{"cellRadius: 12\n", []string{"-shapeFactorCoefficient", "0"}, nil}
+
+	defer ResetModelParameters()
+	for i, test := range tests {
+		ResetModelParameters()
+		filename := filepath.Join(t.TempDir(), "params.yaml")
+		if err := os.WriteFile(filename, []byte(test.file), 0644); err != nil {
+			t.Fatal(err)
+		}
+		flags := flag.NewFlagSet("test", flag.ContinueOnError)
+		paramsFile := AddModelParameterFlags(flags)
+		if err := flags.Parse(append(test.args, "-params", filename)); err != nil {
+			t.Fatal(err)
+		}
+		err := ApplyParameterFile(flags, *paramsFile)
+		if test.answer == nil {
+			if err == nil {
+				t.Errorf("Error! For input test dataset %d, your code accepts the parameters, and they should be rejected", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Error! For input test dataset %d, your code gives %v, and the parameters should be accepted", i, err)
+			continue
+		}
+		for name, value := range test.answer {
+			if outcome := *FindModelParameter(name).Value; outcome != value {
+				t.Errorf("Error! For input test dataset %d, your code gives %s = %v, and the correct value is %v", i, name, outcome, value)
+			}
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strings"
+)
+
+// Series is one line on a line plot.
+type Series struct {
+	Name   string
+	X, Y   []float64
+	Colour string // any SVG colour, e.g. "#1f77b4"
+}
+
+// DefaultColours are used for series that don't set a colour.
+var DefaultColours = []string{"#1f77b4", "#ff7f0e", "#2ca02c", "#d62728", "#9467bd", "#8c564b", "#e377c2", "#7f7f7f"}
+
+// WriteLinePlotSVG: Draws one or more series as a line plot and saves it as an SVG file.
+// Input: filename (string) the output file, title, xLabel, yLabel (string) the plot labels,
+// series ([]Series) the lines to draw.
+func WriteLinePlotSVG(filename, title, xLabel, yLabel string, series []Series) {
+	err := os.WriteFile(filename, []byte(LinePlotSVG(title, xLabel, yLabel, series)), 0644)
+	if err != nil {
+		panic("Error writing plot " + filename + ".")
+	}
+}
+
+// LinePlotSVG: Draws one or more series as a line plot and returns the SVG.
+// SVG is plain text, so this doesn't need any plotting library.
+// Input: title, xLabel, yLabel (string) the plot labels, series ([]Series) the lines to draw.
+func LinePlotSVG(title, xLabel, yLabel string, series []Series) string {
+	const width, height = 800.0, 500.0
+	const left, right, top, bottom = 80.0, 160.0, 50.0, 60.0
+
+	xMin, xMax := math.Inf(1), math.Inf(-1)
+	yMin, yMax := math.Inf(1), math.Inf(-1)
+	for _, s := range series {
+		for i := range s.X {
+			if math.IsNaN(s.Y[i]) || math.IsInf(s.Y[i], 0) {
+				continue
+			}
+			xMin, xMax = math.Min(xMin, s.X[i]), math.Max(xMax, s.X[i])
+			yMin, yMax = math.Min(yMin, s.Y[i]), math.Max(yMax, s.Y[i])
+		}
+	}
+	if math.IsInf(xMin, 1) { // nothing to plot
+		xMin, xMax, yMin, yMax = 0, 1, 0, 1
+	}
+	if xMax == xMin {
+		xMax = xMin + 1
+	}
+	if yMax == yMin {
+		yMax = yMin + 1
+	}
+	plotWidth := width - left - right
+	plotHeight := height - top - bottom
+	toX := func(x float64) float64 { return left + (x-xMin)/(xMax-xMin)*plotWidth }
+	toY := func(y float64) float64 { return top + plotHeight - (y-yMin)/(yMax-yMin)*plotHeight }
+
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "<svg xmlns='http://www.w3.org/2000/svg' width='%g' height='%g' font-family='sans-serif' font-size='12'>\n", width, height)
+	fmt.Fprintf(&builder, "<rect width='100%%' height='100%%' fill='white'/>\n")
+	fmt.Fprintf(&builder, "<text x='%g' y='25' text-anchor='middle' font-size='16'>%s</text>\n", left+plotWidth/2, EscapeXML(title))
+
+	// axes with 5 ticks each
+	fmt.Fprintf(&builder, "<path d='M%g %g V%g H%g' stroke='black' fill='none'/>\n", left, top, top+plotHeight, left+plotWidth)
+	for i := 0; i <= 5; i++ {
+		xValue := xMin + float64(i)/5*(xMax-xMin)
+		yValue := yMin + float64(i)/5*(yMax-yMin)
+		fmt.Fprintf(&builder, "<text x='%g' y='%g' text-anchor='middle'>%.3g</text>\n", toX(xValue), top+plotHeight+18, xValue)
+		fmt.Fprintf(&builder, "<text x='%g' y='%g' text-anchor='end'>%.3g</text>\n", left-6, toY(yValue)+4, yValue)
+	}
+	fmt.Fprintf(&builder, "<text x='%g' y='%g' text-anchor='middle'>%s</text>\n", left+plotWidth/2, height-15, EscapeXML(xLabel))
+	fmt.Fprintf(&builder, "<text x='20' y='%g' text-anchor='middle' transform='rotate(-90 20 %g)'>%s</text>\n", top+plotHeight/2, top+plotHeight/2, EscapeXML(yLabel))
+
+	for index, s := range series {
+		colour := s.Colour
+		if colour == "" {
+			colour = DefaultColours[index%len(DefaultColours)]
+		}
+		var points []string
+		for i := range s.X {
+			if math.IsNaN(s.Y[i]) || math.IsInf(s.Y[i], 0) {
+				continue
+			}
+			points = append(points, fmt.Sprintf("%.2f,%.2f", toX(s.X[i]), toY(s.Y[i])))
+		}
+		fmt.Fprintf(&builder, "<polyline points='%s' stroke='%s' fill='none' stroke-width='1.5'/>\n", strings.Join(points, " "), colour)
+		// legend
+		legendY := top + 10 + float64(index)*18
+		fmt.Fprintf(&builder, "<line x1='%g' y1='%g' x2='%g' y2='%g' stroke='%s' stroke-width='2'/>\n", left+plotWidth+10, legendY, left+plotWidth+30, legendY, colour)
+		fmt.Fprintf(&builder, "<text x='%g' y='%g'>%s</text>\n", left+plotWidth+35, legendY+4, EscapeXML(s.Name))
+	}
+	builder.WriteString("</svg>\n")
+	return builder.String()
+}
+
+// EscapeXML: Escapes the characters that have special meaning in SVG text.
+func EscapeXML(text string) string {
+	replacer := strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "'", "&apos;", "\"", "&quot;")
+	return replacer.Replace(text)
+}
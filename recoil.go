@@ -0,0 +1,76 @@
+package main
+
+import (
+	"math"
+	"strconv"
+)
+
+// RecoilTimeConstant: Returns the time constant (in hours) with which fibres relax back to their rest
+// orientation. Stiffer matrices recoil faster: tau = RecoilTime / S, so RecoilTime is the time constant of a
+// matrix of stiffness 1, and tau stays positive and gets longer as the stiffness goes down.
+// A stiffness of 0 (or below) never recoils, and RecoilTime = 0 turns recoil off.
+// Input: stiffness (float64) the matrix stiffness.
+// Output: (float64) the time constant, or +Inf if fibres don't recoil.
+func RecoilTimeConstant(stiffness float64) float64 {
+	if RecoilTime <= 0 || stiffness <= 0 {
+		return math.Inf(1)
+	}
+	return RecoilTime / stiffness
+}
+
+// Recoil: Relaxes a fibre towards the orientation and position it had when it was created.
+// The angle and offset from rest both decay by a factor exp(-time/tau) per time step.
+// Input: time (float64) the time step in hours, stiffness (float64) the matrix stiffness.
+func (f *Fibre) Recoil(time, stiffness float64) {
+	tau := RecoilTimeConstant(stiffness)
+	if math.IsInf(tau, 1) {
+		return
+	}
+	fraction := 1 - math.Exp(-time/tau)
+	angle := f.AngleFromRest()
+	f.UpdateDirection(-fraction * angle)
+	f.position.x += fraction * (f.restPosition.x - f.position.x)
+	f.position.y += fraction * (f.restPosition.y - f.position.y)
+	f.ResetPivot()
+}
+
+// SetRest: Records the current orientation and position of a fibre as its rest state.
+func (f *Fibre) SetRest() {
+	f.restDirection = f.MaterialDirection()
+	f.restPosition = f.position
+}
+
+// AngleFromRest: Returns the signed angle (in radians, counter-clockwise positive) the fibre has been
+// rotated away from its rest orientation.
+func (f *Fibre) AngleFromRest() float64 {
+	current := f.MaterialDirection()
+	return math.Atan2(CrossProduct2D(f.restDirection, current), DotProduct2D(f.restDirection, current))
+}
+
+// WriteRecoilToFile: Writes how far the fibres are from rest at every time point to a csv file with the
+// columns time, mean absolute angle from rest (degrees), fraction of fibres more than 5 degrees from rest.
+// Input: timeFrames ([]*ECM) the ECMs from the simulation, timeStep (float64) the time between frames.
+func WriteRecoilToFile(timeFrames []*ECM, timeStep float64, filename string) {
+	rows := make([][]string, 0, len(timeFrames))
+	for gen, frame := range timeFrames {
+		var meanAngle, fractionDisplaced float64
+		for _, fibre := range frame.fibres {
+			angle := math.Abs(fibre.AngleFromRest()) * 180 / math.Pi
+			meanAngle += angle
+			if angle > 5 {
+				fractionDisplaced++
+			}
+		}
+		if len(frame.fibres) > 0 {
+			meanAngle /= float64(len(frame.fibres))
+			fractionDisplaced /= float64(len(frame.fibres))
+		}
+		rows = append(rows, []string{
+			strconv.FormatFloat(float64(gen)*timeStep, 'f', 1, 64),
+			strconv.FormatFloat(meanAngle, 'f', -1, 64),
+			strconv.FormatFloat(fractionDisplaced, 'f', -1, 64),
+		})
+	}
+
+	WriteCSV(filename, rows)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestRecoilTimeConstant(t *testing.T) {
+	type test struct {
+		recoilTime, stiffness, answer float64
+	}
+
+	tests := make([]test, 6)
+	tests[0] = test{2, 1, 2}
+	tests[1] = test{2, 0.5, 4}
+	tests[2] = test{2, 0.95, 2 / 0.95}
+	tests[3] = test{2, 0, math.Inf(1)}
+	tests[4] = test{0, 0.5, math.Inf(1)}
+	// stiffnesses above 1 recoil faster still, but never instantly or backwards
+	tests[5] = test{2, 4, 0.5}
+
+	recoilTime := RecoilTime
+	defer func() { RecoilTime = recoilTime }()
+	for i, test := range tests {
+		RecoilTime = test.recoilTime
+		outcome := RecoilTimeConstant(test.stiffness)
+		if math.Abs(outcome-test.answer) > 1e-12 && outcome != test.answer {
+			t.Errorf("Error! For input test dataset %d, your code gives %v, and the correct time constant is %v.", i, outcome, test.answer)
+		}
+	}
+}
+
+func TestAngleFromRest(t *testing.T) {
+	type test struct {
+		degrees float64 // rotation applied after setting the rest state
+		flipped bool    // whether FindPivot has reversed the direction since
+		answer  float64
+	}
+
+	tests := make([]test, 5)
+	tests[0] = test{0, false, 0}
+	tests[1] = test{30, false, 30}
+	tests[2] = test{-45, false, -45}
+	tests[3] = test{30, true, 30}
+	tests[4] = test{170, false, 170}
+
+	for i, test := range tests {
+		fibre := Fibre{length: 10, position: OrderedPair{50, 50}, direction: OrderedPair{1, 0}}
+		fibre.SetRest()
+		fibre.UpdateDirection(test.degrees * math.Pi / 180)
+		if test.flipped {
+			fibre.direction = MultiplyVectorByConstant2D(fibre.direction, -1)
+			fibre.flipped = true
+		}
+		outcome := fibre.AngleFromRest() * 180 / math.Pi
+		if math.Abs(outcome-test.answer) > 1e-9 {
+			t.Errorf("Error! For input test dataset %d, your code gives %v degrees, and the correct angle is %v.", i, outcome, test.answer)
+		}
+	}
+}
+
+// TestRecoil checks that one step of recoil shrinks the angle and offset from rest by exp(-dt/tau) and keeps
+// the pivot on an end of the fibre.
+func TestRecoil(t *testing.T) {
+	recoilTime := RecoilTime
+	defer func() { RecoilTime = recoilTime }()
+	RecoilTime = 2
+
+	for i, stiffness := range []float64{0.25, 0.5, 0.95, 1, 3} {
+		fibre := Fibre{length: 10, position: OrderedPair{50, 50}, direction: OrderedPair{1, 0}}
+		fibre.ResetPivot()
+		fibre.SetRest()
+		fibre.UpdateDirection(math.Pi / 3)
+		fibre.position = OrderedPair{53, 54}
+		fibre.ResetPivot()
+
+		fibre.Recoil(0.75, stiffness)
+		factor := math.Exp(-0.75 / RecoilTimeConstant(stiffness))
+		if outcome, answer := fibre.AngleFromRest(), math.Pi/3*factor; math.Abs(outcome-answer) > 1e-9 {
+			t.Errorf("Error! For input test dataset %d, your code gives an angle of %v, and the correct angle is %v.", i, outcome, answer)
+		}
+		if outcome, answer := ComputeDistance(fibre.position, fibre.restPosition), 5*factor; math.Abs(outcome-answer) > 1e-9 {
+			t.Errorf("Error! For input test dataset %d, your code gives an offset of %v, and the correct offset is %v.", i, outcome, answer)
+		}
+		if rule := FibreInvariant(&fibre, nil); rule != "" {
+			t.Errorf("Error! For input test dataset %d, your code breaks %q, and the correct fibre keeps it.", i, rule)
+		}
+	}
+}
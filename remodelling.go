@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// CellType holds the per-type matrix remodelling rates.
+// fraction: share of the initial cells that are of this type.
+// degradationRate: micrometres of fibre length removed per hour from each fibre within DegradationRadius.
+// depositionRate: number of new fibres laid down per hour.
+type CellType struct {
+	name                                      string
+	fraction, degradationRate, depositionRate float64
+}
+
+// ParseCellTypes: Parses a list of cell types written as "name:fraction:degradationRate:depositionRate"
+// separated by commas, e.g. "leader:0.2:5:1,follower:0.8:0:0".
+// Input: description (string) the cell type list.
+// Output: ([]CellType) the parsed cell types, or an error if the description is malformed.
+func ParseCellTypes(description string) ([]CellType, error) {
+	var cellTypes []CellType
+	for _, entry := range strings.Split(description, ",") {
+		fields := strings.Split(strings.TrimSpace(entry), ":")
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("invalid cell type %q: expected name:fraction:degradationRate:depositionRate", entry)
+		}
+		var cellType CellType
+		cellType.name = fields[0]
+		values := make([]float64, 3)
+		for i := range values {
+			value, err := strconv.ParseFloat(fields[i+1], 64)
+			if err != nil || value < 0 || math.IsNaN(value) || math.IsInf(value, 0) {
+				return nil, fmt.Errorf("invalid cell type %q: rates and fractions must be non-negative numbers", entry)
+			}
+			values[i] = value
+		}
+		cellType.fraction, cellType.degradationRate, cellType.depositionRate = values[0], values[1], values[2]
+		cellTypes = append(cellTypes, cellType)
+	}
+	total := 0.0
+	for _, cellType := range cellTypes {
+		total += cellType.fraction
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("invalid cell types %q: the fractions can't all be 0", description)
+	}
+	return cellTypes, nil
+}
+
+// AssignCellType: Picks a cell type index at random, weighted by the fraction of each type.
+// Output: (int) index into CellTypes.
+func AssignCellType() int {
+	total := 0.0
+	for _, cellType := range CellTypes {
+		total += cellType.fraction
+	}
+	r := rng.Float64() * total
+	for i, cellType := range CellTypes {
+		r -= cellType.fraction
+		if r < 0 {
+			return i
+		}
+	}
+	return len(CellTypes) - 1
+}
+
+// RemodelMatrix: Lets every cell degrade the fibres around it and deposit new fibres along its projection.
+// Fibres shorter than MinimumFibreLength after degradation are removed from the matrix.
+// Input: time (float64) The time step in hours.
+func (e *ECM) RemodelMatrix(time float64) {
+	degraded := false
+	for _, cell := range e.cells {
+		cellType := CellTypes[cell.cellType]
+		if cellType.degradationRate > 0 {
+			cell.DegradeFibres(e.fibres, cellType.degradationRate*time)
+			degraded = true
+		}
+		if cellType.depositionRate > 0 {
+			numNew := SamplePoisson(cellType.depositionRate * time)
+			for i := 0; i < numNew; i++ {
+				e.fibres = append(e.fibres, cell.DepositFibre())
+				e.CrosslinkNewFibre(len(e.fibres) - 1)
+			}
+		}
+	}
+
+	// drop the fibres that have been fully degraded. Without degradation the fibres are left alone, even the
+	// ones that started out shorter than MinimumFibreLength.
+	if !degraded {
+		return
+	}
+	newIndex := make([]int, len(e.fibres))
+	remaining := make([]*Fibre, 0, len(e.fibres))
+	for i, fibre := range e.fibres {
+		if fibre.length >= MinimumFibreLength {
+			newIndex[i] = len(remaining)
+			remaining = append(remaining, fibre)
+		} else {
+			newIndex[i] = -1
+		}
+	}
+	if len(remaining) < len(e.fibres) {
+		e.fibres = remaining
+		e.PruneCrosslinks(newIndex)
+	}
+}
+
+// DegradeFibres: Shortens every fibre within DegradationRadius of the cell by some amount.
+// The fibre shrinks symmetrically about its center.
+// Input: fibres ([]*Fibre) the fibres on the ECM, amount (float64) the length removed from each nearby fibre.
+func (c *Cell) DegradeFibres(fibres []*Fibre, amount float64) {
+	for _, fibre := range fibres {
+		if fibre.DistanceToPoint(c.position) <= DegradationRadius {
+			fibre.length = math.Max(fibre.length-amount, 0)
+			fibre.ResetPivot()
+		}
+	}
+}
+
+// DepositFibre: Creates a new fibre at the cell's position that is aligned with the cell's projection.
+// Output: (*Fibre) pointer to the new fibre.
+func (c *Cell) DepositFibre() *Fibre {
+	var newFibre Fibre
+	newFibre.length = RandomFibreLength() // same length distribution as InitializeFibres
+	newFibre.width = FibreWidth
+	newFibre.position = c.position
+	newFibre.direction = c.projection
+	if newFibre.direction.Magnitude() == 0 {
+		angle := rng.Float64() * 2 * math.Pi
+		newFibre.direction.x = math.Cos(angle)
+		newFibre.direction.y = math.Sin(angle)
+	}
+	newFibre.direction.Normalize()
+	newFibre.ResetPivot()
+	newFibre.SetRest()
+	return &newFibre
+}
+
+// SamplePoisson: Draws a random number from a Poisson distribution with mean lambda.
+// Uses Knuth's algorithm for small means. It takes about lambda steps and exp(-lambda) underflows for large
+// ones, so above PoissonNormalLimit the normal approximation N(lambda, lambda), rounded, is used instead.
+func SamplePoisson(lambda float64) int {
+	if lambda <= 0 {
+		return 0
+	}
+	if lambda > PoissonNormalLimit {
+		return int(math.Max(0, math.Round(lambda+math.Sqrt(lambda)*rng.NormFloat64())))
+	}
+	limit := math.Exp(-lambda)
+	k := 0
+	p := rng.Float64()
+	for p > limit {
+		k++
+		p *= rng.Float64()
+	}
+	return k
+}
+
+// PoissonNormalLimit is the mean above which SamplePoisson uses the normal approximation.
+const PoissonNormalLimit = 30.0
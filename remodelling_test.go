@@ -0,0 +1,120 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseCellTypes(t *testing.T) {
+	type test struct {
+		description string
+		answer      []CellType // nil if the description should be rejected
+	}
+
+	tests := make([]test, 10)
+	tests[0] = test{"default:1:0:0", []CellType{{name: "default", fraction: 1}}}
+	tests[1] = test{"leader:0.2:5:1, follower:0.8:0:0", []CellType{{"leader", 0.2, 5, 1}, {"follower", 0.8, 0, 0}}}
+	tests[2] = test{"leader:0.2:5", nil}
+	tests[3] = test{"leader:0.2:5:1:3", nil}
+	tests[4] = test{"leader:a:5:1", nil}
+	tests[5] = test{"leader:0.2:-5:1", nil}
+	tests[6] = test{"leader:NaN:5:1", nil}
+	tests[7] = test{"leader:0.2:5:+Inf", nil}
+	tests[8] = test{"a:0:1:0,b:0:0:1", nil}
+	tests[9] = test{"", nil}
+
+	for i, test := range tests {
+		outcome, err := ParseCellTypes(test.description)
+		if test.answer == nil {
+			if err == nil {
+				t.Errorf("Error! For input test dataset %d, your code accepts %q, and the correct answer is an error.", i, test.description)
+			}
+			continue
+		}
+		if err != nil || len(outcome) != len(test.answer) {
+			t.Errorf("Error! For input test dataset %d, your code gives %v (%v), and the correct cell types are %v.", i, outcome, err, test.answer)
+			continue
+		}
+		for j := range outcome {
+			if outcome[j] != test.answer[j] {
+				t.Errorf("Error! For input test dataset %d, your code gives %v, and the correct cell types are %v.", i, outcome, test.answer)
+			}
+		}
+	}
+}
+
+func TestDegradeFibres(t *testing.T) {
+	type test struct {
+		center OrderedPair // of a horizontal fibre 20 uM long
+		answer float64     // its length after degradation
+	}
+
+	// the cell is at (50, 50) and the degradation radius is 10
+	tests := make([]test, 5)
+	tests[0] = test{OrderedPair{50, 50}, 17}
+	// the closest point of the fibre is its end, 9 uM from the cell
+	tests[1] = test{OrderedPair{69, 50}, 17}
+	tests[2] = test{OrderedPair{71, 50}, 20}
+	tests[3] = test{OrderedPair{50, 61}, 20}
+	tests[4] = test{OrderedPair{50, 40}, 17}
+
+	radius := DegradationRadius
+	defer func() { DegradationRadius = radius }()
+	DegradationRadius = 10
+	cell := Cell{position: OrderedPair{50, 50}}
+	fibres := make([]*Fibre, len(tests))
+	for i, test := range tests {
+		fibres[i] = &Fibre{length: 20, position: test.center, direction: OrderedPair{1, 0}}
+		fibres[i].ResetPivot()
+	}
+	cell.DegradeFibres(fibres, 3)
+	for i, test := range tests {
+		if fibres[i].length != test.answer || fibres[i].position != test.center {
+			t.Errorf("Error! For input test dataset %d, your code gives a fibre of length %v at %v, and the correct fibre is %v long at %v.", i, fibres[i].length, fibres[i].position, test.answer, test.center)
+		}
+	}
+}
+
+// TestRemodelMatrix checks that fibres degraded below MinimumFibreLength are removed along with their
+// crosslinks, and that fibres out of reach are kept.
+func TestRemodelMatrix(t *testing.T) {
+	radius, minimum, cellTypes := DegradationRadius, MinimumFibreLength, CellTypes
+	defer func() { DegradationRadius, MinimumFibreLength, CellTypes = radius, minimum, cellTypes }()
+	DegradationRadius, MinimumFibreLength = 10, 5
+	CellTypes = []CellType{{name: "degrader", fraction: 1, degradationRate: 4}}
+
+	var e ECM
+	e.cells = []*Cell{{position: OrderedPair{50, 50}}}
+	e.fibres = []*Fibre{NewTestFibre(50, 50, 6, 0), NewTestFibre(80, 80, 6, 0), NewTestFibre(50, 52, 20, 90)}
+	e.crosslinks = []Crosslink{{fibre1: 0, fibre2: 2}, {fibre1: 1, fibre2: 2}}
+	// 4 per hour for half an hour takes 2 uM off every fibre near the cell
+	e.RemodelMatrix(0.5)
+
+	if len(e.fibres) != 2 || e.fibres[0].length != 6 || e.fibres[1].length != 18 {
+		t.Errorf("Error! Your code leaves %d fibres, and the correct fibres are 6 and 18 uM long.", len(e.fibres))
+	}
+	if len(e.crosslinks) != 1 || e.crosslinks[0] != (Crosslink{fibre1: 0, fibre2: 1}) {
+		t.Errorf("Error! Your code leaves the crosslinks %v, and the correct crosslinks are [{0 1}].", e.crosslinks)
+	}
+}
+
+func TestSamplePoisson(t *testing.T) {
+	SeedRandom(1)
+	const numSamples = 20000
+	for _, lambda := range []float64{0, 0.3, 4, 29, 31, 1000, 1e6} {
+		sum, sumSquares := 0.0, 0.0
+		for i := 0; i < numSamples; i++ {
+			k := float64(SamplePoisson(lambda))
+			if k < 0 {
+				t.Fatalf("Error! For a mean of %v, your code gives %v, and the correct sample isn't negative.", lambda, k)
+			}
+			sum, sumSquares = sum+k, sumSquares+k*k
+		}
+		mean := sum / numSamples
+		variance := sumSquares/numSamples - mean*mean
+		// the mean and variance of a Poisson distribution are both lambda; allow 5 standard errors
+		if math.Abs(mean-lambda) > 5*math.Sqrt(lambda/numSamples) || math.Abs(variance-lambda) > 0.05*lambda+0.01 {
+			t.Errorf("Error! For a mean of %v, your code gives samples with mean %v and variance %v, and the correct mean and variance are %v.", lambda, mean, variance, lambda)
+		}
+	}
+}
@@ -1,12 +1,21 @@
 package main
 
 import (
+	"context"
+	"embed"
+	"encoding/json"
+	"flag"
 	"fmt"
 	htemplate "html/template"
+	"io"
+	"net"
 	"net/http"
-	"path"
+	"os"
+	"os/signal"
+	"path/filepath"
 	"strconv"
-	"text/template"
+	"syscall"
+	"time"
 )
 
 // For handling directories
@@ -17,33 +26,181 @@ const PlotRoot = "/" + Plots + "/"
 type Page struct {
 	Title    string
 	Contents htemplate.HTML
+	Live     bool         // show the live viewer for the current run
+	Prefill  htemplate.JS // JSON of form values to fill in, e.g. when duplicating a past run
 }
 
-// RunWebApp: For creating the web app server.
-func RunWebApp() {
+// RunWebApp: For creating the web app server. It runs until it gets SIGINT or SIGTERM, then waits for the
+// running simulation to finish (or stops it, keeping its results so far) before exiting.
+// Input: args ([]string) the flags of the "serve" command, e.g. -addr localhost:8080 -out results.
+func RunWebApp(args []string) {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	flags.StringVar(&ServerAddress, "addr", ServerAddress, "address to listen on, e.g. :5000 or localhost:8080")
+	out := flags.String("out", ".", "directory to keep the runs in")
+	flags.StringVar(&TemplateDir, "templates", TemplateDir, "directory to read the page templates from (default: the built in ones)")
+	flags.DurationVar(&ShutdownGrace, "shutdownGrace", ShutdownGrace, "how long a running simulation may go on after SIGINT or SIGTERM before it is stopped")
+	flags.Parse(args)
+
+	if TemplateDir != "" {
+		dir, err := filepath.Abs(TemplateDir)
+		if err != nil {
+			panic("Error finding template directory " + TemplateDir + ".")
+		}
+		TemplateDir = dir
+		if _, err := PageTemplates(); err != nil {
+			panic("Error reading templates: " + err.Error())
+		}
+	}
+	if err := os.MkdirAll(*out, 0755); err != nil {
+		panic("Error creating output directory " + *out + ".")
+	}
+	if err := os.Chdir(*out); err != nil {
+		panic("Error changing to output directory " + *out + ".")
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", mainHandler)
+	mux.HandleFunc("/Inputs/", inputHandler)
+	mux.HandleFunc("/live/events", liveEventsHandler)
+	mux.HandleFunc("/live/", liveControlHandler)
+	mux.HandleFunc("/history", historyHandler)
+	mux.HandleFunc("/history/", historyHandler)
+	mux.HandleFunc("/compare", compareHandler)
+	mux.HandleFunc("/parameters", parametersHandler)
+
+	mux.Handle(PlotRoot, http.StripPrefix(PlotRoot, http.FileServer(http.Dir("./"+Plots))))
+	mux.Handle("/runs/", http.StripPrefix("/runs/", http.FileServer(http.Dir(RunsDir))))
+
+	listener, err := net.Listen("tcp", ServerAddress)
+	if err != nil {
+		panic("Error starting the web app: " + err.Error())
+	}
 	fmt.Println("Running Web App.")
-	fmt.Println("http://localhost:5000")
+	fmt.Println(ServerURL(ServerAddress, listener.Addr()))
+
+	server := &http.Server{Handler: mux}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	served := make(chan error, 1)
+	go func() {
+		served <- server.Serve(listener)
+	}()
+	select {
+	case err := <-served:
+		panic("Error running the web app: " + err.Error())
+	case <-ctx.Done():
+	}
+	stop() // a second Ctrl-C exits straight away
+
+	fmt.Println("Shutting down.")
+	StopLiveRuns(ShutdownGrace)
+	// the live viewers have been sent the end of the run, so the open connections finish quickly
+	shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	server.Shutdown(shutdown)
+}
 
-	http.HandleFunc("/", mainHandler)
-	http.HandleFunc("/Inputs/", inputHandler)
+// ServerURL: Returns the URL to open the web app at, e.g. "http://localhost:5000".
+// Input: address (string) the address given to listen on, listening (net.Addr) the address it is listening on,
+// which has the port chosen when address asked for port 0.
+func ServerURL(address string, listening net.Addr) string {
+	host, _, _ := net.SplitHostPort(address)
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "localhost"
+	}
+	_, port, _ := net.SplitHostPort(listening.String())
+	return "http://" + net.JoinHostPort(host, port)
+}
+
+// builtInTemplates are the page templates compiled into the program, so it runs from any directory.
+//
+//go:embed *.html
+var builtInTemplates embed.FS
+
+// templateFunctions can be called from the page templates, e.g. {{range modelParameters}}.
+var templateFunctions = htemplate.FuncMap{
+	"modelParameters": func() []ModelParameter { return ModelParameters },
+}
+
+// parsedTemplates are builtInTemplates parsed once at start up.
+var parsedTemplates = htemplate.Must(htemplate.New("").Funcs(templateFunctions).ParseFS(builtInTemplates, "*.html"))
+
+// PageTemplates: Returns the page templates. When TemplateDir is set they are read from it on every request,
+// so they can be edited without restarting the server; otherwise the built in ones are used.
+func PageTemplates() (*htemplate.Template, error) {
+	if TemplateDir != "" {
+		return htemplate.New("").Funcs(templateFunctions).ParseFS(os.DirFS(TemplateDir), "*.html")
+	}
+	return parsedTemplates, nil
+}
+
+// ExecuteHTMLTemplate: Fills in one of the page templates (e.g. "inputs.html") with data. html/template
+// escapes the parameters people typed into the form.
+func ExecuteHTMLTemplate(w io.Writer, name string, data interface{}) error {
+	t, err := PageTemplates()
+	if err != nil {
+		return err
+	}
+	return t.ExecuteTemplate(w, name, data)
+}
 
-	http.Handle(PlotRoot, http.StripPrefix(PlotRoot, http.FileServer(http.Dir("./"+Plots))))
-	http.ListenAndServe(":5000", nil)
+// ServePage: Responds with one of the page templates filled in with data, or an error if that fails.
+func ServePage(w http.ResponseWriter, name string, data interface{}) {
+	if err := ExecuteHTMLTemplate(w, name, data); err != nil {
+		http.Error(w, "Error showing "+name+": "+err.Error(), http.StatusInternalServerError)
+	}
 }
 
 // MainHandler: Handler that loads the html right when server is built.
+// With ?from=<run ID> the form is filled in with the parameters of that past run.
 func mainHandler(w http.ResponseWriter, r *http.Request) {
-	t, err := template.ParseFiles("inputs.html")
-	// t, err := template.ParseFiles("inputs.html")
-	if err != nil {
-		fmt.Println("ERROR")
-		panic("Error in mainHandler.")
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	var page Page
+	if id := r.URL.Query().Get("from"); id != "" {
+		record, err := LoadRunRecord(id)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		// json.Marshal escapes <, > and &, so the values are safe inside the page's script
+		prefill, err := json.Marshal(record.Parameters)
+		if err != nil {
+			panic("Error in mainHandler.")
+		}
+		page = Page{Title: "Duplicating run " + record.ID, Contents: "Edit the parameters and submit to start a new run.", Prefill: htemplate.JS(prefill)}
 	}
-	t.Execute(w, nil)
+	ServePage(w, "inputs.html", page)
 }
 
 // inputHandler: Handler for when someone hits the "submit" button.
 func inputHandler(w http.ResponseWriter, r *http.Request) {
+	// the parameters are global, so claim the run before setting any of them
+	run, err := StartLiveRun()
+	if err != nil {
+		page := Page{Title: "Can't start the simulation: " + err.Error()}
+		if err == ErrRunActive {
+			page.Contents = "Wait for it to finish or cancel it first."
+		}
+		ServePage(w, "inputs.html", page)
+		return
+	}
+	started := false
+	defer func() {
+		// bad inputs panic before the simulation starts; free the run so the next one can go
+		if r := recover(); r != nil {
+			SetGenerationObserver(nil)
+			run.Finish("", RunStatus{}, fmt.Sprint(r))
+			panic(r)
+		}
+		if !started {
+			SetGenerationObserver(nil)
+			run.Finish("", RunStatus{}, "the simulation didn't start")
+		}
+	}()
+
 	r.ParseForm()
 	// for key, val := range r.Form {
 	// 	fmt.Println("key", key, "val", val)
@@ -77,21 +234,114 @@ func inputHandler(w http.ResponseWriter, r *http.Request) {
 		panic("Failure in inputHandler.")
 	}
 
-	RunSimulation(numGens, numCells, numFibres, timeStep, width, cellSpeed, stiffness)
+	RecoilTime = parseOptionalFloat(r, "recoilTime", 0.0)
+	CellDoublingTime = parseOptionalFloat(r, "doublingTime", 0.0)
+	CellApoptosisRate = parseOptionalFloat(r, "apoptosisRate", 0.0)
+	DaughterPlacement = parseOptionalString(r, "daughterPlacement", "random")
+	LineageFormat = parseOptionalString(r, "lineageFormat", "newick")
+	CheckLineageOptions()
+	DegradationRadius = parseOptionalFloat(r, "degradationRadius", 20.0)
+	CrosslinkDensity = parseOptionalFloat(r, "crosslinkDensity", 0.0)
+	CrosslinkStiffness = parseOptionalFloat(r, "crosslinkStiffness", 1.0)
+	CheckNetworkOptions()
+	SoftBodyCells = parseOptionalString(r, "softBody", "off") == "on"
+	steering, ok := GetSteeringModel(parseOptionalString(r, "steeringModel", "fibre-snap"))
+	if !ok {
+		panic("Failure in inputHandler: unknown steering model.")
+	}
+	CellSteering = steering
+	PersistenceTime = parseOptionalFloat(r, "persistenceTime", 2.0)
+	TumbleRate = parseOptionalFloat(r, "tumbleRate", 0.5)
+	CheckSteeringOptions()
+	NoiseAmplitude = parseOptionalFloat(r, "noiseAmplitude", 0.0)
+	if parseOptionalString(r, "dimensions", "2") == "3" {
+		Dimensions = 3
+	} else {
+		Dimensions = 2
+	}
+	RenderMode3D = parseOptionalString(r, "renderMode3D", "projection")
+	SliceDepth = parseOptionalFloat(r, "sliceDepth", 0.5)
+	CellTypes, err = ParseCellTypes(parseOptionalString(r, "cellTypes", "default:1:0:0"))
+	if err != nil {
+		panic("Failure in inputHandler: " + err.Error())
+	}
+
+	for _, p := range ModelParameters {
+		if err := SetModelParameter(p.Name, parseOptionalFloat(r, p.Name, p.Default)); err != nil {
+			panic("Failure in inputHandler: " + err.Error())
+		}
+	}
+
+	CellColouring = parseOptionalString(r, "cellColour", "uniform")
+	FibreColouring = parseOptionalString(r, "fibreColour", "uniform")
+	TrailLength = int(parseOptionalFloat(r, "trail", 0))
+	DrawProjections = parseOptionalString(r, "arrows", "off") == "on"
+	DrawTimeStamps = parseOptionalString(r, "timeStamp", "off") == "on"
+	FrameScaleBar = parseOptionalFloat(r, "frameScaleBar", 0)
+	CheckDrawingOptions()
+	SnapshotGenerations = parseOptionalString(r, "snapshots", "")
+	CheckSnapshotGenerations(numGens)
+	SnapshotTrajectories = parseOptionalString(r, "snapshotTrajectories", "off") == "on"
+	SnapshotScaleBar = parseOptionalFloat(r, "scaleBar", 100.0)
+	OutputFormat = parseOptionalString(r, "outputFormat", "gif")
+	if !IsOutputFormat(OutputFormat) {
+		panic("Failure in inputHandler: unknown output format.")
+	}
+
+	CheckInvariants = parseOptionalString(r, "checkInvariants", "off") == "on"
+	CheckInvariantOptions()
+	Check3DOptions()
 
-	t, err := template.ParseFiles("inputs.html")
+	timeLimit := time.Duration(parseOptionalFloat(r, "timeLimit", 0) * float64(time.Minute))
+	// every run gets its own directory in the history
+	record, err := NewRunRecord(r.Form)
 	if err != nil {
-		panic("Error while adding gif to page.")
+		panic("Error creating the run directory: " + err.Error())
 	}
+	OutputDir = record.Dir()
+	ThumbnailSize = HistoryThumbnailSize
+	ctx := run.Start(numGens, timeLimit, record.ID)
+	started = true
+	// run in the background so the page can show the simulation as it goes
+	go func() {
+		animation, failure := "", ""
+		var status RunStatus
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					failure = fmt.Sprint(r)
+				}
+			}()
+			animation, status = RunSimulation(ctx, numGens, numCells, numFibres, timeStep, width, cellSpeed, stiffness)
+		}()
+		SetGenerationObserver(nil)
+		if err := record.Finish(animation, status, failure); err != nil {
+			fmt.Println("Error saving the run record: " + err.Error())
+		}
+		run.Finish(filepath.ToSlash(animation), status, failure)
+	}()
 
-	var page Page
-	plotFile := "CellMigration"
-	plotPath := path.Join(Plots, plotFile) + ".out.gif"
-	page = Page{
-		Title: "ECM Gif",
-		Contents: htemplate.HTML(fmt.Sprintf(
-			"<img src='/%s' class='rounded' alt='skew' style='width:600px;height:auto;'>", plotPath,
-		)),
-	}
-	t.Execute(w, page)
+	ServePage(w, "inputs.html", Page{Title: "ECM Animation", Live: true})
+}
+
+// parseOptionalFloat: Reads an optional float64 field from a submitted form.
+// Returns defaultValue if the field is missing or empty.
+func parseOptionalFloat(r *http.Request, key string, defaultValue float64) float64 {
+	if len(r.Form[key]) == 0 || r.Form[key][0] == "" {
+		return defaultValue
+	}
+	value, err := strconv.ParseFloat(r.Form[key][0], 0)
+	if err != nil {
+		panic("Failure in inputHandler.")
+	}
+	return value
+}
+
+// parseOptionalString: Reads an optional string field from a submitted form.
+// Returns defaultValue if the field is missing or empty.
+func parseOptionalString(r *http.Request, key string, defaultValue string) string {
+	if len(r.Form[key]) == 0 || r.Form[key][0] == "" {
+		return defaultValue
+	}
+	return r.Form[key][0]
 }
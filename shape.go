@@ -0,0 +1,98 @@
+package main
+
+import (
+	"math"
+	"strconv"
+)
+
+// Area: Calculates the area enclosed by a cell's perimeter vertices using the shoelace formula.
+// Falls back to the area of a circle of the cell's radius if the cell has no perimeter.
+// Output: (float64) the area of the cell in uM^2.
+func (c *Cell) Area() float64 {
+	numVertices := len(c.perimeterVertices)
+	if numVertices < 3 {
+		return math.Pi * c.radius * c.radius
+	}
+	area := 0.0
+	for i := range c.perimeterVertices {
+		p1 := c.perimeterVertices[i]
+		p2 := c.perimeterVertices[(i+1)%numVertices]
+		area += p1.x*p2.y - p2.x*p1.y
+	}
+	return math.Abs(area) / 2
+}
+
+// Perimeter: Calculates the length of a cell's perimeter.
+// Output: (float64) the perimeter of the cell in uM.
+func (c *Cell) Perimeter() float64 {
+	numVertices := len(c.perimeterVertices)
+	if numVertices < 3 {
+		return 2 * math.Pi * c.radius
+	}
+	perimeter := 0.0
+	for i := range c.perimeterVertices {
+		perimeter += ComputeDistance(c.perimeterVertices[i], c.perimeterVertices[(i+1)%numVertices])
+	}
+	return perimeter
+}
+
+// AspectRatio: Calculates the ratio of the long axis to the short axis of a cell. The axes are the
+// square roots of the eigenvalues of the covariance matrix of the perimeter vertices. A circle gives 1.
+// Output: (float64) the aspect ratio of the cell, +Inf if the perimeter has collapsed onto a line or a point.
+func (c *Cell) AspectRatio() float64 {
+	numVertices := float64(len(c.perimeterVertices))
+	if numVertices < 3 {
+		return 1.0
+	}
+	var centroid OrderedPair
+	for _, vertex := range c.perimeterVertices {
+		centroid.x += vertex.x / numVertices
+		centroid.y += vertex.y / numVertices
+	}
+	var sxx, syy, sxy float64
+	for _, vertex := range c.perimeterVertices {
+		dx := vertex.x - centroid.x
+		dy := vertex.y - centroid.y
+		sxx += dx * dx / numVertices
+		syy += dy * dy / numVertices
+		sxy += dx * dy / numVertices
+	}
+	// eigenvalues of [[sxx, sxy], [sxy, syy]]
+	mean := (sxx + syy) / 2
+	spread := math.Sqrt((sxx-syy)*(sxx-syy)/4 + sxy*sxy)
+	major := mean + spread
+	minor := mean - spread
+	if minor <= 0 {
+		return math.Inf(1)
+	}
+	return math.Sqrt(major / minor)
+}
+
+// WriteShapeToFile: Writes the shape of every cell at every time point to a csv file with the columns
+// time, cell label, area, perimeter, aspect ratio. The aspect ratio of a collapsed cell has no finite value
+// and is written as NA, which spreadsheets and R read as missing.
+// Input: timeFrames ([]*ECM) the ECMs from the simulation, timeStep (float64) the time between frames.
+func WriteShapeToFile(timeFrames []*ECM, timeStep float64, filename string) {
+	var rows [][]string
+	for gen, frame := range timeFrames {
+		for _, cell := range frame.cells {
+			rows = append(rows, []string{
+				strconv.FormatFloat(float64(gen)*timeStep, 'f', 1, 64),
+				strconv.Itoa(cell.label),
+				strconv.FormatFloat(cell.Area(), 'f', -1, 64),
+				strconv.FormatFloat(cell.Perimeter(), 'f', -1, 64),
+				FormatShapeValue(cell.AspectRatio()),
+			})
+		}
+	}
+
+	WriteCSV(filename, rows)
+}
+
+// FormatShapeValue: Formats a value for CellShape.csv, writing NA instead of an infinite or NaN value.
+func FormatShapeValue(value float64) string {
+	if math.IsInf(value, 0) || math.IsNaN(value) {
+		return "NA"
+	}
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+// RegularPolygon: Returns the vertices of a regular polygon with some number of sides and circumradius,
+// counter-clockwise and centered at (50, 50), starting at an angle in radians.
+func RegularPolygon(numSides int, radius, start float64) []OrderedPair {
+	vertices := make([]OrderedPair, numSides)
+	for i := range vertices {
+		angle := start + 2*math.Pi*float64(i)/float64(numSides)
+		vertices[i] = OrderedPair{50 + radius*math.Cos(angle), 50 + radius*math.Sin(angle)}
+	}
+	return vertices
+}
+
+func TestCellShape(t *testing.T) {
+	type test struct {
+		vertices                     []OrderedPair
+		area, perimeter, aspectRatio float64
+	}
+
+	tests := make([]test, 7)
+	// a square of side 2 and a hexagon of side 3
+	tests[0] = test{RegularPolygon(4, math.Sqrt2, math.Pi/4), 4, 8, 1}
+	tests[1] = test{RegularPolygon(6, 3, 0.3), 1.5 * math.Sqrt(3) * 9, 18, 1}
+	// a regular 16-gon like the perimeter of a soft-body cell, rotated so no vertex is on an axis
+	tests[2] = test{RegularPolygon(16, 15, 0.1), 8 * 225 * math.Sin(math.Pi/8), 32 * 15 * math.Sin(math.Pi/16), 1}
+	// clockwise vertices have the same area
+	tests[3] = test{[]OrderedPair{{0, 0}, {0, 2}, {2, 2}, {2, 0}}, 4, 8, 1}
+	// a 4 by 1 rectangle: the vertices spread 2 and 0.5 from the centroid
+	tests[4] = test{[]OrderedPair{{0, 0}, {4, 0}, {4, 1}, {0, 1}}, 4, 10, 4}
+	// without a perimeter the cell is a circle of its radius
+	tests[5] = test{nil, math.Pi * 100, 20 * math.Pi, 1}
+	// collapsed onto a line
+	tests[6] = test{[]OrderedPair{{0, 0}, {1, 1}, {2, 2}}, 0, 4 * math.Sqrt2, math.Inf(1)}
+
+	for i, test := range tests {
+		cell := Cell{radius: 10, perimeterVertices: test.vertices}
+		if outcome := cell.Area(); math.Abs(outcome-test.area) > 1e-9 {
+			t.Errorf("Error! For input test dataset %d, your code gives an area of %v, and the correct area is %v.", i, outcome, test.area)
+		}
+		if outcome := cell.Perimeter(); math.Abs(outcome-test.perimeter) > 1e-9 {
+			t.Errorf("Error! For input test dataset %d, your code gives a perimeter of %v, and the correct perimeter is %v.", i, outcome, test.perimeter)
+		}
+		if outcome := cell.AspectRatio(); math.Abs(outcome-test.aspectRatio) > 1e-9 && outcome != test.aspectRatio {
+			t.Errorf("Error! For input test dataset %d, your code gives an aspect ratio of %v, and the correct aspect ratio is %v.", i, outcome, test.aspectRatio)
+		}
+	}
+}
+
+func TestFormatShapeValue(t *testing.T) {
+	type test struct {
+		value  float64
+		answer string
+	}
+
+	tests := make([]test, 4)
+	tests[0] = test{1.5, "1.5"}
+	tests[1] = test{math.Inf(1), "NA"}
+	tests[2] = test{math.NaN(), "NA"}
+	tests[3] = test{12, "12"}
+
+	for i, test := range tests {
+		if outcome := FormatShapeValue(test.value); outcome != test.answer {
+			t.Errorf("Error! For input test dataset %d, your code gives %q, and the correct answer is %q.", i, outcome, test.answer)
+		}
+	}
+}
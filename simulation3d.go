@@ -0,0 +1,377 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"math"
+	"os"
+	"strconv"
+)
+
+// ECM3D is the 3D version of the ECM: fibres and cells in a cube of side ECMwidth that wraps around in all directions.
+type ECM3D struct {
+	fibres []*Fibre3D
+	cells  []*Cell3D
+}
+
+// Fibre3D is a rigid fibre in 3D. As in 2D, pivot + direction*length/2 = position after FindPivot.
+type Fibre3D struct {
+	length, width              float64
+	position, pivot, direction Vector3
+}
+
+// Cell3D is a spherical cell in 3D that steers with the same fibre-snapping rule as 2D cells.
+type Cell3D struct {
+	radius, integrin, shapeFactor, viscocity float64
+	position, projection                     Vector3
+	label                                    int
+}
+
+// Check3DOptions: Panics if a 3D run is asked for an option that only the 2D model has. 3D cells always snap to
+// fibres (SteerFibreSnap3D is the only 3D steering model) and are rigid spheres, and 3D fibres don't recoil, crosslink or get remodelled, nor do 3D cells divide or die.
+func Check3DOptions() {
+	if Dimensions != 3 {
+		return
+	}
+	if _, ok := CellSteering.(FibreSnapSteering); !ok {
+		panic("Error: 3D cells can only use the fibre-snap steering model.")
+	}
+	if SoftBodyCells {
+		panic("Error: 3D cells can't be deformable.")
+	}
+	if RecoilTime > 0 {
+		panic("Error: 3D fibres don't recoil, so the recoil time must be 0.")
+	}
+	if CrosslinkDensity > 0 {
+		panic("Error: 3D fibres can't be crosslinked, so the crosslink density must be 0.")
+	}
+	for _, cellType := range CellTypes {
+		if cellType.degradationRate > 0 || cellType.depositionRate > 0 {
+			panic("Error: 3D cells can't degrade or deposit fibres.")
+		}
+	}
+	if CellDoublingTime > 0 || CellApoptosisRate > 0 {
+		panic("Error: 3D cells can't divide or die, so the doubling time and apoptosis rate must be 0.")
+	}
+}
+
+// InitializeECM3D generates a new 3D ECM object.
+// Input: number of fibres, number of cells, width of the cube, speed of cells, stiffness of matrix
+// Output: pointer to ECM3D object made using given parameters
+func InitializeECM3D(numFibres, numCells int, width, speed, stiffness float64) *ECM3D {
+	ECMwidth = width
+	ECMstiffness = stiffness
+	CellSpeed = speed
+
+	var newECM ECM3D
+	newECM.fibres = make([]*Fibre3D, numFibres)
+	for i := range newECM.fibres {
+		var newFibre Fibre3D
+		newFibre.length = RandomFibreLength()
+		newFibre.width = FibreWidth
+		newFibre.position = Vector3{rng.Float64() * width, rng.Float64() * width, rng.Float64() * width}
+		newFibre.direction = RandomUnitVector3D() // fibres are oriented uniformly on the sphere
+		newFibre.pivot = SubtractVectors3D(newFibre.position, MultiplyVectorByConstant3D(newFibre.direction, 0.5*newFibre.length))
+		newECM.fibres[i] = &newFibre
+	}
+
+	newECM.cells = make([]*Cell3D, numCells)
+	n := PlacementMargin
+	for i := range newECM.cells {
+		var newCell Cell3D
+		newCell.label = i + 1
+		newCell.radius = CellRadius
+		newCell.integrin = CellIntegrin
+		newCell.shapeFactor = ShapeFactor(newCell.radius, CellHeight)
+		newCell.viscocity = CellViscosity
+		newCell.position = Vector3{
+			width*n + rng.Float64()*width*(1-2*n),
+			width*n + rng.Float64()*width*(1-2*n),
+			width*n + rng.Float64()*width*(1-2*n),
+		}
+		newCell.projection = RandomUnitVector3D()
+		newECM.cells[i] = &newCell
+	}
+	return &newECM
+}
+
+// SimulateCellMotility3D is the 3D version of SimulateCellMotility.
+// Output: A slice of numGens+1 ECM3D objects and the positions of every cell at every time point
+// as rows of [time, label, x, y, z].
+// Like SimulateCellMotility it stops early, returning the generations so far, once ctx ends.
+func SimulateCellMotility3D(ctx context.Context, initialECM *ECM3D, numGens int, time float64) ([]*ECM3D, [][]float64) {
+	timeFrames := make([]*ECM3D, numGens+1)
+	timeFrames[0] = initialECM
+	positionArray := make([][]float64, 0, (numGens+1)*len(initialECM.cells))
+	for _, cell := range initialECM.cells {
+		positionArray = append(positionArray, []float64{0, float64(cell.label), cell.position.x, cell.position.y, cell.position.z})
+	}
+
+	ObserveGeneration(func() LiveFrame { return initialECM.ToLiveFrame(0, 0) })
+
+	timePoint := 0.0
+	for gen := 1; gen <= numGens; gen++ {
+		if ctx.Err() != nil {
+			return timeFrames[:gen], positionArray
+		}
+		timeFrames[gen] = timeFrames[gen-1].UpdateECM3D(time)
+		timePoint += time
+		for _, cell := range timeFrames[gen].cells {
+			positionArray = append(positionArray, []float64{timePoint, float64(cell.label), cell.position.x, cell.position.y, cell.position.z})
+		}
+		ObserveGeneration(func() LiveFrame { return timeFrames[gen].ToLiveFrame(gen, timePoint) })
+	}
+	return timeFrames, positionArray
+}
+
+// UpdateECM3D returns a copy of the ECM advanced by one time step. Fibres are pulled by every cell
+// within InteractionRadius, then cells steer along nearby fibres and move.
+func (e *ECM3D) UpdateECM3D(time float64) *ECM3D {
+	newECM := e.CopyECM3D()
+	for _, fibre := range newECM.fibres {
+		var nearbyCells []*Cell3D
+		for _, cell := range newECM.cells {
+			if fibre.DistanceToPoint(cell.position) <= InteractionRadius {
+				nearbyCells = append(nearbyCells, cell)
+			}
+		}
+		fibre.UpdateFibreFromCells3D(nearbyCells, ECMstiffness)
+	}
+	for _, cell := range newECM.cells {
+		cell.UpdateCell3D(newECM.fibres, time)
+	}
+	return newECM
+}
+
+// CopyECM3D creates a deep copy of a 3D ECM.
+func (e *ECM3D) CopyECM3D() *ECM3D {
+	var newECM ECM3D
+	newECM.fibres = make([]*Fibre3D, len(e.fibres))
+	for i, fibre := range e.fibres {
+		newFibre := *fibre
+		newECM.fibres[i] = &newFibre
+	}
+	newECM.cells = make([]*Cell3D, len(e.cells))
+	for i, cell := range e.cells {
+		newCell := *cell
+		newECM.cells[i] = &newCell
+	}
+	return &newECM
+}
+
+// GetEndpoints: Returns the two ends of a 3D fibre.
+func (f *Fibre3D) GetEndpoints() (Vector3, Vector3) {
+	magnitude := f.direction.Magnitude()
+	halfLength := MultiplyVectorByConstant3D(f.direction, 0.5*f.length/magnitude)
+	return AddVectors3D(f.position, halfLength), SubtractVectors3D(f.position, halfLength)
+}
+
+// DistanceToPoint: Finds the shortest distance from a point to a 3D fibre, treating the fibre as a line segment.
+func (f *Fibre3D) DistanceToPoint(p Vector3) float64 {
+	endpoint1, endpoint2 := f.GetEndpoints()
+	segment := SubtractVectors3D(endpoint2, endpoint1)
+	lengthSquared := DotProduct3D(segment, segment)
+	if lengthSquared == 0 {
+		return ComputeDistance3D(p, endpoint1)
+	}
+	t := DotProduct3D(SubtractVectors3D(p, endpoint1), segment) / lengthSquared
+	t = math.Max(0, math.Min(1, t))
+	return ComputeDistance3D(p, AddVectors3D(endpoint1, MultiplyVectorByConstant3D(segment, t)))
+}
+
+// UpdateFibre3D rotates a fibre about the end furthest from the cell, in the plane containing the fibre and
+// the cell, by the same angle phi = theta - arcsin(alignFactor*D/d) used in 2D.
+// Input: cell (*Cell3D) the cell pulling on the fibre, stiffness (float64) matrix stiffness.
+func (f *Fibre3D) UpdateFibre3D(cell *Cell3D, stiffness float64) {
+	// FindPivot: the pivot is the end furthest from the cell and direction points from the pivot to the center
+	endpoint1, endpoint2 := f.GetEndpoints()
+	if ComputeDistance3D(cell.position, endpoint1) > ComputeDistance3D(cell.position, endpoint2) {
+		f.pivot = endpoint1
+		f.direction = MultiplyVectorByConstant3D(f.direction, -1.0)
+	} else {
+		f.pivot = endpoint2
+	}
+	f.direction.Normalize()
+
+	toCell := SubtractVectors3D(cell.position, f.pivot)
+	d := toCell.Magnitude() // distance from pivot point to cell
+	if d == 0 {
+		return
+	}
+	along := DotProduct3D(toCell, f.direction)
+	perpendicular := SubtractVectors3D(toCell, MultiplyVectorByConstant3D(f.direction, along))
+	D := perpendicular.Magnitude() // perpendicular distance from the cell to the fibre's line
+	theta := ClampedAsin(D / d)
+	alignFactor := (1 - AlignFactorCoefficient*cell.integrin*(1-stiffness))
+	phi := theta - ClampedAsin(alignFactor*D/d)
+
+	// rotating about direction x toCell turns the fibre towards the cell
+	axis := CrossProduct3D(f.direction, toCell)
+	if axis.Magnitude() == 0 { // the cell is already on the fibre's line
+		return
+	}
+	axis.Normalize()
+	f.direction = RotateAboutAxis3D(f.direction, axis, phi)
+	f.direction.Normalize()
+	f.position = AddVectors3D(f.pivot, MultiplyVectorByConstant3D(f.direction, 0.5*f.length))
+}
+
+// UpdateFibreFromCells3D: 3D version of UpdateFibreFromCells. Averages the fibre that each cell would produce.
+func (f *Fibre3D) UpdateFibreFromCells3D(cells []*Cell3D, stiffness float64) {
+	if len(cells) == 0 {
+		return
+	}
+	if len(cells) == 1 {
+		f.UpdateFibre3D(cells[0], stiffness)
+		return
+	}
+	reference := f.direction
+	var direction, position Vector3
+	for _, cell := range cells {
+		candidate := *f
+		candidate.UpdateFibre3D(cell, stiffness)
+		aligned := candidate.direction
+		if DotProduct3D(reference, aligned) < 0 {
+			aligned = MultiplyVectorByConstant3D(aligned, -1.0)
+		}
+		direction = AddVectors3D(direction, aligned)
+		position = AddVectors3D(position, MultiplyVectorByConstant3D(candidate.position, 1/float64(len(cells))))
+	}
+	if direction.Magnitude() == 0 {
+		return
+	}
+	direction.Normalize()
+	f.direction = direction
+	f.position = position
+	f.pivot = SubtractVectors3D(position, MultiplyVectorByConstant3D(direction, 0.5*f.length))
+}
+
+// UpdateCell3D steers a 3D cell with SteerFibreSnap3D and moves it with the Langevin integrator.
+// The cell is pulled along by the nearby fibres, see ComputeTractionForce3D.
+// Fibre snapping is the only steering model with a 3D form, so Check3DOptions refuses the others.
+// Input: fibres ([]*Fibre3D) all fibres on the ECM, time (float64) the time step in hours.
+func (cell *Cell3D) UpdateCell3D(fibres []*Fibre3D, time float64) {
+	nearbyFibres := cell.FindNearbyFibres3D(InteractionRadius, fibres)
+	cell.projection = cell.SteerFibreSnap3D(nearbyFibres)
+
+	// the motile force plus the traction of the nearby fibres over the drag coefficient, as in 2D
+	force := MultiplyVectorByConstant3D(cell.projection, MotileForce())
+	force = AddVectors3D(force, cell.ComputeTractionForce3D(nearbyFibres))
+	velocity := MultiplyVectorByConstant3D(force, 1/(cell.shapeFactor*cell.viscocity))
+	numSubsteps := NumSubsteps(velocity.Magnitude(), NoiseAmplitude, time, MaxStepFraction*cell.radius)
+	h := time / float64(numSubsteps)
+	for step := 0; step < numSubsteps; step++ {
+		noise := Vector3{rng.NormFloat64(), rng.NormFloat64(), rng.NormFloat64()}
+		displacement := AddVectors3D(MultiplyVectorByConstant3D(velocity, h), MultiplyVectorByConstant3D(noise, NoiseAmplitude*math.Sqrt(h)))
+		cell.position = WrapOnCube(AddVectors3D(cell.position, displacement))
+	}
+}
+
+// FindNearbyFibres3D: Returns the fibres whose centers are closer than threshold to the cell.
+func (cell *Cell3D) FindNearbyFibres3D(threshold float64, fibres []*Fibre3D) []*Fibre3D {
+	var nearbyFibres []*Fibre3D
+	for _, fibre := range fibres {
+		if ComputeDistance3D(cell.position, fibre.position) < threshold {
+			nearbyFibres = append(nearbyFibres, fibre)
+		}
+	}
+	return nearbyFibres
+}
+
+// SteerFibreSnap3D: The 3D form of FibreSnapSteering. Sums noisy projections of the cell's polarity onto the
+// nearby fibre directions, then snaps to the nearby fibre whose direction changes the projection the least.
+// Input: nearbyFibres ([]*Fibre3D) the fibres near the cell.
+// Output: (Vector3) the new projection, or the old one if there are no nearby fibres.
+func (cell *Cell3D) SteerFibreSnap3D(nearbyFibres []*Fibre3D) Vector3 {
+	if len(nearbyFibres) == 0 {
+		return cell.projection
+	}
+	// sum noisy projections onto nearby fibre directions
+	var netForce Vector3
+	for _, fibre := range nearbyFibres {
+		sign := 1.0
+		if DotProduct3D(cell.projection, fibre.direction) < 0 {
+			sign = -1.0
+		}
+		noise := sign * (1 + rng.NormFloat64())
+		direction := MultiplyVectorByConstant3D(fibre.direction, noise)
+		scale := DotProduct3D(cell.projection, direction) / DotProduct3D(direction, direction)
+		netForce = AddVectors3D(netForce, MultiplyVectorByConstant3D(direction, scale))
+	}
+	netForce.Normalize()
+
+	// then snap to the fibre causing the smallest change in direction
+	indexMin := -1
+	var smallest float64
+	for i, fibre := range nearbyFibres {
+		angle := AngleBetweenVectors3D(netForce, fibre.direction)
+		if angle > math.Pi/2 {
+			angle = math.Pi - angle
+		}
+		if indexMin < 0 || angle < smallest {
+			indexMin = i
+			smallest = angle
+		}
+	}
+	newProjection := nearbyFibres[indexMin].direction
+	newProjection.Normalize()
+	if DotProduct3D(netForce, newProjection) < 0 {
+		newProjection = MultiplyVectorByConstant3D(newProjection, -1.0)
+	}
+	if math.IsNaN(newProjection.x) {
+		return cell.projection
+	}
+	return newProjection
+}
+
+// ComputeTractionForce3D: The 3D form of ComputeTractionForce. Each nearby fibre pulls the cell along its own
+// direction by the projection of the cell's polarity onto it, and the pulls are averaged and scaled by
+// FibreTraction times the motile force.
+// Input: nearbyFibres ([]*Fibre3D) the fibres near the cell.
+// Output: (Vector3) the traction force, zero if there are no nearby fibres.
+func (cell *Cell3D) ComputeTractionForce3D(nearbyFibres []*Fibre3D) Vector3 {
+	var traction Vector3
+	if len(nearbyFibres) == 0 || FibreTraction == 0 {
+		return traction
+	}
+	for _, fibre := range nearbyFibres {
+		lengthSquared := DotProduct3D(fibre.direction, fibre.direction)
+		if lengthSquared > 0 {
+			traction = AddVectors3D(traction, MultiplyVectorByConstant3D(fibre.direction, DotProduct3D(cell.projection, fibre.direction)/lengthSquared))
+		}
+	}
+	return MultiplyVectorByConstant3D(traction, FibreTraction*MotileForce()/float64(len(nearbyFibres)))
+}
+
+// WrapOnCube: Puts a point back inside the cube [0, ECMwidth]^3, which wraps around in every direction.
+func WrapOnCube(p Vector3) Vector3 {
+	p.x -= ECMwidth * math.Floor(p.x/ECMwidth)
+	p.y -= ECMwidth * math.Floor(p.y/ECMwidth)
+	p.z -= ECMwidth * math.Floor(p.z/ECMwidth)
+	return p
+}
+
+// WriteToFile3D writes the 3D trajectories ([time, label, x, y, z] rows) to a csv file.
+func WriteToFile3D(positionArray [][]float64, filename string) {
+	stringArray := make([][]string, len(positionArray))
+	for index, row := range positionArray {
+		stringArray[index] = []string{
+			strconv.FormatFloat(row[0], 'f', -1, 64),
+			strconv.FormatFloat(row[1], 'f', 1, 64),
+			strconv.FormatFloat(row[2], 'f', -1, 64),
+			strconv.FormatFloat(row[3], 'f', -1, 64),
+			strconv.FormatFloat(row[4], 'f', -1, 64),
+		}
+	}
+
+	outFilePosition, err := os.Create(filename)
+	if err != nil {
+		panic("Error creating output csv file for 3D positions.")
+	}
+	defer outFilePosition.Close()
+
+	err = csv.NewWriter(outFilePosition).WriteAll(stringArray)
+	if err != nil {
+		panic("Error writing output csv file for 3D positions.")
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestRandomUnitVector3D(t *testing.T) {
+	SeedRandom(1)
+	var sum Vector3
+	const numVectors = 20000
+	for i := 0; i < numVectors; i++ {
+		v := RandomUnitVector3D()
+		if magnitude := v.Magnitude(); math.Abs(magnitude-1) > 1e-12 {
+			t.Fatalf("Error! For input test dataset %d, your code gives a vector of length %v, and the correct length is 1.", i, magnitude)
+		}
+		sum = AddVectors3D(sum, v)
+	}
+	// uniform on the sphere, so no direction is favoured
+	if mean := MultiplyVectorByConstant3D(sum, 1.0/numVectors); mean.Magnitude() > 0.03 {
+		t.Errorf("Error! Your code gives a mean direction of %+v, and the correct mean is close to 0.", mean)
+	}
+}
+
+func TestRotateAboutAxis3D(t *testing.T) {
+	type test struct {
+		v, axis Vector3
+		degrees float64
+		answer  Vector3
+	}
+
+	tests := make([]test, 5)
+	tests[0] = test{Vector3{1, 0, 0}, Vector3{0, 0, 1}, 90, Vector3{0, 1, 0}}
+	tests[1] = test{Vector3{0, 1, 0}, Vector3{1, 0, 0}, 90, Vector3{0, 0, 1}}
+	tests[2] = test{Vector3{1, 2, 3}, Vector3{0, 0, 1}, 180, Vector3{-1, -2, 3}}
+	// a vector along the axis doesn't move
+	tests[3] = test{Vector3{0, 0, 2}, Vector3{0, 0, 1}, 37, Vector3{0, 0, 2}}
+	// a third of a turn about the diagonal cycles the coordinate axes
+	tests[4] = test{Vector3{1, 0, 0}, Vector3{1 / math.Sqrt(3), 1 / math.Sqrt(3), 1 / math.Sqrt(3)}, 120, Vector3{0, 1, 0}}
+
+	for i, test := range tests {
+		outcome := RotateAboutAxis3D(test.v, test.axis, test.degrees*math.Pi/180)
+		if ComputeDistance3D(outcome, test.answer) > 1e-9 {
+			t.Errorf("Error! For input test dataset %d, your code gives %+v, and the correct vector is %+v.", i, outcome, test.answer)
+		}
+	}
+}
+
+// TestUpdateFibre3D checks that one step turns a fibre towards the cell in the plane of the fibre and the cell,
+// about the end furthest from the cell, without changing its length.
+func TestUpdateFibre3D(t *testing.T) {
+	coefficient := AlignFactorCoefficient
+	defer func() { AlignFactorCoefficient = coefficient }()
+	AlignFactorCoefficient = 0.1
+
+	fibre := Fibre3D{length: 20, position: Vector3{50, 50, 50}, direction: Vector3{1, 0, 0}}
+	cell := Cell3D{integrin: 5, position: Vector3{70, 50, 80}}
+	// the pivot is (40, 50, 50), the end furthest from the cell, which is 45 degrees off the fibre
+	fibre.UpdateFibre3D(&cell, 0.5)
+
+	pivot := Vector3{40, 50, 50}
+	toCell := SubtractVectors3D(cell.position, pivot)
+	if ComputeDistance3D(fibre.pivot, pivot) > 1e-9 {
+		t.Errorf("Error! Your code gives a pivot of %+v, and the correct pivot is %+v.", fibre.pivot, pivot)
+	}
+	endpoint1, endpoint2 := fibre.GetEndpoints()
+	if length := ComputeDistance3D(endpoint1, endpoint2); math.Abs(length-20) > 1e-9 {
+		t.Errorf("Error! Your code gives a fibre of length %v, and the correct length is 20.", length)
+	}
+	if math.Min(ComputeDistance3D(endpoint1, pivot), ComputeDistance3D(endpoint2, pivot)) > 1e-9 {
+		t.Errorf("Error! Your code moves the pivot off the ends %+v and %+v.", endpoint1, endpoint2)
+	}
+	// the fibre stays in the y = 50 plane and turns from 45 degrees away from the cell to phi less than that
+	theta := math.Pi / 4
+	answer := math.Asin((1 - 0.1*5*0.5) * math.Sin(theta))
+	if outcome := AngleBetweenVectors3D(fibre.direction, toCell); math.Abs(outcome-answer) > 1e-9 || math.Abs(fibre.direction.y) > 1e-12 {
+		t.Errorf("Error! Your code gives the direction %+v, %v radians from the cell, and the correct angle is %v in the y = 50 plane.", fibre.direction, outcome, answer)
+	}
+}
+
+func TestCheck3DOptions(t *testing.T) {
+	type test struct {
+		change func()
+		answer bool // whether the options are rejected
+	}
+
+	tests := make([]test, 8)
+	tests[0] = test{func() {}, false}
+	tests[1] = test{func() { CellSteering = RunAndTumble{} }, true}
+	tests[2] = test{func() { SoftBodyCells = true }, true}
+	tests[3] = test{func() { RecoilTime = 1 }, true}
+	tests[4] = test{func() { CrosslinkDensity = 0.5 }, true}
+	tests[5] = test{func() { CellTypes = []CellType{{name: "leader", fraction: 1, degradationRate: 2}} }, true}
+	tests[6] = test{func() { CellDoublingTime = 10 }, true}
+	// noise is simulated in 3D too
+	tests[7] = test{func() { NoiseAmplitude = 5 }, false}
+
+	dimensions, steering, softBody, recoilTime, density, cellTypes, doublingTime, apoptosisRate, noise := Dimensions, CellSteering, SoftBodyCells, RecoilTime, CrosslinkDensity, CellTypes, CellDoublingTime, CellApoptosisRate, NoiseAmplitude
+	defer func() {
+		Dimensions, CellSteering, SoftBodyCells, RecoilTime, CrosslinkDensity, CellTypes, CellDoublingTime, CellApoptosisRate, NoiseAmplitude = dimensions, steering, softBody, recoilTime, density, cellTypes, doublingTime, apoptosisRate, noise
+	}()
+	for i, test := range tests {
+		CellSteering, SoftBodyCells, RecoilTime, CrosslinkDensity = FibreSnapSteering{}, false, 0, 0
+		CellTypes, CellDoublingTime, CellApoptosisRate, NoiseAmplitude = []CellType{{name: "default", fraction: 1}}, 0, 0, 0
+		test.change()
+		Dimensions = 3
+		if outcome := Panics(Check3DOptions); outcome != test.answer {
+			t.Errorf("Error! For input test dataset %d, your code gives %v, and the correct answer is %v.", i, outcome, test.answer)
+		}
+		// the same options are all fine in 2D
+		Dimensions = 2
+		if Panics(Check3DOptions) {
+			t.Errorf("Error! For input test dataset %d, your code rejects a 2D run.", i)
+		}
+	}
+}
+
+// TestCheck3DSteering checks every steering model a run can be given: only fibre-snap has a 3D form, so the
+// others must be refused rather than silently replaced by it.
+func TestCheck3DSteering(t *testing.T) {
+	dimensions, steering := Dimensions, CellSteering
+	defer func() { Dimensions, CellSteering = dimensions, steering }()
+	Dimensions = 3
+	for _, name := range SteeringModelNames() {
+		CellSteering, _ = GetSteeringModel(name)
+		answer := name != "fibre-snap"
+		if outcome := Panics(Check3DOptions); outcome != answer {
+			t.Errorf("Error! For the steering model %q, your code gives %v, and the correct answer is %v.", name, outcome, answer)
+		}
+	}
+}
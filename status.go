@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// RunStatus records how far a run got. It is written to RunStatus.json with the other outputs so
+// partial results can be told apart from complete ones.
+type RunStatus struct {
+	State       string  `json:"state"`       // "complete", "cancelled" or "timed out"
+	Requested   int     `json:"requested"`   // generations asked for
+	Generations int     `json:"generations"` // generations simulated
+	Frames      int     `json:"frames"`      // frames drawn, 0 if drawing was skipped or stopped
+	Seconds     float64 `json:"seconds"`     // wall clock time of the whole run
+}
+
+// StateFromContext: Returns "complete" if ctx hasn't ended, otherwise "timed out" or "cancelled"
+// depending on why it ended.
+func StateFromContext(ctx context.Context) string {
+	switch {
+	case ctx.Err() == nil:
+		return "complete"
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return "timed out"
+	}
+	return "cancelled"
+}
+
+// String: Describes the status in a sentence, e.g. "Timed out after 120 of 300 generations."
+func (status RunStatus) String() string {
+	if status.State == "complete" {
+		return fmt.Sprintf("Finished %d generations.", status.Generations)
+	}
+	state := "Cancelled"
+	if status.State == "timed out" {
+		state = "Timed out"
+	}
+	return fmt.Sprintf("%s after %d of %d generations. The results so far were written out.", state, status.Generations, status.Requested)
+}
+
+// WriteToFile: Writes the status as JSON.
+func (status RunStatus) WriteToFile(filename string) {
+	data, err := json.MarshalIndent(status, "", "  ")
+	if err != nil {
+		panic("Error encoding run status.")
+	}
+	if err := os.WriteFile(filename, data, 0644); err != nil {
+		panic("Error writing " + filename + ".")
+	}
+}
+
+// ReadRunStatus: Reads a status written by RunStatus.WriteToFile.
+func ReadRunStatus(filename string) (RunStatus, error) {
+	var status RunStatus
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return status, err
+	}
+	err = json.Unmarshal(data, &status)
+	return status, err
+}
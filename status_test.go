@@ -0,0 +1,31 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestStateFromContext(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	timedOut, cancelTimeout := context.WithTimeout(context.Background(), -time.Second)
+	defer cancelTimeout()
+
+	type test struct {
+		ctx    context.Context
+		answer string
+	}
+
+	tests := make([]test, 3)
+	tests[0].ctx, tests[0].answer = context.Background(), "complete"
+	tests[1].ctx, tests[1].answer = cancelled, "cancelled"
+	tests[2].ctx, tests[2].answer = timedOut, "timed out"
+
+	for i, test := range tests {
+		outcome := StateFromContext(test.ctx)
+		if outcome != test.answer {
+			t.Errorf("Error! For input test dataset %d, your code gives %q, and the correct state is %q", i, outcome, test.answer)
+		}
+	}
+}
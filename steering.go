@@ -0,0 +1,179 @@
+package main
+
+import (
+	"math"
+	"sort"
+)
+
+// SteeringModel decides which way a cell points next. Steer is given the cell, the fibres near it and the
+// time step, and returns the cell's new (unit) projection vector.
+type SteeringModel interface {
+	Steer(cell *Cell, nearbyFibres []*Fibre, time float64) OrderedPair
+}
+
+// SteeringModels maps the names that can be selected for a run to their steering models.
+var SteeringModels = map[string]SteeringModel{
+	"fibre-snap":             FibreSnapSteering{},
+	"persistent-random-walk": PersistentRandomWalk{},
+	"contact-guidance":       DistanceWeightedGuidance{},
+	"run-and-tumble":         RunAndTumble{},
+}
+
+// GetSteeringModel: Looks up a steering model by name.
+// Input: name (string) the name of the model.
+// Output: (SteeringModel, bool) the model and whether the name exists.
+func GetSteeringModel(name string) (SteeringModel, bool) {
+	model, ok := SteeringModels[name]
+	return model, ok
+}
+
+// SteeringModelNames: Returns the names of all steering models in alphabetical order.
+func SteeringModelNames() []string {
+	names := make([]string, 0, len(SteeringModels))
+	for name := range SteeringModels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// CheckSteeringOptions: Panics if the selected steering model can't be simulated with the current settings.
+// The random walk and contact guidance divide by PersistenceTime, and a negative TumbleRate isn't a rate.
+func CheckSteeringOptions() {
+	switch CellSteering.(type) {
+	case PersistentRandomWalk, DistanceWeightedGuidance:
+		if PersistenceTime <= 0 {
+			panic("Error: the persistence time must be above 0 for the " + SteeringModelName(CellSteering) + " steering model.")
+		}
+	case RunAndTumble:
+		if TumbleRate < 0 {
+			panic("Error: the tumble rate can't be negative.")
+		}
+	}
+	if _, ok := CellSteering.(DistanceWeightedGuidance); ok && GuidanceLength <= 0 {
+		panic("Error: the guidance length must be above 0 for the contact-guidance steering model.")
+	}
+}
+
+// SteeringModelName: Returns the name a steering model is selected by, or "" if it isn't one of SteeringModels.
+func SteeringModelName(model SteeringModel) string {
+	for name, candidate := range SteeringModels {
+		if candidate == model {
+			return name
+		}
+	}
+	return ""
+}
+
+// FibreSnapSteering is the original rule: sum noisy projections of the cell's polarity onto nearby fibre
+// directions, then snap to the nearby fibre whose direction changes the projection the least.
+type FibreSnapSteering struct{}
+
+// Steer implements SteeringModel for FibreSnapSteering.
+func (FibreSnapSteering) Steer(cell *Cell, nearbyFibres []*Fibre, time float64) OrderedPair {
+	projection := cell.CalculateNewProjection(nearbyFibres) // Normalized net force acting on the cell from all nearby fibres
+	if len(nearbyFibres) == 0 {
+		return cell.projection
+	}
+
+	indexMin := FindSmallestAngleChange(projection, nearbyFibres)
+	return AlignWith(projection, nearbyFibres[indexMin].direction)
+}
+
+// FindSmallestAngleChange: Finds the fibre whose direction is closest to a projection vector, treating fibres as undirected.
+// Input: projection (OrderedPair) the cell's projection, fibres ([]*Fibre) the candidate fibres.
+// Output: (int) the index of the closest fibre, or -1 if there are no fibres.
+func FindSmallestAngleChange(projection OrderedPair, fibres []*Fibre) int {
+	var changeMagnitude float64
+	indexMin := -1
+	for index, fibre := range fibres {
+		deltaMagnitude := math.Abs(FindAngleChange(projection, fibre.direction))
+		if indexMin < 0 || deltaMagnitude < changeMagnitude {
+			changeMagnitude = deltaMagnitude
+			indexMin = index
+		}
+	}
+	return indexMin
+}
+
+// PersistentRandomWalk ignores the fibres. The projection diffuses on the unit circle so that the direction
+// decorrelates over PersistenceTime hours.
+type PersistentRandomWalk struct{}
+
+// Steer implements SteeringModel for PersistentRandomWalk.
+func (PersistentRandomWalk) Steer(cell *Cell, nearbyFibres []*Fibre, time float64) OrderedPair {
+	sd := math.Sqrt(2 * time / PersistenceTime)
+	return RotateVector(UnitOrRandom(cell.projection), rng.NormFloat64()*sd)
+}
+
+// DistanceWeightedGuidance turns the cell towards the average direction of the nearby fibres, with each fibre
+// weighted by exp(-distance/GuidanceLength) where distance is measured to the closest point on the fibre.
+// Fibres are treated as undirected, so each one is flipped to point the same way as the cell before averaging.
+type DistanceWeightedGuidance struct{}
+
+// Steer implements SteeringModel for DistanceWeightedGuidance.
+func (DistanceWeightedGuidance) Steer(cell *Cell, nearbyFibres []*Fibre, time float64) OrderedPair {
+	current := UnitOrRandom(cell.projection)
+	var guidance OrderedPair
+	for _, fibre := range nearbyFibres {
+		weight := math.Exp(-fibre.DistanceToPoint(cell.position) / GuidanceLength)
+		direction := AlignWith(current, fibre.direction)
+		guidance.x += weight * direction.x
+		guidance.y += weight * direction.y
+	}
+	if guidance.Magnitude() == 0 {
+		return current
+	}
+	guidance.Normalize()
+	// relax towards the guidance direction with a time constant of PersistenceTime
+	blend := 1 - math.Exp(-time/PersistenceTime)
+	newProjection := OrderedPair{(1-blend)*current.x + blend*guidance.x, (1-blend)*current.y + blend*guidance.y}
+	return UnitOrRandom(newProjection)
+}
+
+// RunAndTumble keeps running in a straight line and tumbles to a new direction at rate TumbleRate per hour.
+// After a tumble the cell picks up a nearby fibre to run along if there is one, otherwise a random direction.
+type RunAndTumble struct{}
+
+// Steer implements SteeringModel for RunAndTumble.
+func (RunAndTumble) Steer(cell *Cell, nearbyFibres []*Fibre, time float64) OrderedPair {
+	if rng.Float64() >= 1-math.Exp(-TumbleRate*time) {
+		return UnitOrRandom(cell.projection)
+	}
+	if len(nearbyFibres) > 0 {
+		fibre := nearbyFibres[rng.Intn(len(nearbyFibres))]
+		direction := fibre.direction
+		direction.Normalize()
+		if rng.Intn(2) == 0 {
+			direction = MultiplyVectorByConstant2D(direction, -1.0)
+		}
+		return direction
+	}
+	return RotateVector(OrderedPair{1, 0}, rng.Float64()*2*math.Pi)
+}
+
+// AlignWith: Returns v2 normalized and flipped if necessary so that it points the same way as v1.
+// Making sure the cell doesn't do a 180.
+func AlignWith(v1, v2 OrderedPair) OrderedPair {
+	v2.Normalize()
+	if DotProduct2D(v1, v2) < 0 {
+		v2 = MultiplyVectorByConstant2D(v2, -1.0)
+	}
+	return v2
+}
+
+// RotateVector: Rotates a vector counter-clockwise by some angle.
+// Input: v (OrderedPair) the vector, angle (float64) the angle in radians.
+// Output: (OrderedPair) the rotated vector.
+func RotateVector(v OrderedPair, angle float64) OrderedPair {
+	return OrderedPair{v.x*math.Cos(angle) - v.y*math.Sin(angle), v.x*math.Sin(angle) + v.y*math.Cos(angle)}
+}
+
+// UnitOrRandom: Returns v normalized, or a random unit vector if v is zero.
+func UnitOrRandom(v OrderedPair) OrderedPair {
+	if v.Magnitude() == 0 {
+		return RotateVector(OrderedPair{1, 0}, rng.Float64()*2*math.Pi)
+	}
+	v.Normalize()
+	return v
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFindSmallestAngleChange(t *testing.T) {
+	type test struct {
+		projection OrderedPair
+		angles     []float64 // fibre directions in degrees
+		answer     int
+	}
+
+	tests := make([]test, 4)
+	tests[0].projection = OrderedPair{1, 0}
+	tests[0].angles = []float64{60, 10, 80}
+	tests[0].answer = 1
+
+	tests[1].projection = OrderedPair{1, 0}
+	tests[1].angles = []float64{5, 40, 90}
+	tests[1].answer = 0
+
+	// fibres are undirected, so a fibre at 175 degrees is only 5 degrees away
+	tests[2].projection = OrderedPair{1, 0}
+	tests[2].angles = []float64{30, 175, -20}
+	tests[2].answer = 1
+
+	tests[3].projection = OrderedPair{0, 1}
+	tests[3].angles = []float64{}
+	tests[3].answer = -1
+
+	for i, test := range tests {
+		fibres := make([]*Fibre, len(test.angles))
+		for j, angle := range test.angles {
+			var fibre Fibre
+			fibre.direction = OrderedPair{math.Cos(angle * math.Pi / 180), math.Sin(angle * math.Pi / 180)}
+			fibres[j] = &fibre
+		}
+		outcome := FindSmallestAngleChange(test.projection, fibres)
+		if outcome != test.answer {
+			t.Errorf("Error! For input test dataset %d, your code gives %d, and the closest fibre is %d", i, outcome, test.answer)
+		}
+	}
+}
+
+func TestCheckSteeringOptions(t *testing.T) {
+	type test struct {
+		model                       string
+		persistenceTime, tumbleRate float64
+		answer                      bool // whether the options are rejected
+	}
+
+	tests := make([]test, 8)
+	tests[0] = test{"persistent-random-walk", 2, 0.5, false}
+	// Steer would divide by 0 and give NaN
+	tests[1] = test{"persistent-random-walk", 0, 0.5, true}
+	tests[2] = test{"contact-guidance", -1, 0.5, true}
+	tests[3] = test{"contact-guidance", 0.1, -3, false}
+	tests[4] = test{"run-and-tumble", 0, 0, false}
+	tests[5] = test{"run-and-tumble", 2, -0.5, true}
+	// the other models don't use the persistence time
+	tests[6] = test{"fibre-snap", 0, 0.5, false}
+	tests[7] = test{"run-and-tumble", 0, 0.5, false}
+
+	steering, persistenceTime, tumbleRate := CellSteering, PersistenceTime, TumbleRate
+	defer func() { CellSteering, PersistenceTime, TumbleRate = steering, persistenceTime, tumbleRate }()
+	for i, test := range tests {
+		CellSteering, _ = GetSteeringModel(test.model)
+		PersistenceTime, TumbleRate = test.persistenceTime, test.tumbleRate
+		outcome := Panics(CheckSteeringOptions)
+		if outcome != test.answer {
+			t.Errorf("Error! For input test dataset %d, your code gives %v, and the correct answer is %v.", i, outcome, test.answer)
+		}
+	}
+}
+
+// Panics: Reports whether f panics.
+func Panics(f func()) (panicked bool) {
+	defer func() { panicked = recover() != nil }()
+	f()
+	return false
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ParseSnapshotGenerations: Parses a comma separated list of generations to save as SVG snapshots,
+// e.g. "0,100,-1". Negative generations count back from the end, so -1 is the last generation.
+// Input: spec (string) the list, numFrames (int) the number of time frames.
+// Output: ([]int) the generations, each in [0, numFrames), and an error if the list can't be read.
+func ParseSnapshotGenerations(spec string, numFrames int) ([]int, error) {
+	return parseSnapshotGenerations(spec, numFrames, false)
+}
+
+// AvailableSnapshotGenerations: Like ParseSnapshotGenerations, but generations past the end of a run that was
+// stopped early are skipped rather than rejected. Negative generations count back from the last generation there is.
+func AvailableSnapshotGenerations(spec string, numFrames int) ([]int, error) {
+	return parseSnapshotGenerations(spec, numFrames, true)
+}
+
+// parseSnapshotGenerations: Does the work of ParseSnapshotGenerations and AvailableSnapshotGenerations.
+// skipMissing says whether generations outside [0, numFrames) are skipped (true) or an error (false).
+func parseSnapshotGenerations(spec string, numFrames int, skipMissing bool) ([]int, error) {
+	var generations []int
+	if strings.TrimSpace(spec) == "" {
+		return generations, nil
+	}
+	for _, field := range strings.Split(spec, ",") {
+		generation, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil {
+			return nil, fmt.Errorf("bad snapshot generation %q", field)
+		}
+		if generation < 0 {
+			generation += numFrames
+		}
+		if generation < 0 || generation >= numFrames {
+			if skipMissing {
+				continue
+			}
+			return nil, fmt.Errorf("snapshot generation %s is outside 0 to %d", field, numFrames-1)
+		}
+		generations = append(generations, generation)
+	}
+	return generations, nil
+}
+
+// CheckSnapshotGenerations: Panics if SnapshotGenerations can't be read or names a generation a run of numGens
+// generations won't have, so a bad list is caught before the simulation rather than after it.
+func CheckSnapshotGenerations(numGens int) {
+	if _, err := ParseSnapshotGenerations(SnapshotGenerations, numGens+1); err != nil {
+		panic("Error reading snapshot generations: " + err.Error())
+	}
+}
+
+// WriteSnapshotsToFile: Writes an SVG snapshot of each generation in SnapshotGenerations
+// to <prefix>_<generation>.svg. Generations a stopped run didn't get to are skipped.
+// Input: timeFrames ([]*ECM) the ECM at every time point, positionArray ([][]float64) the cell positions
+// used for trajectories, timeStep (float64) hours per generation, prefix (string) start of the file names.
+func WriteSnapshotsToFile(timeFrames []*ECM, positionArray [][]float64, timeStep float64, prefix string) {
+	generations, err := AvailableSnapshotGenerations(SnapshotGenerations, len(timeFrames))
+	if err != nil {
+		panic("Error reading snapshot generations: " + err.Error())
+	}
+	var trajectories []Trajectory
+	if SnapshotTrajectories {
+		trajectories = ExtractTrajectories(positionArray, 0)
+	}
+	for _, generation := range generations {
+		svg := timeFrames[generation].ToSVG(float64(generation)*timeStep, trajectories, SnapshotScaleBar)
+		filename := fmt.Sprintf("%s_%d.svg", prefix, generation)
+		if err := os.WriteFile(filename, []byte(svg), 0644); err != nil {
+			panic("Error writing " + filename + ".")
+		}
+	}
+}
+
+// ToSVG: Draws the ECM as an SVG. Coordinates are in micrometres, so the snapshot stays sharp at any zoom.
+// Fibres are lines, cells are circles (or polygons for soft-body cells).
+// Input: time (float64) the time of this frame in hours, trajectories ([]Trajectory) cell tracks to draw up
+// to time (nil for none), scaleBar (float64) length of the scale bar in micrometres (0 for none).
+// Output: (string) the SVG document.
+func (e *ECM) ToSVG(time float64, trajectories []Trajectory, scaleBar float64) string {
+	if e == nil {
+		panic("Can't Draw a nil ECM.")
+	}
+	width := ECMwidth
+
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "<svg xmlns='http://www.w3.org/2000/svg' width='1000' height='1000' viewBox='0 0 %g %g'>\n", width, width)
+	fmt.Fprintf(&builder, "<rect width='%g' height='%g' fill='black'/>\n", width, width)
+
+	// same colours as DrawToCanvas
+	fmt.Fprintf(&builder, "<g stroke-linecap='round'>\n")
+	for _, f := range e.fibres {
+		half := MultiplyVectorByConstant2D(f.direction, 0.5*f.length/f.direction.Magnitude())
+		fmt.Fprintf(&builder, "<line x1='%.3f' y1='%.3f' x2='%.3f' y2='%.3f' stroke-width='%g' stroke='%s'/>\n",
+			f.position.x-half.x, f.position.y-half.y, f.position.x+half.x, f.position.y+half.y, f.width, SVGColour(FibreColour(f)))
+	}
+	fmt.Fprintf(&builder, "</g>\n")
+
+	if len(trajectories) > 0 {
+		fmt.Fprintf(&builder, "<g stroke='rgb(250,220,120)' stroke-width='%g' fill='none'>\n", width/500)
+		for _, trajectory := range trajectories {
+			for _, segment := range trajectory.WrappedSegments(time, width) {
+				fmt.Fprintf(&builder, "<polyline points='%s'/>\n", SVGPoints(segment))
+			}
+		}
+		fmt.Fprintf(&builder, "</g>\n")
+	}
+
+	fmt.Fprintf(&builder, "<g>\n")
+	for _, c := range e.cells {
+		if SoftBodyCells && len(c.perimeterVertices) > 2 {
+			fmt.Fprintf(&builder, "<polygon points='%s' fill='%s'/>\n", SVGPoints(c.perimeterVertices), SVGColour(CellColour(c)))
+			continue
+		}
+		fmt.Fprintf(&builder, "<circle cx='%.3f' cy='%.3f' r='%g' fill='%s'/>\n", c.position.x, c.position.y, c.radius, SVGColour(CellColour(c)))
+	}
+	fmt.Fprintf(&builder, "</g>\n")
+
+	if scaleBar > 0 {
+		margin := width / 25
+		fmt.Fprintf(&builder, "<line x1='%g' y1='%g' x2='%g' y2='%g' stroke='white' stroke-width='%g'/>\n",
+			margin, width-margin, margin+scaleBar, width-margin, width/200)
+		fmt.Fprintf(&builder, "<text x='%g' y='%g' fill='white' font-family='sans-serif' font-size='%g' text-anchor='middle'>%g &#181;m</text>\n",
+			margin+scaleBar/2, width-margin-width/100, width/40, scaleBar)
+	}
+	fmt.Fprintf(&builder, "<text x='%g' y='%g' fill='white' font-family='sans-serif' font-size='%g'>t = %.2f h</text>\n",
+		width/50, width/25, width/40, time)
+	fmt.Fprintf(&builder, "</svg>\n")
+	return builder.String()
+}
+
+// WrappedSegments: Returns the points of a trajectory up to a given time, split wherever the cell
+// crossed the edge of the board so no line is drawn across the whole board.
+// Input: time (float64) the last time to include, width (float64) the width of the board.
+func (t Trajectory) WrappedSegments(time, width float64) [][]OrderedPair {
+	var segments [][]OrderedPair
+	var current []OrderedPair
+	for i := range t.positions {
+		if t.times[i] > time+1e-9 {
+			break
+		}
+		if len(current) > 0 {
+			previous := current[len(current)-1]
+			if math.Abs(t.positions[i].x-previous.x) > width/2 || math.Abs(t.positions[i].y-previous.y) > width/2 {
+				if len(current) > 1 {
+					segments = append(segments, current)
+				}
+				current = nil
+			}
+		}
+		current = append(current, t.positions[i])
+	}
+	if len(current) > 1 {
+		segments = append(segments, current)
+	}
+	return segments
+}
+
+// SVGColour: Formats an RGB colour for an SVG attribute.
+func SVGColour(rgb [3]uint8) string {
+	return fmt.Sprintf("rgb(%d,%d,%d)", rgb[0], rgb[1], rgb[2])
+}
+
+// SVGPoints: Formats points for the points attribute of an SVG polyline or polygon.
+func SVGPoints(points []OrderedPair) string {
+	fields := make([]string, len(points))
+	for i, p := range points {
+		fields[i] = fmt.Sprintf("%.3f,%.3f", p.x, p.y)
+	}
+	return strings.Join(fields, " ")
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseSnapshotGenerations(t *testing.T) {
+	type test struct {
+		spec   string
+		answer string
+	}
+
+	tests := make([]test, 3)
+	tests[0].spec = "0,5,-1"
+	tests[0].answer = "[0 5 10]"
+
+	tests[1].spec = ""
+	tests[1].answer = "[]"
+
+	tests[2].spec = " 3 , -11"
+	tests[2].answer = "[3 0]"
+
+	for i, test := range tests {
+		outcome, err := ParseSnapshotGenerations(test.spec, 11)
+		if err != nil {
+			t.Errorf("Error! For input test dataset %d, your code returns the error %v", i, err)
+			continue
+		}
+		if fmt.Sprint(outcome) != test.answer {
+			t.Errorf("Error! For input test dataset %d, your code gives %v, and the correct generations are %s", i, outcome, test.answer)
+		}
+	}
+
+	for _, spec := range []string{"a", "11", "-12", "1,,2"} {
+		if _, err := ParseSnapshotGenerations(spec, 11); err == nil {
+			t.Errorf("Error! %q should not be accepted", spec)
+		}
+	}
+}
+
+func TestAvailableSnapshotGenerations(t *testing.T) {
+	type test struct {
+		spec   string
+		answer string
+	}
+
+	// a run of 100 generations that was stopped after generation 10
+	tests := make([]test, 4)
+	tests[0] = test{"0,5,-1", "[0 5 10]"}
+	tests[1] = test{"0,50,100,-1", "[0 10]"}
+	tests[2] = test{"-50", "[]"}
+	tests[3] = test{"", "[]"}
+
+	for i, test := range tests {
+		outcome, err := AvailableSnapshotGenerations(test.spec, 11)
+		if err != nil {
+			t.Errorf("Error! For input test dataset %d, your code returns the error %v", i, err)
+			continue
+		}
+		if fmt.Sprint(outcome) != test.answer {
+			t.Errorf("Error! For input test dataset %d, your code gives %v, and the correct generations are %s", i, outcome, test.answer)
+		}
+	}
+	for _, spec := range []string{"a", "1,,2", "0;1"} {
+		if _, err := AvailableSnapshotGenerations(spec, 11); err == nil {
+			t.Errorf("Error! %q should not be accepted", spec)
+		}
+	}
+}
+
+// TestWriteSnapshotsPartialRun checks that a stopped run writes the snapshots it has and skips the rest.
+func TestWriteSnapshotsPartialRun(t *testing.T) {
+	generations := SnapshotGenerations
+	defer func() { SnapshotGenerations = generations }()
+	SnapshotGenerations = "0,2,150,-1"
+
+	timeFrames := []*ECM{{}, {}, {}, {}}
+	prefix := filepath.Join(t.TempDir(), "Snapshot")
+	WriteSnapshotsToFile(timeFrames, nil, 0.75, prefix)
+	for _, generation := range []int{0, 2, 3} {
+		if _, err := os.Stat(fmt.Sprintf("%s_%d.svg", prefix, generation)); err != nil {
+			t.Errorf("Error! Your code doesn't write the snapshot of generation %d, and the correct code does.", generation)
+		}
+	}
+	if files, _ := filepath.Glob(prefix + "_*.svg"); len(files) != 3 {
+		t.Errorf("Error! Your code writes the snapshots %v, and the correct number is 3.", files)
+	}
+}
@@ -0,0 +1,371 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SweepParameterNames are the RunSimulation parameters that can be swept, in the order they appear in the results table.
+var SweepParameterNames = []string{"numGens", "numCells", "numFibres", "timeStep", "width", "cellSpeed", "stiffness"}
+
+// SweepStatistics are the summary statistics collected from every run, in the order they appear in the results table.
+var SweepStatistics = []string{"meanSpeed", "directionality", "persistence", "meanTurningAngle", "tortuosity", "finalOrder"}
+
+// SweepJob is a single simulation in a sweep.
+type SweepJob struct {
+	combination int               // index of the parameter combination
+	replicate   int               // replicate number within the combination
+	seed        int64             // random seed of the run
+	values      map[string]string // parameter name -> value
+	dir         string            // directory the run writes its output to
+}
+
+// ParseSweepValues: Parses the values a parameter takes in a sweep. Values are either a comma separated list
+// ("10,15,20") or a range written start:stop:step ("0.5:0.95:0.15"), which includes stop.
+// Input: spec (string) the values.
+// Output: ([]string) the individual values, or an error if spec is malformed.
+func ParseSweepValues(spec string) ([]string, error) {
+	if !strings.Contains(spec, ":") {
+		var values []string
+		for _, value := range strings.Split(spec, ",") {
+			value = strings.TrimSpace(value)
+			if _, err := strconv.ParseFloat(value, 64); err != nil {
+				return nil, fmt.Errorf("invalid value %q", value)
+			}
+			values = append(values, value)
+		}
+		return values, nil
+	}
+	fields := strings.Split(spec, ":")
+	if len(fields) != 3 {
+		return nil, fmt.Errorf("invalid range %q: expected start:stop:step", spec)
+	}
+	bounds := make([]float64, 3)
+	for i, field := range fields {
+		value, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid range %q: %v", spec, err)
+		}
+		bounds[i] = value
+	}
+	start, stop, step := bounds[0], bounds[1], bounds[2]
+	if step <= 0 || stop < start {
+		return nil, fmt.Errorf("invalid range %q: step must be positive and stop at least start", spec)
+	}
+	var values []string
+	numValues := int(math.Floor((stop-start)/step+1e-9)) + 1
+	for i := 0; i < numValues; i++ {
+		values = append(values, strconv.FormatFloat(start+float64(i)*step, 'f', -1, 64))
+	}
+	return values, nil
+}
+
+// ParameterGrid: Returns every combination of parameter values, varying the last parameter fastest.
+// Input: names ([]string) the parameter names, values ([][]string) the values of each parameter.
+// Output: ([]map[string]string) one map of parameter name -> value per combination.
+func ParameterGrid(names []string, values [][]string) []map[string]string {
+	grid := []map[string]string{{}}
+	for i, name := range names {
+		var newGrid []map[string]string
+		for _, combination := range grid {
+			for _, value := range values[i] {
+				newCombination := make(map[string]string, len(combination)+1)
+				for key, existing := range combination {
+					newCombination[key] = existing
+				}
+				newCombination[name] = value
+				newGrid = append(newGrid, newCombination)
+			}
+		}
+		grid = newGrid
+	}
+	return grid
+}
+
+// RunSweep: Parses the flags of the "sweep" command, runs every parameter combination the requested number of
+// times on a pool of workers, and writes SweepResults.csv with the mean and standard deviation of each summary
+// statistic per combination. Each run is a separate "run" process with its own seed and output directory.
+func RunSweep(args []string) {
+	flags := flag.NewFlagSet("sweep", flag.ExitOnError)
+	defaults := map[string]string{
+		"numGens": strconv.Itoa(DefaultRunParameters.numGens), "numCells": strconv.Itoa(DefaultRunParameters.numCells),
+		"numFibres": strconv.Itoa(DefaultRunParameters.numFibres), "timeStep": FormatValue(DefaultRunParameters.timeStep),
+		"width": FormatValue(DefaultRunParameters.width), "cellSpeed": FormatValue(DefaultRunParameters.cellSpeed),
+		"stiffness": FormatValue(DefaultRunParameters.stiffness),
+	}
+	specs := make(map[string]*string)
+	for _, name := range SweepParameterNames {
+		specs[name] = flags.String(name, defaults[name], "values of "+name+": a list a,b,c or a range start:stop:step")
+	}
+	replicates := flags.Int("replicates", 1, "number of runs per parameter combination")
+	workers := flags.Int("workers", runtime.NumCPU(), "number of simulations to run at once")
+	seed := flags.Int64("seed", DefaultSeed(), "seed of the first run; run i uses seed+i (at least 1)")
+	out := flags.String("out", "sweep", "directory to write the runs and results to")
+	flags.DurationVar(&TimeLimit, "timeout", TimeLimit, "time limit of each simulation, e.g. 10m (0 for no limit); runs that hit it count as failed")
+	flags.Parse(args)
+	if *seed < 1 {
+		// every run is given a seed counting up from this one, and "run" reads 0 as "seed from the clock"
+		fmt.Println("Error: -seed must be at least 1.")
+		os.Exit(2)
+	}
+
+	values := make([][]string, len(SweepParameterNames))
+	for i, name := range SweepParameterNames {
+		parsed, err := ParseSweepValues(*specs[name])
+		if err != nil {
+			fmt.Println("Error in -" + name + ": " + err.Error())
+			os.Exit(2)
+		}
+		values[i] = parsed
+	}
+	grid := ParameterGrid(SweepParameterNames, values)
+
+	var jobs []SweepJob
+	for combination, parameters := range grid {
+		for replicate := 0; replicate < *replicates; replicate++ {
+			jobs = append(jobs, SweepJob{
+				combination: combination,
+				replicate:   replicate,
+				seed:        *seed + int64(len(jobs)),
+				values:      parameters,
+				dir:         filepath.Join(*out, fmt.Sprintf("combination%03d_replicate%02d", combination, replicate)),
+			})
+		}
+	}
+	fmt.Printf("Running %d parameter combinations x %d replicates = %d simulations on %d workers.\n",
+		len(grid), *replicates, len(jobs), *workers)
+
+	executable, err := os.Executable()
+	if err != nil {
+		panic("Error finding the program to run simulations with.")
+	}
+	start := time.Now()
+	results := RunSweepJobs(executable, jobs, *workers)
+	fmt.Printf("Sweep finished in %s.\n", time.Since(start).Truncate(time.Second))
+
+	WriteSweepResults(filepath.Join(*out, "SweepResults.csv"), grid, jobs, results)
+}
+
+// RunSweepJobs: Runs the jobs on a pool of workers and collects the summary statistics of each one.
+// Input: executable (string) path to this program, jobs ([]SweepJob) the runs, workers (int) the pool size.
+// Output: ([]map[string]float64) the statistics of each job, nil for jobs that failed.
+func RunSweepJobs(executable string, jobs []SweepJob, workers int) []map[string]float64 {
+	results := make([]map[string]float64, len(jobs))
+	var printLock sync.Mutex
+	RunParallel(len(jobs), workers, func(i int) {
+		stats, err := jobs[i].Run(executable)
+		printLock.Lock()
+		if err != nil {
+			fmt.Printf("Run %d/%d failed: %v\n", i+1, len(jobs), err)
+		} else {
+			fmt.Printf("Run %d/%d finished.\n", i+1, len(jobs))
+		}
+		printLock.Unlock()
+		results[i] = stats
+	})
+	return results
+}
+
+// RunParallel: Calls task(i) for i = 0 ... n-1 on a pool of workers and waits for all of them to finish.
+// Input: n (int) number of tasks, workers (int) the pool size, task (func(int)) the work to do for each index.
+func RunParallel(n, workers int, task func(i int)) {
+	if workers < 1 {
+		workers = 1
+	}
+	indices := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				task(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		indices <- i
+	}
+	close(indices)
+	wg.Wait()
+}
+
+// Run: Runs a single job as a separate process and reads back its summary statistics.
+// Input: executable (string) path to this program.
+// Output: (map[string]float64) the statistics named in SweepStatistics, or an error.
+func (job SweepJob) Run(executable string) (map[string]float64, error) {
+	if err := job.Execute(executable); err != nil {
+		return nil, err
+	}
+	return ReadRunSummary(job.dir)
+}
+
+// Execute: Runs a single job as a separate process, passing every entry of job.values as a flag.
+// The output of the process is saved to run.log in the job's directory.
+// Input: executable (string) path to this program.
+// Output: (error) nil if the run finished all its generations.
+func (job SweepJob) Execute(executable string) error {
+	if err := os.MkdirAll(job.dir, 0755); err != nil {
+		return err
+	}
+	args := []string{"run", "-draw=false", "-seed", strconv.FormatInt(job.seed, 10), "-out", "."}
+	if TimeLimit > 0 {
+		args = append(args, "-timeout", TimeLimit.String())
+	}
+	names := make([]string, 0, len(job.values))
+	for name := range job.values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := job.values[name]
+		if name == "numGens" || name == "numCells" || name == "numFibres" {
+			number, _ := strconv.ParseFloat(value, 64)
+			value = strconv.Itoa(int(number))
+		}
+		args = append(args, "-"+name, value)
+	}
+	logFile, err := os.Create(filepath.Join(job.dir, "run.log"))
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+
+	command := exec.Command(executable, args...)
+	command.Dir = job.dir
+	command.Stdout = logFile
+	command.Stderr = logFile
+	if err := command.Run(); err != nil {
+		return fmt.Errorf("%v (see %s)", err, filepath.Join(job.dir, "run.log"))
+	}
+	// a run that was stopped early still exits normally, so check how far it got
+	status, err := ReadRunStatus(filepath.Join(job.dir, "RunStatus.json"))
+	if err != nil {
+		return err
+	}
+	if status.State != "complete" {
+		return fmt.Errorf("%s after %d of %d generations", status.State, status.Generations, status.Requested)
+	}
+	return nil
+}
+
+// ReadRunSummary: Reads the population motility statistics and the final fibre order parameter of a finished run.
+// Input: dir (string) the directory the run wrote its output to.
+// Output: (map[string]float64) the statistics named in SweepStatistics, or an error if the files can't be read.
+func ReadRunSummary(dir string) (map[string]float64, error) {
+	stats := make(map[string]float64)
+
+	summary, err := ReadCSV(filepath.Join(dir, "MotilitySummary.csv"))
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range summary {
+		if len(row) == len(MotilityHeader) && row[0] == "all" {
+			for i, name := range MotilityHeader[2:] {
+				stats[name] = ParseValue(row[i+2])
+			}
+		}
+	}
+
+	order, err := ReadCSV(filepath.Join(dir, "AlignmentOrder.csv"))
+	if err != nil {
+		return nil, err
+	}
+	stats["finalOrder"] = math.NaN()
+	if len(order) > 0 && len(order[len(order)-1]) > 1 {
+		stats["finalOrder"] = ParseValue(order[len(order)-1][1])
+	}
+	return stats, nil
+}
+
+// ReadCSV: Reads every row of a csv file. Rows may have different numbers of fields.
+func ReadCSV(filename string) ([][]string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	return reader.ReadAll()
+}
+
+// ParseValue: Parses a csv field written by FormatValue. Empty fields are NaN.
+func ParseValue(field string) float64 {
+	value, err := strconv.ParseFloat(field, 64)
+	if err != nil {
+		return math.NaN()
+	}
+	return value
+}
+
+// MeanAndStandardDeviation: Returns the mean and sample standard deviation of the non-NaN values.
+func MeanAndStandardDeviation(values []float64) (float64, float64) {
+	var finite []float64
+	for _, value := range values {
+		if !math.IsNaN(value) {
+			finite = append(finite, value)
+		}
+	}
+	mean := Mean(finite)
+	if len(finite) < 2 {
+		return mean, math.NaN()
+	}
+	sumSquares := 0.0
+	for _, value := range finite {
+		sumSquares += (value - mean) * (value - mean)
+	}
+	return mean, math.Sqrt(sumSquares / float64(len(finite)-1))
+}
+
+// WriteSweepResults: Writes one row per parameter combination with the parameter values, the number of
+// replicates that finished, and the mean and standard deviation of each statistic over those replicates.
+func WriteSweepResults(filename string, grid []map[string]string, jobs []SweepJob, results []map[string]float64) {
+	header := append([]string{}, SweepParameterNames...)
+	header = append(header, "replicates", "completed")
+	for _, stat := range SweepStatistics {
+		header = append(header, stat+"Mean", stat+"SD")
+	}
+	rows := [][]string{header}
+
+	for combination, parameters := range grid {
+		var row []string
+		for _, name := range SweepParameterNames {
+			row = append(row, parameters[name])
+		}
+		values := make(map[string][]float64)
+		replicates, completed := 0, 0
+		for i, job := range jobs {
+			if job.combination != combination {
+				continue
+			}
+			replicates++
+			if results[i] == nil {
+				continue
+			}
+			completed++
+			for _, stat := range SweepStatistics {
+				values[stat] = append(values[stat], results[i][stat])
+			}
+		}
+		row = append(row, strconv.Itoa(replicates), strconv.Itoa(completed))
+		for _, stat := range SweepStatistics {
+			mean, sd := MeanAndStandardDeviation(values[stat])
+			row = append(row, FormatValue(mean), FormatValue(sd))
+		}
+		rows = append(rows, row)
+	}
+	WriteCSV(filename, rows)
+	fmt.Println("Results written to " + filename + ".")
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseSweepValues(t *testing.T) {
+	type test struct {
+		spec   string
+		answer string
+	}
+
+	tests := make([]test, 4)
+	tests[0].spec = "10,15,20"
+	tests[0].answer = "10 15 20"
+
+	tests[1].spec = "0.5:0.95:0.15"
+	tests[1].answer = "0.5 0.65 0.8 0.95"
+
+	tests[2].spec = "3"
+	tests[2].answer = "3"
+
+	tests[3].spec = "1:2:5"
+	tests[3].answer = "1"
+
+	for i, test := range tests {
+		outcome, err := ParseSweepValues(test.spec)
+		if err != nil {
+			t.Errorf("Error! For input test dataset %d, your code returns the error %v", i, err)
+			continue
+		}
+		if strings.Join(outcome, " ") != test.answer {
+			t.Errorf("Error! For input test dataset %d, your code gives %v, and the correct values are %s", i, outcome, test.answer)
+		}
+	}
+
+	for _, spec := range []string{"a,b", "1:2", "2:1:0.5", "0:1:0"} {
+		if _, err := ParseSweepValues(spec); err == nil {
+			t.Errorf("Error! %q should not be accepted", spec)
+		}
+	}
+}
+
+func TestParameterGrid(t *testing.T) {
+	grid := ParameterGrid([]string{"a", "b"}, [][]string{{"1", "2"}, {"x", "y", "z"}})
+	if len(grid) != 6 {
+		t.Fatalf("Error! Your code gives %d combinations, and there are 6", len(grid))
+	}
+	if grid[0]["a"] != "1" || grid[0]["b"] != "x" || grid[5]["a"] != "2" || grid[5]["b"] != "z" {
+		t.Errorf("Error! The grid is in the wrong order: %v", grid)
+	}
+}
+
+func TestDefaultSeed(t *testing.T) {
+	for i := 0; i < 1000; i++ {
+		if seed := DefaultSeed(); seed < 1 || seed > MaxDefaultSeed {
+			t.Fatalf("Error! For input test dataset %d, your code gives the seed %d, and the correct seed is between 1 and %d.", i, seed, MaxDefaultSeed)
+		}
+	}
+}
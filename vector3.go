@@ -0,0 +1,82 @@
+package main
+
+import (
+	"math"
+)
+
+// Vector3 is a point or direction in 3D space. It is the 3D counterpart of OrderedPair.
+type Vector3 struct {
+	x, y, z float64
+}
+
+// ComputeDistance3D: Returns the distance between two points in 3D space.
+func ComputeDistance3D(p1, p2 Vector3) float64 {
+	delta := SubtractVectors3D(p1, p2)
+	return delta.Magnitude()
+}
+
+// Magnitude: Calculate the magnitude of a Vector3.
+func (v *Vector3) Magnitude() float64 {
+	return math.Sqrt(v.x*v.x + v.y*v.y + v.z*v.z)
+}
+
+// Normalize: Normalizes a Vector3 so that its magnitude = 1. The zero vector is left unchanged.
+func (v *Vector3) Normalize() {
+	magnitude := v.Magnitude()
+	if magnitude == 0 {
+		return
+	}
+	v.x /= magnitude
+	v.y /= magnitude
+	v.z /= magnitude
+}
+
+// AddVectors3D: Returns v1 + v2.
+func AddVectors3D(v1, v2 Vector3) Vector3 {
+	return Vector3{v1.x + v2.x, v1.y + v2.y, v1.z + v2.z}
+}
+
+// SubtractVectors3D: Returns v1 - v2.
+func SubtractVectors3D(v1, v2 Vector3) Vector3 {
+	return Vector3{v1.x - v2.x, v1.y - v2.y, v1.z - v2.z}
+}
+
+// MultiplyVectorByConstant3D: Multiplies a vector by some constant value.
+func MultiplyVectorByConstant3D(v Vector3, constant float64) Vector3 {
+	return Vector3{v.x * constant, v.y * constant, v.z * constant}
+}
+
+// DotProduct3D: Returns the dot product between two vectors in R3.
+func DotProduct3D(v1, v2 Vector3) float64 {
+	return v1.x*v2.x + v1.y*v2.y + v1.z*v2.z
+}
+
+// CrossProduct3D: Returns the cross product v1 x v2.
+func CrossProduct3D(v1, v2 Vector3) Vector3 {
+	return Vector3{v1.y*v2.z - v1.z*v2.y, v1.z*v2.x - v1.x*v2.z, v1.x*v2.y - v1.y*v2.x}
+}
+
+// RotateAboutAxis3D: Rotates v by some angle about a unit axis using Rodrigues' rotation formula.
+// Input: v (Vector3) the vector, axis (Vector3) the unit axis, angle (float64) the angle in radians.
+// Output: (Vector3) the rotated vector.
+func RotateAboutAxis3D(v, axis Vector3, angle float64) Vector3 {
+	cos, sin := math.Cos(angle), math.Sin(angle)
+	term1 := MultiplyVectorByConstant3D(v, cos)
+	term2 := MultiplyVectorByConstant3D(CrossProduct3D(axis, v), sin)
+	term3 := MultiplyVectorByConstant3D(axis, DotProduct3D(axis, v)*(1-cos))
+	return AddVectors3D(AddVectors3D(term1, term2), term3)
+}
+
+// RandomUnitVector3D: Returns a direction drawn uniformly from the unit sphere.
+func RandomUnitVector3D() Vector3 {
+	z := rng.Float64()*2 - 1
+	phi := rng.Float64() * 2 * math.Pi
+	r := math.Sqrt(1 - z*z)
+	return Vector3{r * math.Cos(phi), r * math.Sin(phi), z}
+}
+
+// AngleBetweenVectors3D: Returns the angle between two vectors in radians.
+func AngleBetweenVectors3D(v1, v2 Vector3) float64 {
+	cosTheta := DotProduct3D(v1, v2) / (v1.Magnitude() * v2.Magnitude())
+	return math.Acos(math.Max(-1, math.Min(1, cosTheta)))
+}